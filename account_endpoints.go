@@ -0,0 +1,249 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	auth "github.com/SergioFloresCorrea/Chirpy/internal"
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+)
+
+// ChangeOwnPassword replaces the password of the caller after checking the
+// current one, then revokes every other session, so only the device making
+// the change stays logged in.
+func (cfg *apiConfig) ChangeOwnPassword(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if !cfg.checkCurrentPassword(w, req, user, expectedJson.CurrentPassword, "Current password is incorrect") {
+		return
+	}
+
+	if !cfg.checkPasswordPolicy(w, expectedJson.NewPassword, user.Email) {
+		return
+	}
+
+	hashedPassword, err := cfg.passwordHasher.Hash(expectedJson.NewPassword)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error in hashing password")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	err = qtx.UpdateUserPassword(req.Context(), database.UpdateUserPasswordParams{
+		HashedPassword: hashedPassword,
+		UpdatedAt:      time.Now(),
+		ID:             user.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	err = qtx.RevokeOtherSessionsForUser(req.Context(), database.RevokeOtherSessionsForUserParams{
+		RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UserID:    user.ID,
+		FamilyID:  caller.SessionID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(user.ID),
+		Action:     "user.password_changed",
+		TargetType: "user",
+		TargetID:   user.ID.String(),
+	})
+	cfg.sendMail(req.Context(), user.Email, "Your Chirpy password was changed",
+		"The password of your Chirpy account was just changed and your other sessions were logged out. If this wasn't you, reset your password right away.")
+
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+// RequestEmailChange mails a confirmation token to the new address. The
+// account keeps its current email until that token is sent to
+// POST /api/users/verify.
+func (cfg *apiConfig) RequestEmailChange(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		Password string `json:"password"`
+		NewEmail string `json:"new_email"`
+	}
+
+	caller, ok := cfg.authorize(w, req, auth.ScopeProfileWrite)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	if expectedJson.NewEmail == "" {
+		respondWithError(w, http.StatusBadRequest, "new_email is required")
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if !cfg.checkCurrentPassword(w, req, user, expectedJson.Password, "Password is incorrect") {
+		return
+	}
+
+	if expectedJson.NewEmail == user.Email {
+		respondWithError(w, http.StatusBadRequest, "new_email is already the account email")
+		return
+	}
+
+	if _, err := cfg.dbQueries.GetUserByEmail(req.Context(), expectedJson.NewEmail); err == nil {
+		respondWithError(w, http.StatusConflict, "Email address is already in use")
+		return
+	}
+
+	if err := cfg.sendVerificationEmail(req.Context(), user, expectedJson.NewEmail); err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(user.ID),
+		Action:     "user.email_change_requested",
+		TargetType: "user",
+		TargetID:   user.ID.String(),
+		Details:    map[string]string{"old_email": user.Email, "new_email": expectedJson.NewEmail},
+	})
+	cfg.sendMail(req.Context(), user.Email, "Your Chirpy email address is changing",
+		fmt.Sprintf("Someone asked to move your Chirpy account to %s. The change only happens once the new address is confirmed. If this wasn't you, change your password right away.", expectedJson.NewEmail))
+
+	respondWithJSON(w, http.StatusAccepted, map[string]string{"status": "A confirmation email has been sent to the new address."})
+}
+
+// DeleteOwnAccount schedules the caller's account for deletion once the grace
+// period has passed. Until then the account is hidden from public reads and
+// logged out everywhere; logging in again cancels the deletion.
+func (cfg *apiConfig) DeleteOwnAccount(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		Password string `json:"password"`
+	}
+
+	type ResponseJson struct {
+		ScheduledDeletionAt time.Time `json:"scheduled_deletion_at"`
+	}
+
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if !cfg.checkCurrentPassword(w, req, user, expectedJson.Password, "Password is incorrect") {
+		return
+	}
+
+	deleteAt := time.Now().Add(cfg.deletionGracePeriod)
+
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	err = qtx.ScheduleUserDeletion(req.Context(), database.ScheduleUserDeletionParams{
+		ScheduledDeletionAt: sql.NullTime{Time: deleteAt, Valid: true},
+		UpdatedAt:           time.Now(),
+		ID:                  user.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	err = qtx.RevokeAllRefreshTokensForUser(req.Context(), database.RevokeAllRefreshTokensForUserParams{
+		RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UserID:    user.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(user.ID),
+		Action:     "user.deletion_scheduled",
+		TargetType: "user",
+		TargetID:   user.ID.String(),
+		Details:    map[string]time.Time{"scheduled_deletion_at": deleteAt},
+	})
+	cfg.sendMail(req.Context(), user.Email, "Your Chirpy account will be deleted",
+		fmt.Sprintf("Your Chirpy account and all of its chirps will be deleted on %s. Log in before then to keep it.", deleteAt.UTC().Format(time.RFC1123)))
+
+	respondWithJSON(w, http.StatusAccepted, ResponseJson{ScheduledDeletionAt: deleteAt})
+}
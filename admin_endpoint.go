@@ -5,22 +5,6 @@ import (
 	"net/http"
 )
 
-func (cfg *apiConfig) ResetCounterRequests(w http.ResponseWriter, req *http.Request) {
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	if cfg.platform != "dev" {
-		w.WriteHeader(http.StatusForbidden)
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
-	prevCount := cfg.fileserverHits.Swap(0)
-	fmt.Fprintf(w, "The number of hits has been reset from %d to %d.\n", prevCount, cfg.fileserverHits.Load())
-
-	cfg.dbQueries.DeleteUsers(req.Context())
-	fmt.Fprintf(w, "All users data has been deleted.\n")
-
-}
-
 func (cfg *apiConfig) CountRequests(w http.ResponseWriter, req *http.Request) {
 	htmlForm := `
 	<html>
@@ -32,5 +16,5 @@ func (cfg *apiConfig) CountRequests(w http.ResponseWriter, req *http.Request) {
 	`
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, htmlForm, cfg.fileserverHits.Load())
+	fmt.Fprintf(w, htmlForm, cfg.metrics.fileserverHits.Value())
 }
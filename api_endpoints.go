@@ -3,13 +3,16 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"sort"
 	"time"
 
 	auth "github.com/SergioFloresCorrea/Chirpy/internal"
 	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/SergioFloresCorrea/Chirpy/internal/moderation"
 	"github.com/google/uuid"
 )
 
@@ -24,16 +27,21 @@ func (cfg *apiConfig) ValidateAndSaveChirp(w http.ResponseWriter, req *http.Requ
 		Body string `json:"body"`
 	}
 
-	tokenString, err := checkAuthHeader(req)
-	if err != nil {
-		respondWithError(w, 400, fmt.Sprintf("%v", err))
+	caller, ok := cfg.authorize(w, req, auth.ScopeChirpsWrite)
+	if !ok {
 		return
 	}
-	userID, err := auth.ValidateJWT(tokenString, cfg.secret)
+	userID := caller.UserID
+
+	blocked, err := cfg.blockedUntilVerified(req.Context(), userID, actionCreateChirp)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "Verify your email address before posting chirps")
+		return
+	}
 
 	decoder := json.NewDecoder(req.Body)
 	expectedJson := ExpectedJson{}
@@ -49,12 +57,29 @@ func (cfg *apiConfig) ValidateAndSaveChirp(w http.ResponseWriter, req *http.Requ
 		return
 	}
 
+	decision, err := cfg.moderateChirp(req, userID, expectedJson.Body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if decision.Action == moderation.ActionReject {
+		cfg.metrics.chirps.With("rejected").Inc()
+		message := decision.Message
+		if message == "" {
+			message = "Chirp was rejected by the content rules"
+		}
+		respondWithError(w, http.StatusUnprocessableEntity, message)
+		return
+	}
+
 	params := database.CreateChirpParams{
 		ID:        uuid.New(),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
-		Body:      replaceBadWords(expectedJson.Body),
+		Body:      decision.Body,
 		UserID:    userID,
+		Status:    chirpStatusFor(decision),
+		BodyHash:  chirpBodyHash(expectedJson.Body),
 	}
 
 	chirp, err := cfg.dbQueries.CreateChirp(req.Context(), params)
@@ -62,7 +87,16 @@ func (cfg *apiConfig) ValidateAndSaveChirp(w http.ResponseWriter, req *http.Requ
 		respondWithError(w, 400, fmt.Sprintf("%v", err))
 		return
 	}
-	responseJson := Chirp(chirp)
+	cfg.metrics.chirps.With(chirp.Status).Inc()
+	if chirp.Status == chirpStatusHeld {
+		message := decision.Message
+		if message == "" {
+			message = "Chirp is held for review"
+		}
+		respondWithJSON(w, http.StatusAccepted, HeldChirp{Chirp: toChirp(chirp), Status: chirp.Status, Message: message})
+		return
+	}
+	responseJson := toChirp(chirp)
 	respondWithJSON(w, 201, responseJson)
 }
 
@@ -73,11 +107,12 @@ func (cfg *apiConfig) CreateUser(w http.ResponseWriter, req *http.Request) {
 	}
 
 	type ResponseJson struct {
-		ID          uuid.UUID `json:"id"`
-		CreatedAt   time.Time `json:"created_at"`
-		UpdatedAt   time.Time `json:"updated_at"`
-		Email       string    `json:"email"`
-		IsChirpyRed bool      `json:"is_chirpy_red"`
+		ID            uuid.UUID `json:"id"`
+		CreatedAt     time.Time `json:"created_at"`
+		UpdatedAt     time.Time `json:"updated_at"`
+		Email         string    `json:"email"`
+		IsChirpyRed   bool      `json:"is_chirpy_red"`
+		EmailVerified bool      `json:"email_verified"`
 	}
 
 	decoder := json.NewDecoder(req.Body)
@@ -88,7 +123,11 @@ func (cfg *apiConfig) CreateUser(w http.ResponseWriter, req *http.Request) {
 	}
 	defer req.Body.Close()
 
-	hashedPassword, err := auth.HashPassword(expectedJson.Password)
+	if !cfg.checkPasswordPolicy(w, expectedJson.Password, expectedJson.Email) {
+		return
+	}
+
+	hashedPassword, err := cfg.passwordHasher.Hash(expectedJson.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error in hashing password")
 		return
@@ -106,12 +145,18 @@ func (cfg *apiConfig) CreateUser(w http.ResponseWriter, req *http.Request) {
 		respondWithError(w, 400, fmt.Sprintf("%v", err))
 		return
 	}
+
+	if err := cfg.sendVerificationEmail(req.Context(), user, user.Email); err != nil {
+		log.Printf("Couldn't issue verification token for new user: %v\n", err)
+	}
+
 	responseJson := ResponseJson{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		IsChirpyRed:   user.IsChirpyRed,
+		EmailVerified: user.EmailVerifiedAt.Valid,
 	}
 
 	respondWithJSON(w, 201, responseJson)
@@ -119,18 +164,9 @@ func (cfg *apiConfig) CreateUser(w http.ResponseWriter, req *http.Request) {
 
 func (cfg *apiConfig) LoginUser(w http.ResponseWriter, req *http.Request) {
 	type ExpectedJson struct {
-		Password string `json:"password"`
-		Email    string `json:"email"`
-	}
-
-	type ResponseJson struct {
-		ID           uuid.UUID `json:"id"`
-		CreatedAt    time.Time `json:"created_at"`
-		UpdatedAt    time.Time `json:"updated_at"`
-		Email        string    `json:"email"`
-		Token        string    `json:"token"`
-		RefreshToken string    `json:"refresh_token"`
-		IsChirpyRed  bool      `json:"is_chirpy_red"`
+		Password   string `json:"password"`
+		Email      string `json:"email"`
+		DeviceName string `json:"device_name"`
 	}
 
 	decoder := json.NewDecoder(req.Body)
@@ -141,59 +177,139 @@ func (cfg *apiConfig) LoginUser(w http.ResponseWriter, req *http.Request) {
 	}
 	defer req.Body.Close()
 
+	ip := clientIP(req)
+	wait, err := cfg.loginThrottle.take(req.Context(), expectedJson.Email, ip)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if wait > 0 {
+		respondWithRetryAfter(w, wait)
+		return
+	}
+
 	user, err := cfg.dbQueries.GetUserByEmail(req.Context(), expectedJson.Email)
 	if err != nil {
+		// Hash anyway, so the response takes as long as for a wrong password.
+		cfg.passwordHasher.Verify(cfg.dummyPasswordHash, expectedJson.Password)
+		cfg.loginThrottle.fail(req.Context(), expectedJson.Email, ip)
+		cfg.metrics.countLogin("password", false)
 		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
-	if err := auth.CheckPasswordHash(user.HashedPassword, expectedJson.Password); err != nil {
+	needsRehash, err := cfg.passwordHasher.Verify(user.HashedPassword, expectedJson.Password)
+	if err != nil {
+		cfg.loginThrottle.fail(req.Context(), expectedJson.Email, ip)
+		cfg.metrics.countLogin("password", false)
 		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
+	// The account's failures stay until completeLogin, so that a correct
+	// password doesn't buy fresh guesses at the second factor.
+	cfg.loginThrottle.passed(req.Context(), ip)
+	cfg.metrics.countLogin("password", true)
+	if needsRehash {
+		cfg.rehashPassword(req.Context(), user.ID, expectedJson.Password)
+	}
 
-	tokenString, err := auth.MakeJWT(user.ID, cfg.secret, time.Hour)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "An error ocurred in creating a JWT")
+	cfg.continueLogin(w, req, user, expectedJson.DeviceName)
+}
+
+// continueLogin picks up once a user has proven who they are with a first
+// factor, a password or an external identity. It either asks for the second
+// factor or starts the session.
+func (cfg *apiConfig) continueLogin(w http.ResponseWriter, req *http.Request, user database.User, deviceName string) {
+	if cfg.unverifiedRestrictions.blocks(actionLogin, user) {
+		respondWithError(w, http.StatusForbidden, "Verify your email address before logging in")
+		return
+	}
+	if cfg.refuseSuspendedLogin(w, req, user.ID) {
 		return
 	}
 
-	refreshToken, err := auth.MakeRefreshToken() // the error is always nil
-	if err != nil {
+	totp, err := cfg.dbQueries.GetTOTPByUserID(req.Context(), user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
 		return
 	}
+	if err == nil && totp.ConfirmedAt.Valid {
+		cfg.startMFAChallenge(w, req, user.ID, deviceName)
+		return
+	}
 
-	params := database.CreateRefreshTokenParams{
-		Token:     refreshToken,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		UserID:    user.ID,
-		ExpiresAt: time.Now().Add(60 * 24 * time.Hour),
+	cfg.completeLogin(w, req, user, deviceName)
+}
+
+// completeLogin starts a new session for a user who has proven who they are,
+// with a password alone or followed by a second factor. Only now are the
+// account's failed logins cleared.
+func (cfg *apiConfig) completeLogin(w http.ResponseWriter, req *http.Request, user database.User, deviceName string) {
+	type ResponseJson struct {
+		ID            uuid.UUID `json:"id"`
+		CreatedAt     time.Time `json:"created_at"`
+		UpdatedAt     time.Time `json:"updated_at"`
+		Email         string    `json:"email"`
+		Token         string    `json:"token"`
+		RefreshToken  string    `json:"refresh_token"`
+		IsChirpyRed   bool      `json:"is_chirpy_red"`
+		EmailVerified bool      `json:"email_verified"`
+	}
+
+	cfg.loginThrottle.succeed(req.Context(), user.Email)
+	if user.ScheduledDeletionAt.Valid {
+		err := cfg.dbQueries.CancelUserDeletion(req.Context(), database.CancelUserDeletionParams{UpdatedAt: time.Now(), ID: user.ID})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+			return
+		}
+		cfg.recordAudit(req.Context(), req, auditEntry{
+			ActorID:    actor(user.ID),
+			Action:     "user.deletion_cancelled",
+			TargetType: "user",
+			TargetID:   user.ID.String(),
+		})
+	}
+
+	sessionID := uuid.New()
+	claims, err := cfg.sessionClaims(req.Context(), user, sessionID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	tokenString, err := cfg.keyring.MakeJWT(claims, time.Hour)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "An error ocurred in creating a JWT")
+		return
 	}
 
-	_, err = cfg.dbQueries.CreateRefreshToken(req.Context(), params)
+	refreshToken, err := issueRefreshToken(req.Context(), cfg.dbQueries, user.ID, sessionID, newSessionClient(req, deviceName))
 	if err != nil {
 		respondWithError(w, 400, fmt.Sprintf("%v", err))
 		return
 	}
 
 	responseJson := ResponseJson{
-		ID:           user.ID,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
-		Email:        user.Email,
-		Token:        tokenString,
-		RefreshToken: refreshToken,
-		IsChirpyRed:  user.IsChirpyRed,
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		Token:         tokenString,
+		RefreshToken:  refreshToken,
+		IsChirpyRed:   user.IsChirpyRed,
+		EmailVerified: user.EmailVerifiedAt.Valid,
 	}
 
 	respondWithJSON(w, http.StatusOK, responseJson)
 }
 
+// RefreshAccessToken trades a refresh token for a new access token and a new
+// refresh token of the same family. Tokens issued to OAuth clients have to be
+// refreshed at /oauth/token instead.
 func (cfg *apiConfig) RefreshAccessToken(w http.ResponseWriter, req *http.Request) {
 	type ResponseJson struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 
 	if !hasNoBody(req) {
@@ -207,30 +323,34 @@ func (cfg *apiConfig) RefreshAccessToken(w http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	tokenRefreshDb, err := cfg.dbQueries.GetRefreshTokenByToken(req.Context(), tokenRefreshString)
-	if err != nil || tokenRefreshDb.ExpiresAt.Before(time.Now()) {
+	tokenRefreshDb, refreshToken, err := cfg.rotateRefreshToken(req, tokenRefreshString, "")
+	if errors.Is(err, errInvalidRefreshToken) {
 		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
-
-	if tokenRefreshDb.RevokedAt.Valid {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
 		return
 	}
 
-	user, err := cfg.dbQueries.GetUserFromRefreshToken(req.Context(), tokenRefreshString)
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), tokenRefreshDb.UserID)
 	if err != nil {
 		respondWithError(w, 400, fmt.Sprintf("%v", err))
 		return
 	}
 
-	tokenString, err := auth.MakeJWT(user.ID, cfg.secret, time.Hour)
+	claims, err := cfg.sessionClaims(req.Context(), user, tokenRefreshDb.FamilyID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	tokenString, err := cfg.keyring.MakeJWT(claims, time.Hour)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "An error ocurred in creating a JWT")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, ResponseJson{Token: tokenString})
+	respondWithJSON(w, http.StatusOK, ResponseJson{Token: tokenString, RefreshToken: refreshToken})
 }
 
 func (cfg *apiConfig) RevokeRefreshToken(w http.ResponseWriter, req *http.Request) {
@@ -248,7 +368,7 @@ func (cfg *apiConfig) RevokeRefreshToken(w http.ResponseWriter, req *http.Reques
 	params := database.SetRevokeAtParams{
 		RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
 		UpdatedAt: time.Now(),
-		Token:     tokenRefreshString,
+		TokenHash: auth.HashToken(tokenRefreshString),
 	}
 	err = cfg.dbQueries.SetRevokeAt(req.Context(), params)
 	if err != nil {
@@ -274,8 +394,19 @@ func (cfg *apiConfig) GetAllChirps(w http.ResponseWriter, req *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "sort query must be either asc or desc")
 	}
 
+	// Authors see their own chirps whatever moderation did with them, so
+	// that a shadow-hidden chirp looks published to them.
+	caller, authenticated, ok := cfg.authorizeIfPresented(w, req, auth.ScopeChirpsRead)
+	if !ok {
+		return
+	}
+
 	if authorIDStr == "" {
-		chirps, err = cfg.dbQueries.GetChirps(req.Context())
+		var viewerID uuid.NullUUID
+		if authenticated {
+			viewerID = uuid.NullUUID{UUID: caller.UserID, Valid: true}
+		}
+		chirps, err = cfg.dbQueries.GetChirps(req.Context(), viewerID)
 		if err != nil {
 			respondWithError(w, 400, fmt.Sprintf("%v", err))
 			return
@@ -286,7 +417,11 @@ func (cfg *apiConfig) GetAllChirps(w http.ResponseWriter, req *http.Request) {
 			respondWithError(w, http.StatusBadRequest, "Invalid author ID format")
 			return
 		}
-		chirps, err = cfg.dbQueries.GetChirpsByUserID(req.Context(), authorID)
+		if authenticated && caller.UserID == authorID {
+			chirps, err = cfg.dbQueries.GetOwnChirps(req.Context(), authorID)
+		} else {
+			chirps, err = cfg.dbQueries.GetChirpsByUserID(req.Context(), authorID)
+		}
 		if err != nil {
 			respondWithError(w, 400, fmt.Sprintf("%v", err))
 			return
@@ -295,7 +430,7 @@ func (cfg *apiConfig) GetAllChirps(w http.ResponseWriter, req *http.Request) {
 
 	responseJson := make([]Chirp, 0, len(chirps))
 	for _, chirp := range chirps {
-		responseJson = append(responseJson, Chirp(chirp))
+		responseJson = append(responseJson, toChirp(chirp))
 	}
 
 	// sorting
@@ -315,27 +450,39 @@ func (cfg *apiConfig) GetChirpByID(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	caller, authenticated, ok := cfg.authorizeIfPresented(w, req, auth.ScopeChirpsRead)
+	if !ok {
+		return
+	}
+
 	chirp, err := cfg.dbQueries.GetChirpByID(req.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) && authenticated {
+		chirp, err = cfg.ownChirp(req.Context(), caller, chirpID)
+	}
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, fmt.Sprintf("%v", err))
 		return
 	}
-	responseJson := Chirp(chirp)
+	responseJson := toChirp(chirp)
 	respondWithJSON(w, http.StatusOK, responseJson)
 }
 
 func (cfg *apiConfig) DeleteChirpByID(w http.ResponseWriter, req *http.Request) {
-	accessToken, err := checkAuthHeader(req)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+	caller, ok := cfg.authorize(w, req, auth.ScopeChirpsWrite)
+	if !ok {
 		return
 	}
+	userID := caller.UserID
 
-	userID, err := auth.ValidateJWT(accessToken, cfg.secret)
+	blocked, err := cfg.blockedUntilVerified(req.Context(), userID, actionDeleteChirp)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "Verify your email address before deleting chirps")
+		return
+	}
 
 	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
 	if err != nil {
@@ -344,6 +491,9 @@ func (cfg *apiConfig) DeleteChirpByID(w http.ResponseWriter, req *http.Request)
 	}
 
 	chirp, err := cfg.dbQueries.GetChirpByID(req.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		chirp, err = cfg.dbQueries.GetOwnChirpByID(req.Context(), database.GetOwnChirpByIDParams{ID: chirpID, UserID: userID})
+	}
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, fmt.Sprintf("%v", err))
 		return
@@ -362,67 +512,6 @@ func (cfg *apiConfig) DeleteChirpByID(w http.ResponseWriter, req *http.Request)
 	respondWithJSON(w, http.StatusNoContent, nil)
 }
 
-func (cfg *apiConfig) UpdateOwnEmail(w http.ResponseWriter, req *http.Request) {
-	type ExpectedJson struct {
-		Password string `json:"password"`
-		Email    string `json:"email"`
-	}
-
-	type ResponseJson struct {
-		ID          uuid.UUID `json:"id"`
-		CreatedAt   time.Time `json:"created_at"`
-		UpdatedAt   time.Time `json:"updated_at"`
-		Email       string    `json:"email"`
-		IsChirpyRed bool      `json:"is_chirpy_red"`
-	}
-
-	accessToken, err := checkAuthHeader(req)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
-		return
-	}
-
-	userID, err := auth.ValidateJWT(accessToken, cfg.secret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
-		return
-	}
-
-	decoder := json.NewDecoder(req.Body)
-	expectedJson := ExpectedJson{}
-	if err := decoder.Decode(&expectedJson); err != nil {
-		respondWithError(w, 400, "Something went wrong")
-		return
-	}
-	defer req.Body.Close()
-
-	hashedPassword, err := auth.HashPassword(expectedJson.Password)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
-		return
-	}
-
-	params := database.UpdateUserEmailAndPasswordParams{
-		Email:          expectedJson.Email,
-		HashedPassword: hashedPassword,
-		ID:             userID,
-	}
-	user, err := cfg.dbQueries.UpdateUserEmailAndPassword(req.Context(), params)
-	if err != nil {
-		respondWithError(w, 400, fmt.Sprintf("%v", err))
-		return
-	}
-
-	responseJson := ResponseJson{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
-	}
-	respondWithJSON(w, http.StatusOK, responseJson)
-}
-
 func (cfg *apiConfig) UpgradoUserToRed(w http.ResponseWriter, req *http.Request) {
 	type ExpectedJson struct {
 		Event string `json:"event"`
@@ -461,11 +550,25 @@ func (cfg *apiConfig) UpgradoUserToRed(w http.ResponseWriter, req *http.Request)
 		return
 	}
 
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("%v", err))
+		return
+	}
 	err = cfg.dbQueries.UpgradeUserToRedByID(req.Context(), userID)
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, fmt.Sprintf("%v", err))
 		return
 	}
 
+	// Polka authenticates with an API key, so there is no actor to record.
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		Action:     "user.upgraded",
+		TargetType: "user",
+		TargetID:   userID.String(),
+		Details:    map[string]string{"source": "polka"},
+		Before:     map[string]bool{"is_chirpy_red": user.IsChirpyRed},
+		After:      map[string]bool{"is_chirpy_red": true},
+	})
 	respondWithJSON(w, http.StatusNoContent, nil)
 }
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/SergioFloresCorrea/Chirpy/internal/auditlog"
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// auditEntry describes one security-relevant action. Details, Before and After
+// are marshalled into JSONB columns and may be nil. Before and After snapshot
+// the target around a change, for actions that modify something.
+type auditEntry struct {
+	ActorID    uuid.NullUUID
+	Action     string
+	TargetType string
+	TargetID   string
+	Details    any
+	Before     any
+	After      any
+}
+
+// recordAudit appends entry to the audit log. A failure is logged rather than
+// returned, because the action it describes has already happened.
+func (cfg *apiConfig) recordAudit(ctx context.Context, req *http.Request, entry auditEntry) {
+	params := database.CreateAuditLogEntryParams{
+		ID:         uuid.New(),
+		CreatedAt:  time.Now(),
+		ActorID:    entry.ActorID,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		Ip:         clientIP(req),
+		Details:    encodeAuditJSON(entry.Action, entry.Details, "{}"),
+		Before:     encodeAuditJSON(entry.Action, entry.Before, "null"),
+		After:      encodeAuditJSON(entry.Action, entry.After, "null"),
+		RequestID:  requestIDFromContext(req.Context()),
+	}
+	if err := cfg.dbQueries.CreateAuditLogEntry(ctx, params); err != nil {
+		log.Printf("Couldn't record audit entry %s: %v\n", entry.Action, err)
+	}
+}
+
+func encodeAuditJSON(action string, value any, empty string) json.RawMessage {
+	if value == nil {
+		return json.RawMessage(empty)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("Couldn't encode audit details for %s: %v\n", action, err)
+		return json.RawMessage(empty)
+	}
+	return encoded
+}
+
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+func actor(userID uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: userID, Valid: true}
+}
+
+const requestIDHeader = "X-Request-ID"
+
+type requestIDContextKey struct{}
+
+func requestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+// middlewareRequestID gives every request an ID, echoed in the response and
+// stored with its audit entries. A well-formed ID sent by a proxy in front of
+// Chirpy is kept, so entries can be matched with the proxy's logs.
+func middlewareRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requestID := req.Header.Get(requestIDHeader)
+		if !auditlog.ValidRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), requestIDContextKey{}, requestID)))
+	})
+}
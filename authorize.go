@@ -0,0 +1,101 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	auth "github.com/SergioFloresCorrea/Chirpy/internal"
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// principal is whoever a request acts for, whether it presented an access
+// token from a login or a personal access token.
+type principal struct {
+	UserID uuid.UUID
+	// SessionID is uuid.Nil for personal access tokens.
+	SessionID uuid.UUID
+	// TokenID is the personal access token used, or uuid.Nil for a login.
+	TokenID uuid.UUID
+	Scopes  []string
+	Roles   []string
+}
+
+// authenticate resolves the bearer token of req into a principal.
+func (cfg *apiConfig) authenticate(req *http.Request) (principal, error) {
+	token, err := checkAuthHeader(req)
+	if err != nil {
+		return principal{}, err
+	}
+
+	if !auth.IsPersonalAccessToken(token) {
+		claims, err := cfg.keyring.ValidateJWT(token)
+		if err != nil {
+			return principal{}, err
+		}
+		if err := cfg.checkNotSuspended(req.Context(), claims.UserID); err != nil {
+			return principal{}, err
+		}
+		return principal{UserID: claims.UserID, SessionID: claims.SessionID, Scopes: claims.Scopes, Roles: claims.Roles}, nil
+	}
+
+	pat, err := cfg.dbQueries.GetPersonalAccessTokenByHash(req.Context(), auth.HashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return principal{}, errInvalidCredentials
+	}
+	if err != nil {
+		return principal{}, err
+	}
+	now := time.Now()
+	if pat.RevokedAt.Valid || !now.Before(pat.ExpiresAt) {
+		return principal{}, errInvalidCredentials
+	}
+	if err := cfg.checkNotSuspended(req.Context(), pat.UserID); err != nil {
+		return principal{}, err
+	}
+
+	err = cfg.dbQueries.TouchPersonalAccessToken(req.Context(), database.TouchPersonalAccessTokenParams{
+		LastUsedAt: sql.NullTime{Time: now, Valid: true},
+		ID:         pat.ID,
+	})
+	if err != nil {
+		log.Printf("couldn't record use of personal access token %s: %v", pat.ID, err)
+	}
+	return principal{UserID: pat.UserID, TokenID: pat.ID, Scopes: pat.Scopes}, nil
+}
+
+// authorize authenticates req and checks that it was granted scope. On
+// failure it writes the error response itself and returns false.
+func (cfg *apiConfig) authorize(w http.ResponseWriter, req *http.Request, scope string) (principal, bool) {
+	caller, err := cfg.authenticate(req)
+	if errors.Is(err, errAccountSuspended) {
+		respondWithError(w, http.StatusForbidden, "Account suspended")
+		return principal{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return principal{}, false
+	}
+	if !auth.HasScope(caller.Scopes, scope) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+		respondWithError(w, http.StatusForbidden, "Token lacks the "+scope+" scope")
+		return principal{}, false
+	}
+	return caller, true
+}
+
+// authorizeIfPresented is authorize for endpoints anyone may call. Without an
+// Authorization header the caller is anonymous; with one, the token has to be
+// valid and carry scope, as anywhere else a token is presented.
+func (cfg *apiConfig) authorizeIfPresented(w http.ResponseWriter, req *http.Request, scope string) (caller principal, authenticated, ok bool) {
+	if req.Header.Get("Authorization") == "" {
+		return principal{}, false, true
+	}
+	caller, ok = cfg.authorize(w, req, scope)
+	return caller, ok, ok
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/SergioFloresCorrea/Chirpy/internal/moderation"
+	"github.com/google/uuid"
+)
+
+// Chirp statuses. Only published chirps show up in public reads; the author
+// always sees their own.
+const (
+	chirpStatusPublished = "published"
+	chirpStatusHeld      = "held"
+	chirpStatusHidden    = "hidden"
+)
+
+const (
+	defaultHeldChirpPageSize = 50
+	maxHeldChirpPageSize     = 100
+)
+
+// newModerationEngine loads the rules in MODERATION_RULES_FILE, or the
+// built-in word filter when it is unset.
+func newModerationEngine() (*moderation.Engine, error) {
+	path := os.Getenv("MODERATION_RULES_FILE")
+	if path == "" {
+		return moderation.NewEngine(moderation.DefaultConfig())
+	}
+	engine, err := moderation.LoadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load the moderation rules: %w", err)
+	}
+	return engine, nil
+}
+
+func (cfg *apiConfig) reloadModerationRules(ctx context.Context) error {
+	changed, err := cfg.moderation.Reload(ctx)
+	if changed {
+		log.Printf("Reloaded the moderation rules\n")
+	}
+	return err
+}
+
+// chirpFacts answers the moderation rules' questions about a chirp being
+// posted.
+type chirpFacts struct {
+	cfg    *apiConfig
+	userID uuid.UUID
+	now    time.Time
+}
+
+func (f chirpFacts) AccountAge(ctx context.Context) (time.Duration, error) {
+	user, err := f.cfg.dbQueries.GetUserByID(ctx, f.userID)
+	if err != nil {
+		return 0, err
+	}
+	return f.now.Sub(user.CreatedAt), nil
+}
+
+func (f chirpFacts) RecentRepeats(ctx context.Context, body string, window time.Duration) (int, error) {
+	count, err := f.cfg.dbQueries.CountRecentChirpsWithBodyHash(ctx, database.CountRecentChirpsWithBodyHashParams{
+		UserID:    f.userID,
+		BodyHash:  chirpBodyHash(body),
+		CreatedAt: f.now.Add(-window),
+	})
+	return int(count), err
+}
+
+// chirpBodyHash identifies a chirp as written. It is stored next to the body,
+// which moderation may have masked, so repeat rules still find copies of a
+// chirp after masking changed them.
+func chirpBodyHash(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+// moderateChirp runs the moderation rules over a chirp about to be posted.
+// Dry-run matches are only logged; anything stronger than a mask goes to the
+// audit log.
+func (cfg *apiConfig) moderateChirp(req *http.Request, userID uuid.UUID, body string) (moderation.Decision, error) {
+	decision, err := cfg.moderation.Evaluate(req.Context(), body, chirpFacts{cfg: cfg, userID: userID, now: time.Now()})
+	if err != nil {
+		return moderation.Decision{}, err
+	}
+
+	for _, match := range decision.DryRun {
+		log.Printf("Moderation dry run: rule %s would %s a chirp by %s\n", match.Rule, match.Action, userID)
+	}
+	var action string
+	switch decision.Action {
+	case moderation.ActionHold:
+		action = "chirp.held"
+	case moderation.ActionShadowHide:
+		action = "chirp.shadow_hidden"
+	case moderation.ActionReject:
+		action = "chirp.rejected"
+	}
+	if action != "" {
+		cfg.recordAudit(req.Context(), req, auditEntry{
+			ActorID:    actor(userID),
+			Action:     action,
+			TargetType: "user",
+			TargetID:   userID.String(),
+			Details:    map[string]any{"rules": decision.Matched, "body": body},
+		})
+	}
+	return decision, nil
+}
+
+// chirpStatusFor is the status a chirp is stored with after decision.
+func chirpStatusFor(decision moderation.Decision) string {
+	switch decision.Action {
+	case moderation.ActionHold:
+		return chirpStatusHeld
+	case moderation.ActionShadowHide:
+		return chirpStatusHidden
+	default:
+		return chirpStatusPublished
+	}
+}
+
+// ListHeldChirps returns the chirps the moderation rules held for review,
+// oldest first.
+func (cfg *apiConfig) ListHeldChirps(w http.ResponseWriter, req *http.Request) {
+	limit := defaultHeldChirpPageSize
+	if value := req.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxHeldChirpPageSize {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxHeldChirpPageSize))
+			return
+		}
+		limit = parsed
+	}
+	offset := 0
+	if value := req.URL.Query().Get("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			respondWithError(w, http.StatusBadRequest, "offset must not be negative")
+			return
+		}
+		offset = parsed
+	}
+
+	chirps, err := cfg.dbQueries.ListChirpsByStatus(req.Context(), database.ListChirpsByStatusParams{
+		Status: chirpStatusHeld,
+		Limit:  int32(limit),
+		Offset: int32(offset),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	responseJson := make([]Chirp, 0, len(chirps))
+	for _, chirp := range chirps {
+		responseJson = append(responseJson, toChirp(chirp))
+	}
+	respondWithJSON(w, http.StatusOK, responseJson)
+}
+
+// ApproveHeldChirp publishes a chirp that was held for review.
+func (cfg *apiConfig) ApproveHeldChirp(w http.ResponseWriter, req *http.Request) {
+	caller := principalFromContext(req.Context())
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID format")
+		return
+	}
+
+	approved, err := cfg.dbQueries.SetChirpStatus(req.Context(), database.SetChirpStatusParams{
+		Status:         chirpStatusPublished,
+		UpdatedAt:      time.Now(),
+		ID:             chirpID,
+		PreviousStatus: chirpStatusHeld,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if approved == 0 {
+		respondWithError(w, http.StatusNotFound, "No held chirp with that ID")
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(caller.UserID),
+		Action:     "chirp.approved",
+		TargetType: "chirp",
+		TargetID:   chirpID.String(),
+		Before:     map[string]string{"status": chirpStatusHeld},
+		After:      map[string]string{"status": chirpStatusPublished},
+	})
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+// RejectHeldChirp deletes a chirp that was held for review.
+func (cfg *apiConfig) RejectHeldChirp(w http.ResponseWriter, req *http.Request) {
+	caller := principalFromContext(req.Context())
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID format")
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteChirpWithStatus(req.Context(), database.DeleteChirpWithStatusParams{
+		ID:     chirpID,
+		Status: chirpStatusHeld,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "No held chirp with that ID")
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(caller.UserID),
+		Action:     "chirp.removed",
+		TargetType: "chirp",
+		TargetID:   chirpID.String(),
+		Before:     map[string]string{"status": chirpStatusHeld},
+	})
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+// ownChirp looks a chirp up for its author, who can see it whatever its
+// status. Anyone else gets sql.ErrNoRows.
+func (cfg *apiConfig) ownChirp(ctx context.Context, caller principal, chirpID uuid.UUID) (database.Chirp, error) {
+	return cfg.dbQueries.GetOwnChirpByID(ctx, database.GetOwnChirpByIDParams{
+		ID:     chirpID,
+		UserID: caller.UserID,
+	})
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	auth "github.com/SergioFloresCorrea/Chirpy/internal"
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/SergioFloresCorrea/Chirpy/internal/export"
+	"github.com/google/uuid"
+)
+
+const dataExportTTL = 7 * 24 * time.Hour
+
+type DataExport struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	DownloadURL string     `json:"download_url,omitempty"`
+}
+
+func toDataExport(dataExport database.DataExport) DataExport {
+	response := DataExport{
+		ID:        dataExport.ID,
+		CreatedAt: dataExport.CreatedAt,
+		Status:    dataExport.Status,
+		Error:     dataExport.Error,
+		ExpiresAt: dataExport.ExpiresAt,
+	}
+	if dataExport.CompletedAt.Valid {
+		response.CompletedAt = &dataExport.CompletedAt.Time
+	}
+	if dataExport.Status == "ready" {
+		response.DownloadURL = fmt.Sprintf("/api/users/me/export/%s?download=true", dataExport.ID)
+	}
+	return response
+}
+
+// RequestDataExport starts building an archive of the caller's data in the
+// background and answers right away with its id.
+func (cfg *apiConfig) RequestDataExport(w http.ResponseWriter, req *http.Request) {
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	params := database.CreateDataExportParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(dataExportTTL),
+	}
+	dataExport, err := cfg.dbQueries.CreateDataExport(req.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	go cfg.buildDataExport(context.Background(), dataExport)
+
+	respondWithJSON(w, http.StatusAccepted, toDataExport(dataExport))
+}
+
+// GetDataExport reports the status of an export, or streams the archive when
+// called with ?download=true. Exports of other users look like missing ones.
+func (cfg *apiConfig) GetDataExport(w http.ResponseWriter, req *http.Request) {
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	exportID, err := uuid.Parse(req.PathValue("exportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid export ID format")
+		return
+	}
+
+	dataExport, err := cfg.dbQueries.GetDataExport(req.Context(), exportID)
+	if err != nil || dataExport.UserID != userID || dataExport.ExpiresAt.Before(time.Now()) {
+		respondWithError(w, http.StatusNotFound, "Export not found")
+		return
+	}
+
+	if req.URL.Query().Get("download") != "true" {
+		respondWithJSON(w, http.StatusOK, toDataExport(dataExport))
+		return
+	}
+
+	if dataExport.Status != "ready" {
+		respondWithError(w, http.StatusConflict, "Export is not ready yet")
+		return
+	}
+
+	file, err := os.Open(dataExport.FilePath)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Export not found")
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"chirpy-export-%s.zip\"", dataExport.ID))
+	http.ServeContent(w, req, "", dataExport.CompletedAt.Time, file)
+}
+
+func (cfg *apiConfig) buildDataExport(ctx context.Context, dataExport database.DataExport) {
+	filePath, err := cfg.writeDataExport(ctx, dataExport)
+	if err != nil {
+		log.Printf("Couldn't build data export %s: %v\n", dataExport.ID, err)
+		err = cfg.dbQueries.MarkDataExportFailed(ctx, database.MarkDataExportFailedParams{
+			Error:       "The archive could not be built. Please request a new export.",
+			CompletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			ID:          dataExport.ID,
+		})
+		if err != nil {
+			log.Printf("Couldn't mark data export %s as failed: %v\n", dataExport.ID, err)
+		}
+		return
+	}
+
+	err = cfg.dbQueries.MarkDataExportReady(ctx, database.MarkDataExportReadyParams{
+		FilePath:    filePath,
+		CompletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		ID:          dataExport.ID,
+	})
+	if err != nil {
+		log.Printf("Couldn't mark data export %s as ready: %v\n", dataExport.ID, err)
+	}
+}
+
+func (cfg *apiConfig) writeDataExport(ctx context.Context, dataExport database.DataExport) (string, error) {
+	datasets, err := cfg.collectUserData(ctx, dataExport.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(cfg.exportDir, 0o700); err != nil {
+		return "", err
+	}
+	filePath := filepath.Join(cfg.exportDir, dataExport.ID.String()+".zip")
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if err := export.WriteArchive(file, datasets); err != nil {
+		os.Remove(filePath)
+		return "", err
+	}
+	return filePath, file.Close()
+}
+
+// collectUserData gathers everything Chirpy stores about a user. Secrets such
+// as the password hash and the refresh tokens themselves are left out.
+func (cfg *apiConfig) collectUserData(ctx context.Context, userID uuid.UUID) ([]export.Dataset, error) {
+	type profile struct {
+		ID              uuid.UUID  `json:"id"`
+		CreatedAt       time.Time  `json:"created_at"`
+		UpdatedAt       time.Time  `json:"updated_at"`
+		Email           string     `json:"email"`
+		EmailVerifiedAt *time.Time `json:"email_verified_at"`
+		IsChirpyRed     bool       `json:"is_chirpy_red"`
+	}
+
+	type login struct {
+		CreatedAt time.Time  `json:"created_at"`
+		ExpiresAt time.Time  `json:"expires_at"`
+		RevokedAt *time.Time `json:"revoked_at"`
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	chirps, err := cfg.dbQueries.GetOwnChirps(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	refreshTokens, err := cfg.dbQueries.GetRefreshTokensForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	userProfile := profile{
+		ID:              user.ID,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+		Email:           user.Email,
+		EmailVerifiedAt: nullTimePtr(user.EmailVerifiedAt),
+		IsChirpyRed:     user.IsChirpyRed,
+	}
+	profileDataset := export.Dataset{
+		Name:    "profile",
+		Records: userProfile,
+		Header:  []string{"id", "created_at", "updated_at", "email", "email_verified_at", "is_chirpy_red"},
+		Rows: [][]string{{
+			user.ID.String(),
+			formatTime(user.CreatedAt),
+			formatTime(user.UpdatedAt),
+			user.Email,
+			formatNullTime(user.EmailVerifiedAt),
+			strconv.FormatBool(user.IsChirpyRed),
+		}},
+	}
+
+	chirpRecords := make([]Chirp, 0, len(chirps))
+	chirpRows := make([][]string, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpRecords = append(chirpRecords, toChirp(chirp))
+		chirpRows = append(chirpRows, []string{chirp.ID.String(), formatTime(chirp.CreatedAt), formatTime(chirp.UpdatedAt), chirp.Body})
+	}
+	chirpDataset := export.Dataset{
+		Name:    "chirps",
+		Records: chirpRecords,
+		Header:  []string{"id", "created_at", "updated_at", "body"},
+		Rows:    chirpRows,
+	}
+
+	loginRecords := make([]login, 0, len(refreshTokens))
+	loginRows := make([][]string, 0, len(refreshTokens))
+	seenFamilies := map[uuid.UUID]bool{}
+	for _, refreshToken := range refreshTokens {
+		// Rotation adds a token to the family on every refresh; only the
+		// first one, oldest by creation, stands for the login itself.
+		if seenFamilies[refreshToken.FamilyID] {
+			continue
+		}
+		seenFamilies[refreshToken.FamilyID] = true
+		loginRecords = append(loginRecords, login{
+			CreatedAt: refreshToken.CreatedAt,
+			ExpiresAt: refreshToken.ExpiresAt,
+			RevokedAt: nullTimePtr(refreshToken.RevokedAt),
+		})
+		loginRows = append(loginRows, []string{formatTime(refreshToken.CreatedAt), formatTime(refreshToken.ExpiresAt), formatNullTime(refreshToken.RevokedAt)})
+	}
+	loginDataset := export.Dataset{
+		Name:    "login_history",
+		Records: loginRecords,
+		Header:  []string{"created_at", "expires_at", "revoked_at"},
+		Rows:    loginRows,
+	}
+
+	return []export.Dataset{profileDataset, chirpDataset, loginDataset}, nil
+}
+
+// purgeExpiredExports deletes export rows past their expiry and the archives
+// they point to.
+func (cfg *apiConfig) purgeExpiredExports(ctx context.Context) error {
+	filePaths, err := cfg.dbQueries.DeleteExpiredDataExports(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	removeExportFiles(filePaths)
+	return nil
+}
+
+// removeExportFiles deletes archives whose rows are gone. Exports that never
+// finished have no file.
+func removeExportFiles(filePaths []string) {
+	for _, filePath := range filePaths {
+		if filePath == "" {
+			continue
+		}
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			log.Printf("Couldn't remove export %s: %v\n", filePath, err)
+		}
+	}
+}
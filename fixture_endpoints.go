@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	auth "github.com/SergioFloresCorrea/Chirpy/internal"
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/SergioFloresCorrea/Chirpy/internal/fixtures"
+	"github.com/google/uuid"
+)
+
+// errFixturesLockout means a truncation would leave nobody able to run
+// fixtures again: bootstrapAdmin only grants the admin role at startup.
+var errFixturesLockout = errors.New("nobody would hold the " + auth.PermissionDataReset + " permission afterwards; load a dataset that grants it")
+
+// fixtureResult is what every fixtures endpoint responds with.
+type fixtureResult struct {
+	Truncated []string `json:"truncated"`
+	Dataset   string   `json:"dataset,omitempty"`
+	Users     int      `json:"users"`
+	Chirps    int      `json:"chirps"`
+}
+
+// ListFixtures returns the datasets that can be loaded.
+func (cfg *apiConfig) ListFixtures(w http.ResponseWriter, req *http.Request) {
+	type ResponseJson struct {
+		Datasets []string `json:"datasets"`
+	}
+
+	datasets, err := fixtures.Available(cfg.fixturesDir)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, ResponseJson{Datasets: datasets})
+}
+
+// ResetFixtures empties every table fixtures manage and seeds a dataset, all
+// or nothing. The dataset has to give someone data:reset, or nobody could
+// use the fixtures API again until a restart. The hit counter is left alone:
+// it is a Prometheus counter, which only goes back to zero when Chirpy
+// restarts.
+func (cfg *apiConfig) ResetFixtures(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		Dataset string `json:"dataset"`
+	}
+
+	expectedJson := ExpectedJson{}
+	if !hasNoBody(req) {
+		decoder := json.NewDecoder(req.Body)
+		if err := decoder.Decode(&expectedJson); err != nil {
+			respondWithError(w, 400, "Something went wrong")
+			return
+		}
+		defer req.Body.Close()
+	}
+
+	cfg.runFixtures(w, req, "fixtures.reset", fixtures.Tables, expectedJson.Dataset)
+}
+
+// TruncateFixtures empties the given tables, and whatever references them.
+func (cfg *apiConfig) TruncateFixtures(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		Tables []string `json:"tables"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+	if _, err := fixtures.TruncateStatement(expectedJson.Tables); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cfg.runFixtures(w, req, "fixtures.truncated", expectedJson.Tables, "")
+}
+
+// LoadFixtures seeds a dataset on top of what is already there.
+func (cfg *apiConfig) LoadFixtures(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		Dataset string `json:"dataset"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+	if expectedJson.Dataset == "" {
+		respondWithError(w, http.StatusBadRequest, "A dataset is required")
+		return
+	}
+
+	cfg.runFixtures(w, req, "fixtures.loaded", nil, expectedJson.Dataset)
+}
+
+// runFixtures truncates tables and then seeds dataset, each optional, in one
+// transaction, and reports the outcome.
+func (cfg *apiConfig) runFixtures(w http.ResponseWriter, req *http.Request, action string, tables []string, datasetName string) {
+	caller := principalFromContext(req.Context())
+	var dataset fixtures.Dataset
+	if datasetName != "" {
+		var err error
+		dataset, err = fixtures.Load(cfg.fixturesDir, datasetName)
+		if errors.Is(err, os.ErrNotExist) {
+			respondWithError(w, http.StatusNotFound, "No dataset called "+datasetName)
+			return
+		} else if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	err := cfg.applyFixtures(req.Context(), tables, dataset)
+	if errors.Is(err, fixtures.ErrNotAllowed) {
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	} else if errors.Is(err, errFixturesLockout) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	result := fixtureResult{
+		Truncated: tables,
+		Dataset:   datasetName,
+		Users:     len(dataset.Users),
+		Chirps:    len(dataset.Chirps),
+	}
+	if result.Truncated == nil {
+		result.Truncated = []string{}
+	}
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(caller.UserID),
+		Action:     action,
+		TargetType: "platform",
+		TargetID:   cfg.platform,
+		Details:    result,
+	})
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// applyFixtures is the only way fixtures reach the database. It checks the
+// platform itself, so that no route or caller can skip the guard. Export
+// archives whose rows a truncation took with it are removed from disk once
+// it commits. A truncation that would leave nobody holding data:reset is
+// refused with errFixturesLockout.
+func (cfg *apiConfig) applyFixtures(ctx context.Context, tables []string, dataset fixtures.Dataset) error {
+	if err := fixtures.Guard(cfg.platform); err != nil {
+		return err
+	}
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	var orphanedExports []string
+	if len(tables) > 0 {
+		statement, err := fixtures.TruncateStatement(tables)
+		if err != nil {
+			return err
+		}
+		exportsBefore, err := qtx.ListDataExportFiles(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+		exportsAfter, err := qtx.ListDataExportFiles(ctx)
+		if err != nil {
+			return err
+		}
+		for _, filePath := range exportsBefore {
+			if !slices.Contains(exportsAfter, filePath) {
+				orphanedExports = append(orphanedExports, filePath)
+			}
+		}
+	}
+	if err := cfg.seedDataset(ctx, qtx, dataset); err != nil {
+		return err
+	}
+	if len(tables) > 0 {
+		resetters, err := qtx.CountUsersWithPermission(ctx, auth.PermissionDataReset)
+		if err != nil {
+			return err
+		}
+		if resetters == 0 {
+			return errFixturesLockout
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	removeExportFiles(orphanedExports)
+	return nil
+}
+
+func (cfg *apiConfig) seedDataset(ctx context.Context, qtx *database.Queries, dataset fixtures.Dataset) error {
+	now := time.Now()
+	userIDs := make(map[string]uuid.UUID, len(dataset.Users))
+	for _, fixture := range dataset.Users {
+		hashedPassword, err := cfg.passwordHasher.Hash(fixture.Password)
+		if err != nil {
+			return err
+		}
+		user, err := qtx.CreateUser(ctx, database.CreateUserParams{
+			ID:             uuid.New(),
+			CreatedAt:      now,
+			UpdatedAt:      now,
+			Email:          fixture.Email,
+			HashedPassword: hashedPassword,
+		})
+		if err != nil {
+			return fmt.Errorf("user %s: %w", fixture.Email, err)
+		}
+		userIDs[fixture.Email] = user.ID
+
+		if fixture.IsChirpyRed {
+			if err := qtx.UpgradeUserToRedByID(ctx, user.ID); err != nil {
+				return fmt.Errorf("user %s: %w", fixture.Email, err)
+			}
+		}
+		if fixture.EmailVerified {
+			_, err := qtx.SetUserEmailVerified(ctx, database.SetUserEmailVerifiedParams{
+				Email:           user.Email,
+				EmailVerifiedAt: sql.NullTime{Time: now, Valid: true},
+				ID:              user.ID,
+			})
+			if err != nil {
+				return fmt.Errorf("user %s: %w", fixture.Email, err)
+			}
+		}
+		for _, role := range fixture.Roles {
+			_, err := qtx.GrantRole(ctx, database.GrantRoleParams{
+				UserID:    user.ID,
+				Role:      role,
+				GrantedAt: now,
+			})
+			if err != nil {
+				return fmt.Errorf("user %s: role %s: %w", fixture.Email, role, err)
+			}
+		}
+	}
+
+	for _, fixture := range dataset.Chirps {
+		_, err := qtx.CreateChirp(ctx, database.CreateChirpParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			Body:      fixture.Body,
+			UserID:    userIDs[fixture.Author],
+			Status:    chirpStatusPublished,
+			BodyHash:  chirpBodyHash(fixture.Body),
+		})
+		if err != nil {
+			return fmt.Errorf("chirp by %s: %w", fixture.Author, err)
+		}
+	}
+	return nil
+}
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
-	"slices"
-	"strings"
+	"time"
 
 	auth "github.com/SergioFloresCorrea/Chirpy/internal"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) error {
@@ -26,20 +29,6 @@ func respondWithError(w http.ResponseWriter, code int, msg string) error {
 	return respondWithJSON(w, code, map[string]string{"error": msg})
 }
 
-func replaceBadWords(body string) string {
-	badWords := []string{"kerfuffle", "sharbert", "fornax"}
-	cleanedBody := make([]string, 0, len(body))
-	words := strings.Split(body, " ")
-	for _, word := range words {
-		if slices.Contains(badWords, strings.ToLower(word)) {
-			cleanedBody = append(cleanedBody, "****")
-		} else {
-			cleanedBody = append(cleanedBody, word)
-		}
-	}
-	return strings.Join(cleanedBody, " ")
-}
-
 func hasNoBody(r *http.Request) bool {
 	// Check if Body is nil or ContentLength is zero or less
 	return r.Body == nil || r.ContentLength <= 0
@@ -53,3 +42,35 @@ func checkAuthHeader(r *http.Request) (string, error) {
 	}
 	return tokenString, nil
 }
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatNullTime(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return formatTime(t.Time)
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
+
+// isUniqueViolation reports whether err is Postgres refusing a row that
+// breaks a unique constraint.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,105 @@
+// Package auditlog holds the parts of reading the audit log that don't need
+// a database: parsing search filters, checking request IDs, and paging
+// through entries for an export.
+package auditlog
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Filters are the query parameters both the listing and the export accept.
+// Every filter is optional.
+type Filters struct {
+	ActorID    uuid.NullUUID
+	Action     sql.NullString
+	TargetType sql.NullString
+	TargetID   sql.NullString
+	RequestID  sql.NullString
+	Since      sql.NullTime
+	Until      sql.NullTime
+}
+
+// ParseFilters reads Filters from a query string. Timestamps are RFC 3339.
+func ParseFilters(query url.Values) (Filters, error) {
+	var filters Filters
+	if value := query.Get("actor_id"); value != "" {
+		actorID, err := uuid.Parse(value)
+		if err != nil {
+			return Filters{}, errors.New("actor_id must be a UUID")
+		}
+		filters.ActorID = uuid.NullUUID{UUID: actorID, Valid: true}
+	}
+	optional := func(name string) sql.NullString {
+		value := query.Get(name)
+		return sql.NullString{String: value, Valid: value != ""}
+	}
+	filters.Action = optional("action")
+	filters.TargetType = optional("target_type")
+	filters.TargetID = optional("target_id")
+	filters.RequestID = optional("request_id")
+	for name, dest := range map[string]*sql.NullTime{"since": &filters.Since, "until": &filters.Until} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return Filters{}, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+		}
+		*dest = sql.NullTime{Time: parsed, Valid: true}
+	}
+	return filters, nil
+}
+
+// ValidRequestID reports whether a request ID sent by a client is safe to
+// keep: short, and made of characters that can't break a log line.
+func ValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > 128 {
+		return false
+	}
+	for _, r := range requestID {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// Cursor is the position of an entry in the order exports read the log in:
+// by creation time, then by ID to break ties.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// Walk pages through the log by keyset rather than by offset, so entries
+// written while it runs can't shift a page and make it skip or repeat rows.
+// fetch returns up to limit entries strictly after cursor, in cursor order,
+// or from the start when cursor is nil. Walk hands every entry to emit and
+// stops at the first batch shorter than batchSize, or at the first error.
+func Walk[T any](ctx context.Context, batchSize int, fetch func(ctx context.Context, after *Cursor, limit int) ([]T, error), cursor func(T) Cursor, emit func(T) error) error {
+	var after *Cursor
+	for {
+		entries, err := fetch(ctx, after, batchSize)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := emit(entry); err != nil {
+				return err
+			}
+		}
+		if len(entries) < batchSize {
+			return nil
+		}
+		last := cursor(entries[len(entries)-1])
+		after = &last
+	}
+}
@@ -0,0 +1,214 @@
+package auditlog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParseFilters(t *testing.T) {
+	actorID := uuid.New()
+	filters, err := ParseFilters(url.Values{
+		"actor_id":    {actorID.String()},
+		"action":      {"user.suspended"},
+		"target_type": {"user"},
+		"since":       {"2025-06-01T00:00:00Z"},
+		"until":       {"2025-07-01T12:30:00+02:00"},
+	})
+	if err != nil {
+		t.Fatalf("ParseFilters() returned error: %v", err)
+	}
+	if !filters.ActorID.Valid || filters.ActorID.UUID != actorID {
+		t.Errorf("ActorID = %v, want %v", filters.ActorID, actorID)
+	}
+	if filters.Action.String != "user.suspended" || !filters.Action.Valid || filters.TargetType.String != "user" {
+		t.Errorf("Action, TargetType = %v, %v", filters.Action, filters.TargetType)
+	}
+	if filters.TargetID.Valid || filters.RequestID.Valid {
+		t.Errorf("Filters that weren't given should be null, got %v and %v", filters.TargetID, filters.RequestID)
+	}
+	if want := time.Date(2025, 7, 1, 10, 30, 0, 0, time.UTC); !filters.Until.Valid || !filters.Until.Time.Equal(want) {
+		t.Errorf("Until = %v, want %v", filters.Until, want)
+	}
+
+	if filters, err := ParseFilters(url.Values{}); err != nil || filters != (Filters{}) {
+		t.Errorf("ParseFilters() with no filters = %+v, %v", filters, err)
+	}
+
+	for name, query := range map[string]url.Values{
+		"bad actor":    {"actor_id": {"42"}},
+		"bad since":    {"since": {"yesterday"}},
+		"date only":    {"until": {"2025-07-01"}},
+		"unix time":    {"since": {"1719792000"}},
+		"good and bad": {"actor_id": {actorID.String()}, "until": {"soon"}},
+	} {
+		if _, err := ParseFilters(query); err == nil {
+			t.Errorf("%s: ParseFilters() should return error, but got none", name)
+		}
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	for _, requestID := range []string{uuid.NewString(), "req-42", "a.b_c-D", strings.Repeat("a", 128)} {
+		if !ValidRequestID(requestID) {
+			t.Errorf("ValidRequestID(%q) = false, want true", requestID)
+		}
+	}
+	for _, requestID := range []string{"", strings.Repeat("a", 129), "two words", "line\nbreak", `quo"te`, "ünïcode", "a/b"} {
+		if ValidRequestID(requestID) {
+			t.Errorf("ValidRequestID(%q) = true, want false", requestID)
+		}
+	}
+}
+
+type entry struct {
+	cursor Cursor
+}
+
+// fakeLog answers fetches the way the export query does: entries strictly
+// after the cursor, ordered by creation time and then ID.
+type fakeLog struct {
+	entries []entry
+	fetches int
+	// onFetch runs before each fetch, to write entries mid-export.
+	onFetch func(fetch int)
+}
+
+func less(a, b Cursor) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return bytes.Compare(a.ID[:], b.ID[:]) < 0
+}
+
+func (l *fakeLog) add(createdAt time.Time) {
+	l.entries = append(l.entries, entry{Cursor{CreatedAt: createdAt, ID: uuid.New()}})
+}
+
+func (l *fakeLog) fetch(ctx context.Context, after *Cursor, limit int) ([]entry, error) {
+	if l.onFetch != nil {
+		l.onFetch(l.fetches)
+	}
+	l.fetches++
+	sorted := slices.Clone(l.entries)
+	sort.Slice(sorted, func(i, j int) bool { return less(sorted[i].cursor, sorted[j].cursor) })
+	var page []entry
+	for _, e := range sorted {
+		if after != nil && !less(*after, e.cursor) {
+			continue
+		}
+		if len(page) == limit {
+			break
+		}
+		page = append(page, e)
+	}
+	return page, nil
+}
+
+func walk(t *testing.T, l *fakeLog, batchSize int) []entry {
+	t.Helper()
+	var got []entry
+	err := Walk(context.Background(), batchSize, l.fetch, func(e entry) Cursor { return e.cursor }, func(e entry) error {
+		got = append(got, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk() returned error: %v", err)
+	}
+	return got
+}
+
+func checkEachOnce(t *testing.T, l *fakeLog, got []entry) {
+	t.Helper()
+	seen := make(map[uuid.UUID]int)
+	for _, e := range got {
+		seen[e.cursor.ID]++
+	}
+	for _, e := range l.entries {
+		if seen[e.cursor.ID] != 1 {
+			t.Errorf("entry %v was exported %d times, want once", e.cursor, seen[e.cursor.ID])
+		}
+	}
+	if len(got) != len(l.entries) {
+		t.Errorf("exported %d entries, want %d", len(got), len(l.entries))
+	}
+	for i := 1; i < len(got); i++ {
+		if !less(got[i-1].cursor, got[i].cursor) {
+			t.Errorf("entries %d and %d are out of order", i-1, i)
+		}
+	}
+}
+
+func TestWalkExportsEveryEntryOnce(t *testing.T) {
+	start := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	for _, size := range []int{0, 1, 2, 3, 4, 5, 6, 7, 12} {
+		l := &fakeLog{}
+		for i := 0; i < size; i++ {
+			// Pairs of entries share a timestamp, so pages often end in the
+			// middle of a tie.
+			l.add(start.Add(time.Duration(i/2) * time.Microsecond))
+		}
+		got := walk(t, l, 3)
+		checkEachOnce(t, l, got)
+		if want := size/3 + 1; l.fetches != want {
+			t.Errorf("%d entries: fetched %d times, want %d", size, l.fetches, want)
+		}
+	}
+}
+
+func TestWalkWithEntriesWrittenMidExport(t *testing.T) {
+	start := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	l := &fakeLog{}
+	for i := 0; i < 5; i++ {
+		l.add(start.Add(time.Duration(i) * time.Second))
+	}
+	l.onFetch = func(fetch int) {
+		if fetch == 1 {
+			// Entries written between two pages land after everything read
+			// so far; an offset would now point one entry too early.
+			l.add(start.Add(time.Minute))
+			l.add(start.Add(time.Minute))
+		}
+	}
+
+	got := walk(t, l, 2)
+	checkEachOnce(t, l, got)
+}
+
+func TestWalkStopsOnError(t *testing.T) {
+	l := &fakeLog{}
+	for i := 0; i < 4; i++ {
+		l.add(time.Now())
+	}
+	failure := errors.New("client went away")
+	emitted := 0
+	err := Walk(context.Background(), 2, l.fetch, func(e entry) Cursor { return e.cursor }, func(e entry) error {
+		emitted++
+		if emitted == 3 {
+			return failure
+		}
+		return nil
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("Walk() = %v, want the emit error", err)
+	}
+	if emitted != 3 || l.fetches != 2 {
+		t.Errorf("emitted %d entries in %d fetches after the error, want 3 in 2", emitted, l.fetches)
+	}
+
+	fetchErr := errors.New("connection reset")
+	err = Walk(context.Background(), 2, func(context.Context, *Cursor, int) ([]entry, error) {
+		return nil, fetchErr
+	}, func(e entry) Cursor { return e.cursor }, func(entry) error { return nil })
+	if !errors.Is(err, fetchErr) {
+		t.Errorf("Walk() = %v, want the fetch error", err)
+	}
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// TokenPolicy lists what every access token must carry to be accepted.
+type TokenPolicy struct {
+	Issuer   string
+	Audience string
+	// Leeway absorbs clock skew between the signer and the verifier when
+	// checking exp, nbf and iat.
+	Leeway time.Duration
+}
+
+// DefaultTokenPolicy allows no clock skew; servers that verify tokens minted
+// elsewhere should set a Leeway.
+var DefaultTokenPolicy = TokenPolicy{
+	Issuer:   "chirpy",
+	Audience: "chirpy-api",
+}
+
+// Claims is the validated content of an access token.
+type Claims struct {
+	UserID uuid.UUID
+	// SessionID names the login the token was issued for; it is uuid.Nil for
+	// tokens that don't belong to a session.
+	SessionID uuid.UUID
+	// ClientID is the OAuth client the token was issued to, or empty for
+	// first-party logins.
+	ClientID string
+	Scopes   []string
+	// Roles are only carried by tokens from an interactive login, so neither
+	// personal access tokens nor OAuth clients can use the admin API.
+	Roles       []string
+	IsChirpyRed bool
+	// ExpiresAt is filled in by validation and ignored when signing.
+	ExpiresAt time.Time
+}
+
+// jwtClaims is the wire format of Claims.
+type jwtClaims struct {
+	jwt.RegisteredClaims
+	SessionID   string   `json:"sid,omitempty"`
+	ClientID    string   `json:"client_id,omitempty"`
+	Scopes      []string `json:"scopes,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	IsChirpyRed bool     `json:"is_chirpy_red"`
+}
+
+func newJWTClaims(claims Claims, policy TokenPolicy, expiresIn time.Duration) jwtClaims {
+	now := time.Now()
+	wire := jwtClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    policy.Issuer,
+			Subject:   claims.UserID.String(),
+			Audience:  jwt.ClaimStrings{policy.Audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        uuid.NewString(),
+		},
+		ClientID:    claims.ClientID,
+		Scopes:      claims.Scopes,
+		Roles:       claims.Roles,
+		IsChirpyRed: claims.IsChirpyRed,
+	}
+	if claims.SessionID != uuid.Nil {
+		wire.SessionID = claims.SessionID.String()
+	}
+	return wire
+}
+
+// parseJWT verifies tokenString against keyfunc, accepting only the listed
+// algorithms, and checks the registered claims against policy.
+func parseJWT(tokenString string, keyfunc jwt.Keyfunc, algorithms []string, policy TokenPolicy) (Claims, error) {
+	wire := jwtClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, &wire, keyfunc,
+		jwt.WithValidMethods(algorithms),
+		jwt.WithIssuer(policy.Issuer),
+		jwt.WithAudience(policy.Audience),
+		jwt.WithLeeway(policy.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	userID, err := uuid.Parse(wire.Subject)
+	if err != nil {
+		return Claims{}, fmt.Errorf("invalid subject: %w", err)
+	}
+	claims := Claims{
+		UserID:      userID,
+		ClientID:    wire.ClientID,
+		Scopes:      wire.Scopes,
+		Roles:       wire.Roles,
+		IsChirpyRed: wire.IsChirpyRed,
+		ExpiresAt:   wire.ExpiresAt.Time,
+	}
+	if wire.SessionID != "" {
+		claims.SessionID, err = uuid.Parse(wire.SessionID)
+		if err != nil {
+			return Claims{}, fmt.Errorf("invalid session id: %w", err)
+		}
+	}
+	return claims, nil
+}
@@ -12,16 +12,36 @@ import (
 	"github.com/google/uuid"
 )
 
+const countRecentChirpsWithBodyHash = `-- name: CountRecentChirpsWithBodyHash :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND body_hash = $2 AND created_at > $3
+`
+
+type CountRecentChirpsWithBodyHashParams struct {
+	UserID    uuid.UUID
+	BodyHash  string
+	CreatedAt time.Time
+}
+
+func (q *Queries) CountRecentChirpsWithBodyHash(ctx context.Context, arg CountRecentChirpsWithBodyHashParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countRecentChirpsWithBodyHash, arg.UserID, arg.BodyHash, arg.CreatedAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, status, body_hash)
 VALUES (
 	$1,
 	$2,
 	$3,
 	$4,
-	$5
+	$5,
+	$6,
+	$7
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, status, body_hash
 `
 
 type CreateChirpParams struct {
@@ -30,6 +50,8 @@ type CreateChirpParams struct {
 	UpdatedAt time.Time
 	Body      string
 	UserID    uuid.UUID
+	Status    string
+	BodyHash  string
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
@@ -39,6 +61,8 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		arg.UpdatedAt,
 		arg.Body,
 		arg.UserID,
+		arg.Status,
+		arg.BodyHash,
 	)
 	var i Chirp
 	err := row.Scan(
@@ -47,6 +71,8 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Status,
+		&i.BodyHash,
 	)
 	return i, err
 }
@@ -61,9 +87,35 @@ func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const deleteChirpWithStatus = `-- name: DeleteChirpWithStatus :execrows
+DELETE FROM chirps
+WHERE id = $1 AND status = $2
+`
+
+type DeleteChirpWithStatusParams struct {
+	ID     uuid.UUID
+	Status string
+}
+
+func (q *Queries) DeleteChirpWithStatus(ctx context.Context, arg DeleteChirpWithStatusParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteChirpWithStatus, arg.ID, arg.Status)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE id = $1
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.status, chirps.body_hash FROM chirps
+INNER JOIN users
+ON users.id = chirps.user_id
+WHERE chirps.id = $1 AND users.scheduled_deletion_at IS NULL AND chirps.status = 'published'
+	AND NOT EXISTS (
+		SELECT 1 FROM user_suspensions
+		WHERE user_suspensions.user_id = chirps.user_id
+			AND user_suspensions.lifted_at IS NULL
+			AND (user_suspensions.expires_at IS NULL OR user_suspensions.expires_at > NOW())
+	)
 LIMIT 1
 `
 
@@ -76,17 +128,28 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Status,
+		&i.BodyHash,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-ORDER BY created_at
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.status, chirps.body_hash FROM chirps
+INNER JOIN users
+ON users.id = chirps.user_id
+WHERE users.scheduled_deletion_at IS NULL AND (chirps.status = 'published' OR chirps.user_id = $1)
+	AND NOT EXISTS (
+		SELECT 1 FROM user_suspensions
+		WHERE user_suspensions.user_id = chirps.user_id
+			AND user_suspensions.lifted_at IS NULL
+			AND (user_suspensions.expires_at IS NULL OR user_suspensions.expires_at > NOW())
+	)
+ORDER BY chirps.created_at
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+func (q *Queries) GetChirps(ctx context.Context, viewerID uuid.NullUUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -100,6 +163,8 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Status,
+			&i.BodyHash,
 		); err != nil {
 			return nil, err
 		}
@@ -115,8 +180,16 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByUserID = `-- name: GetChirpsByUserID :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE user_id = $1
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.status, chirps.body_hash FROM chirps
+INNER JOIN users
+ON users.id = chirps.user_id
+WHERE chirps.user_id = $1 AND users.scheduled_deletion_at IS NULL AND chirps.status = 'published'
+	AND NOT EXISTS (
+		SELECT 1 FROM user_suspensions
+		WHERE user_suspensions.user_id = chirps.user_id
+			AND user_suspensions.lifted_at IS NULL
+			AND (user_suspensions.expires_at IS NULL OR user_suspensions.expires_at > NOW())
+	)
 `
 
 func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
@@ -134,6 +207,8 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Status,
+			&i.BodyHash,
 		); err != nil {
 			return nil, err
 		}
@@ -147,3 +222,136 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 	}
 	return items, nil
 }
+
+const getOwnChirpByID = `-- name: GetOwnChirpByID :one
+SELECT id, created_at, updated_at, body, user_id, status, body_hash FROM chirps
+WHERE id = $1 AND user_id = $2
+LIMIT 1
+`
+
+type GetOwnChirpByIDParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetOwnChirpByID(ctx context.Context, arg GetOwnChirpByIDParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getOwnChirpByID, arg.ID, arg.UserID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.BodyHash,
+	)
+	return i, err
+}
+
+const getOwnChirps = `-- name: GetOwnChirps :many
+SELECT id, created_at, updated_at, body, user_id, status, body_hash FROM chirps
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) GetOwnChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getOwnChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.BodyHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsByStatus = `-- name: ListChirpsByStatus :many
+SELECT id, created_at, updated_at, body, user_id, status, body_hash FROM chirps
+WHERE status = $1
+ORDER BY created_at
+LIMIT $2 OFFSET $3
+`
+
+type ListChirpsByStatusParams struct {
+	Status string
+	Limit  int32
+	Offset int32
+}
+
+func (q *Queries) ListChirpsByStatus(ctx context.Context, arg ListChirpsByStatusParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsByStatus, arg.Status, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.BodyHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setChirpStatus = `-- name: SetChirpStatus :execrows
+UPDATE chirps
+SET status = $1, updated_at = $2
+WHERE id = $3 AND status = $4
+`
+
+type SetChirpStatusParams struct {
+	Status         string
+	UpdatedAt      time.Time
+	ID             uuid.UUID
+	PreviousStatus string
+}
+
+func (q *Queries) SetChirpStatus(ctx context.Context, arg SetChirpStatusParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setChirpStatus,
+		arg.Status,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.PreviousStatus,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -6,33 +6,269 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AuditLog struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	ActorID    uuid.NullUUID
+	Action     string
+	TargetType string
+	TargetID   string
+	Ip         string
+	Details    json.RawMessage
+	Before     json.RawMessage
+	After      json.RawMessage
+	RequestID  string
+}
+
 type Chirp struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Body      string
 	UserID    uuid.UUID
+	Status    string
+	BodyHash  string
 }
 
-type RefreshToken struct {
-	Token     string
+type DataExport struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	Status      string
+	FilePath    string
+	Error       string
+	CompletedAt sql.NullTime
+	ExpiresAt   time.Time
+}
+
+type EmailVerificationToken struct {
+	ID        uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
 	UserID    uuid.UUID
+	Email     string
 	ExpiresAt time.Time
-	RevokedAt sql.NullTime
+	UsedAt    sql.NullTime
+}
+
+type LoginAttempt struct {
+	Key           string
+	Failures      int32
+	LastFailureAt time.Time
+}
+
+type MfaChallenge struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	DeviceName string
+	ExpiresAt  time.Time
+	UsedAt     sql.NullTime
+	Attempts   int32
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash      string
+	CreatedAt     time.Time
+	ClientID      string
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scopes        []string
+	CodeChallenge string
+	FamilyID      uuid.UUID
+	ExpiresAt     time.Time
+	UsedAt        sql.NullTime
+}
+
+type OauthClient struct {
+	ID           string
+	CreatedAt    time.Time
+	OwnerID      uuid.UUID
+	Name         string
+	SecretHash   sql.NullString
+	RedirectUris []string
+	Scopes       []string
+}
+
+type OidcLoginState struct {
+	State        string
+	CreatedAt    time.Time
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	DeviceName   string
+	ExpiresAt    time.Time
+}
+
+type PasswordResetToken struct {
+	TokenHash string
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scopes     []string
+	ExpiresAt  time.Time
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	CodeHash  string
+	UsedAt    sql.NullTime
+}
+
+type RefreshToken struct {
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
+	FamilyID   uuid.UUID
+	RotatedAt  sql.NullTime
+	TokenHash  string
+	UserAgent  string
+	Ip         string
+	DeviceName string
+	LastUsedAt time.Time
+	ClientID   sql.NullString
+	Scopes     []string
+}
+
+type Report struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	TargetType       string
+	TargetID         uuid.UUID
+	TargetUserID     uuid.UUID
+	ChirpBody        sql.NullString
+	ReportCount      int32
+	Status           string
+	ClaimedBy        uuid.NullUUID
+	ClaimedAt        sql.NullTime
+	ResolvedBy       uuid.NullUUID
+	ResolvedAt       sql.NullTime
+	Resolution       sql.NullString
+	ResolutionReason string
+}
+
+type ReportNote struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	ReportID  uuid.UUID
+	AuthorID  uuid.NullUUID
+	Body      string
+}
+
+type ReportSubmission struct {
+	ReportID   uuid.UUID
+	ReporterID uuid.UUID
+	CreatedAt  time.Time
+	Category   string
+	Comment    string
+}
+
+type Role struct {
+	Name        string
+	CreatedAt   time.Time
+	Description string
+}
+
+type RolePermission struct {
+	Role       string
+	Permission string
 }
 
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Email               string
+	HashedPassword      string
+	IsChirpyRed         bool
+	EmailVerifiedAt     sql.NullTime
+	ScheduledDeletionAt sql.NullTime
+}
+
+type UserIdentity struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Provider  string
+	Subject   string
+	Email     string
+}
+
+type UserRole struct {
+	UserID    uuid.UUID
+	Role      string
+	GrantedAt time.Time
+	GrantedBy uuid.NullUUID
+}
+
+type UserSuspension struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UserID      uuid.UUID
+	SuspendedBy uuid.NullUUID
+	Reason      string
+	ExpiresAt   sql.NullTime
+	LiftedAt    sql.NullTime
+	LiftedBy    uuid.NullUUID
+}
+
+type UserTotp struct {
+	UserID       uuid.UUID
+	CreatedAt    time.Time
+	Secret       string
+	ConfirmedAt  sql.NullTime
+	LastUsedStep int64
+}
+
+type UserWarning struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	IssuedBy  uuid.NullUUID
+	ReportID  uuid.NullUUID
+	Reason    string
+}
+
+type WebauthnChallenge struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.NullUUID
+	Ceremony   string
+	Challenge  string
+	DeviceName string
+	ExpiresAt  time.Time
+}
+
+type WebauthnCredential struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	Name         string
+	CredentialID []byte
+	PublicKey    []byte
+	SignCount    int64
+	Transports   []string
+	LastUsedAt   sql.NullTime
 }
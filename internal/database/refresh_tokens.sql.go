@@ -11,81 +11,326 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createRefreshToken = `-- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens(token, created_at, updated_at, user_id, expires_at)
+INSERT INTO refresh_tokens(token_hash, created_at, updated_at, user_id, expires_at, family_id, user_agent, ip, device_name, last_used_at, client_id, scopes)
 VALUES(
 	$1,
 	$2,
 	$3,
 	$4,
-	$5
+	$5,
+	$6,
+	$7,
+	$8,
+	$9,
+	$10,
+	$11,
+	$12
 )
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+RETURNING created_at, updated_at, user_id, expires_at, revoked_at, family_id, rotated_at, token_hash, user_agent, ip, device_name, last_used_at, client_id, scopes
 `
 
 type CreateRefreshTokenParams struct {
-	Token     string
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	UserID    uuid.UUID
-	ExpiresAt time.Time
+	TokenHash  string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	FamilyID   uuid.UUID
+	UserAgent  string
+	Ip         string
+	DeviceName string
+	LastUsedAt time.Time
+	ClientID   sql.NullString
+	Scopes     []string
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
 	row := q.db.QueryRowContext(ctx, createRefreshToken,
-		arg.Token,
+		arg.TokenHash,
 		arg.CreatedAt,
 		arg.UpdatedAt,
 		arg.UserID,
 		arg.ExpiresAt,
+		arg.FamilyID,
+		arg.UserAgent,
+		arg.Ip,
+		arg.DeviceName,
+		arg.LastUsedAt,
+		arg.ClientID,
+		pq.Array(arg.Scopes),
 	)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.RotatedAt,
+		&i.TokenHash,
+		&i.UserAgent,
+		&i.Ip,
+		&i.DeviceName,
+		&i.LastUsedAt,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
 	)
 	return i, err
 }
 
+const getActiveSessionsForUser = `-- name: GetActiveSessionsForUser :many
+SELECT refresh_tokens.created_at, refresh_tokens.updated_at, refresh_tokens.user_id, refresh_tokens.expires_at, refresh_tokens.revoked_at, refresh_tokens.family_id, refresh_tokens.rotated_at, refresh_tokens.token_hash, refresh_tokens.user_agent, refresh_tokens.ip, refresh_tokens.device_name, refresh_tokens.last_used_at, refresh_tokens.client_id, refresh_tokens.scopes, (
+	SELECT MIN(family.created_at) FROM refresh_tokens AS family
+	WHERE family.family_id = refresh_tokens.family_id
+)::TIMESTAMP AS signed_in_at
+FROM refresh_tokens
+WHERE refresh_tokens.user_id = $1
+AND refresh_tokens.revoked_at IS NULL
+AND refresh_tokens.rotated_at IS NULL
+AND refresh_tokens.expires_at > $2
+ORDER BY refresh_tokens.last_used_at DESC
+`
+
+type GetActiveSessionsForUserParams struct {
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+}
+
+type GetActiveSessionsForUserRow struct {
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
+	FamilyID   uuid.UUID
+	RotatedAt  sql.NullTime
+	TokenHash  string
+	UserAgent  string
+	Ip         string
+	DeviceName string
+	LastUsedAt time.Time
+	ClientID   sql.NullString
+	Scopes     []string
+	SignedInAt time.Time
+}
+
+func (q *Queries) GetActiveSessionsForUser(ctx context.Context, arg GetActiveSessionsForUserParams) ([]GetActiveSessionsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getActiveSessionsForUser, arg.UserID, arg.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetActiveSessionsForUserRow
+	for rows.Next() {
+		var i GetActiveSessionsForUserRow
+		if err := rows.Scan(
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.FamilyID,
+			&i.RotatedAt,
+			&i.TokenHash,
+			&i.UserAgent,
+			&i.Ip,
+			&i.DeviceName,
+			&i.LastUsedAt,
+			&i.ClientID,
+			pq.Array(&i.Scopes),
+			&i.SignedInAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getRefreshTokenByToken = `-- name: GetRefreshTokenByToken :one
-SELECT token, created_at, updated_at, user_id, expires_at, revoked_at FROM refresh_tokens
-WHERE token=$1
+SELECT created_at, updated_at, user_id, expires_at, revoked_at, family_id, rotated_at, token_hash, user_agent, ip, device_name, last_used_at, client_id, scopes FROM refresh_tokens
+WHERE token_hash=$1
 LIMIT 1
 `
 
-func (q *Queries) GetRefreshTokenByToken(ctx context.Context, token string) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, getRefreshTokenByToken, token)
+func (q *Queries) GetRefreshTokenByToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRefreshTokenByToken, tokenHash)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.RotatedAt,
+		&i.TokenHash,
+		&i.UserAgent,
+		&i.Ip,
+		&i.DeviceName,
+		&i.LastUsedAt,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
 	)
 	return i, err
 }
 
+const getRefreshTokensForUser = `-- name: GetRefreshTokensForUser :many
+SELECT created_at, updated_at, user_id, expires_at, revoked_at, family_id, rotated_at, token_hash, user_agent, ip, device_name, last_used_at, client_id, scopes FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) GetRefreshTokensForUser(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error) {
+	rows, err := q.db.QueryContext(ctx, getRefreshTokensForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RefreshToken
+	for rows.Next() {
+		var i RefreshToken
+		if err := rows.Scan(
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.FamilyID,
+			&i.RotatedAt,
+			&i.TokenHash,
+			&i.UserAgent,
+			&i.Ip,
+			&i.DeviceName,
+			&i.LastUsedAt,
+			&i.ClientID,
+			pq.Array(&i.Scopes),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeAllRefreshTokensForUser = `-- name: RevokeAllRefreshTokensForUser :exec
+UPDATE refresh_tokens
+SET revoked_at = $1, updated_at = $1
+WHERE user_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeAllRefreshTokensForUserParams struct {
+	RevokedAt sql.NullTime
+	UserID    uuid.UUID
+}
+
+func (q *Queries) RevokeAllRefreshTokensForUser(ctx context.Context, arg RevokeAllRefreshTokensForUserParams) error {
+	_, err := q.db.ExecContext(ctx, revokeAllRefreshTokensForUser, arg.RevokedAt, arg.UserID)
+	return err
+}
+
+const revokeOtherSessionsForUser = `-- name: RevokeOtherSessionsForUser :exec
+UPDATE refresh_tokens
+SET revoked_at = $1, updated_at = $1
+WHERE user_id = $2 AND family_id <> $3 AND revoked_at IS NULL
+`
+
+type RevokeOtherSessionsForUserParams struct {
+	RevokedAt sql.NullTime
+	UserID    uuid.UUID
+	FamilyID  uuid.UUID
+}
+
+func (q *Queries) RevokeOtherSessionsForUser(ctx context.Context, arg RevokeOtherSessionsForUserParams) error {
+	_, err := q.db.ExecContext(ctx, revokeOtherSessionsForUser, arg.RevokedAt, arg.UserID, arg.FamilyID)
+	return err
+}
+
+const revokeRefreshTokenFamily = `-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = $1, updated_at = $1
+WHERE family_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeRefreshTokenFamilyParams struct {
+	RevokedAt sql.NullTime
+	FamilyID  uuid.UUID
+}
+
+func (q *Queries) RevokeRefreshTokenFamily(ctx context.Context, arg RevokeRefreshTokenFamilyParams) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshTokenFamily, arg.RevokedAt, arg.FamilyID)
+	return err
+}
+
+const revokeSessionForUser = `-- name: RevokeSessionForUser :execrows
+UPDATE refresh_tokens
+SET revoked_at = $1, updated_at = $1
+WHERE family_id = $2 AND user_id = $3 AND revoked_at IS NULL
+`
+
+type RevokeSessionForUserParams struct {
+	RevokedAt sql.NullTime
+	FamilyID  uuid.UUID
+	UserID    uuid.UUID
+}
+
+func (q *Queries) RevokeSessionForUser(ctx context.Context, arg RevokeSessionForUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeSessionForUser, arg.RevokedAt, arg.FamilyID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const rotateRefreshToken = `-- name: RotateRefreshToken :execrows
+UPDATE refresh_tokens
+SET rotated_at = $1, updated_at = $1
+WHERE token_hash = $2 AND rotated_at IS NULL AND revoked_at IS NULL
+`
+
+type RotateRefreshTokenParams struct {
+	RotatedAt sql.NullTime
+	TokenHash string
+}
+
+func (q *Queries) RotateRefreshToken(ctx context.Context, arg RotateRefreshTokenParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rotateRefreshToken, arg.RotatedAt, arg.TokenHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const setRevokeAt = `-- name: SetRevokeAt :exec
 UPDATE refresh_tokens
 SET revoked_at = $1, updated_at = $2
-WHERE token = $3
+WHERE token_hash = $3
 `
 
 type SetRevokeAtParams struct {
 	RevokedAt sql.NullTime
 	UpdatedAt time.Time
-	Token     string
+	TokenHash string
 }
 
 func (q *Queries) SetRevokeAt(ctx context.Context, arg SetRevokeAtParams) error {
-	_, err := q.db.ExecContext(ctx, setRevokeAt, arg.RevokedAt, arg.UpdatedAt, arg.Token)
+	_, err := q.db.ExecContext(ctx, setRevokeAt, arg.RevokedAt, arg.UpdatedAt, arg.TokenHash)
 	return err
 }
@@ -13,6 +13,22 @@ import (
 	"github.com/google/uuid"
 )
 
+const cancelUserDeletion = `-- name: CancelUserDeletion :exec
+UPDATE users
+SET scheduled_deletion_at = NULL, updated_at = $1
+WHERE id = $2
+`
+
+type CancelUserDeletionParams struct {
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) CancelUserDeletion(ctx context.Context, arg CancelUserDeletionParams) error {
+	_, err := q.db.ExecContext(ctx, cancelUserDeletion, arg.UpdatedAt, arg.ID)
+	return err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (
@@ -22,7 +38,7 @@ VALUES (
 	$4,
 	$5
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, scheduled_deletion_at
 `
 
 type CreateUserParams struct {
@@ -49,21 +65,43 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.ScheduledDeletionAt,
 	)
 	return i, err
 }
 
-const deleteUsers = `-- name: DeleteUsers :exec
+const deleteUsersPastGracePeriod = `-- name: DeleteUsersPastGracePeriod :many
 DELETE FROM users
+WHERE scheduled_deletion_at <= $1
+RETURNING id
 `
 
-func (q *Queries) DeleteUsers(ctx context.Context) error {
-	_, err := q.db.ExecContext(ctx, deleteUsers)
-	return err
+func (q *Queries) DeleteUsersPastGracePeriod(ctx context.Context, scheduledDeletionAt sql.NullTime) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, deleteUsersPastGracePeriod, scheduledDeletionAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, scheduled_deletion_at FROM users
 WHERE email = $1
 LIMIT 1
 `
@@ -78,35 +116,90 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.ScheduledDeletionAt,
+	)
+	return i, err
+}
+
+const getUserByEmailIgnoringCase = `-- name: GetUserByEmailIgnoringCase :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, scheduled_deletion_at FROM users
+WHERE lower(email) = lower($1)
+ORDER BY created_at
+LIMIT 1
+`
+
+func (q *Queries) GetUserByEmailIgnoringCase(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmailIgnoringCase, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.ScheduledDeletionAt,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, scheduled_deletion_at FROM users
+WHERE id = $1
+LIMIT 1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.ScheduledDeletionAt,
 	)
 	return i, err
 }
 
 const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
-SELECT id, users.created_at, users.updated_at, email, hashed_password, is_chirpy_red, token, refresh_tokens.created_at, refresh_tokens.updated_at, user_id, expires_at, revoked_at FROM users
+SELECT id, users.created_at, users.updated_at, email, hashed_password, is_chirpy_red, email_verified_at, scheduled_deletion_at, refresh_tokens.created_at, refresh_tokens.updated_at, user_id, expires_at, revoked_at, family_id, rotated_at, token_hash, user_agent, ip, device_name, last_used_at FROM users
 INNER JOIN refresh_tokens
 ON users.id = refresh_tokens.user_id
-WHERE refresh_tokens.token = $1
+WHERE refresh_tokens.token_hash = $1
 LIMIT 1
 `
 
 type GetUserFromRefreshTokenRow struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
-	Token          string
-	CreatedAt_2    time.Time
-	UpdatedAt_2    time.Time
-	UserID         uuid.UUID
-	ExpiresAt      time.Time
-	RevokedAt      sql.NullTime
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Email               string
+	HashedPassword      string
+	IsChirpyRed         bool
+	EmailVerifiedAt     sql.NullTime
+	ScheduledDeletionAt sql.NullTime
+	CreatedAt_2         time.Time
+	UpdatedAt_2         time.Time
+	UserID              uuid.UUID
+	ExpiresAt           time.Time
+	RevokedAt           sql.NullTime
+	FamilyID            uuid.UUID
+	RotatedAt           sql.NullTime
+	TokenHash           string
+	UserAgent           string
+	Ip                  string
+	DeviceName          string
+	LastUsedAt          time.Time
 }
 
-func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (GetUserFromRefreshTokenRow, error) {
-	row := q.db.QueryRowContext(ctx, getUserFromRefreshToken, token)
+func (q *Queries) GetUserFromRefreshToken(ctx context.Context, tokenHash string) (GetUserFromRefreshTokenRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserFromRefreshToken, tokenHash)
 	var i GetUserFromRefreshTokenRow
 	err := row.Scan(
 		&i.ID,
@@ -115,31 +208,56 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Ge
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
-		&i.Token,
+		&i.EmailVerifiedAt,
+		&i.ScheduledDeletionAt,
 		&i.CreatedAt_2,
 		&i.UpdatedAt_2,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.RotatedAt,
+		&i.TokenHash,
+		&i.UserAgent,
+		&i.Ip,
+		&i.DeviceName,
+		&i.LastUsedAt,
 	)
 	return i, err
 }
 
-const updateUserEmailAndPassword = `-- name: UpdateUserEmailAndPassword :one
+const scheduleUserDeletion = `-- name: ScheduleUserDeletion :exec
 UPDATE users
-SET email = $1, hashed_password = $2
+SET scheduled_deletion_at = $1, updated_at = $2
 WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
 `
 
-type UpdateUserEmailAndPasswordParams struct {
-	Email          string
-	HashedPassword string
-	ID             uuid.UUID
+type ScheduleUserDeletionParams struct {
+	ScheduledDeletionAt sql.NullTime
+	UpdatedAt           time.Time
+	ID                  uuid.UUID
+}
+
+func (q *Queries) ScheduleUserDeletion(ctx context.Context, arg ScheduleUserDeletionParams) error {
+	_, err := q.db.ExecContext(ctx, scheduleUserDeletion, arg.ScheduledDeletionAt, arg.UpdatedAt, arg.ID)
+	return err
+}
+
+const setUserEmailVerified = `-- name: SetUserEmailVerified :one
+UPDATE users
+SET email = $1, email_verified_at = $2, updated_at = $2
+WHERE id = $3
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, scheduled_deletion_at
+`
+
+type SetUserEmailVerifiedParams struct {
+	Email           string
+	EmailVerifiedAt sql.NullTime
+	ID              uuid.UUID
 }
 
-func (q *Queries) UpdateUserEmailAndPassword(ctx context.Context, arg UpdateUserEmailAndPasswordParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUserEmailAndPassword, arg.Email, arg.HashedPassword, arg.ID)
+func (q *Queries) SetUserEmailVerified(ctx context.Context, arg SetUserEmailVerifiedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserEmailVerified, arg.Email, arg.EmailVerifiedAt, arg.ID)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -148,15 +266,34 @@ func (q *Queries) UpdateUserEmailAndPassword(ctx context.Context, arg UpdateUser
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.ScheduledDeletionAt,
 	)
 	return i, err
 }
 
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $1, updated_at = $2
+WHERE id = $3
+`
+
+type UpdateUserPasswordParams struct {
+	HashedPassword string
+	UpdatedAt      time.Time
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.HashedPassword, arg.UpdatedAt, arg.ID)
+	return err
+}
+
 const upgradeUserToRedByID = `-- name: UpgradeUserToRedByID :exec
 UPDATE users
 SET is_chirpy_red = true
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, scheduled_deletion_at
 `
 
 func (q *Queries) UpgradeUserToRedByID(ctx context.Context, id uuid.UUID) error {
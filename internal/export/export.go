@@ -0,0 +1,58 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Dataset is one section of a personal data archive. Records is written to
+// <Name>.json as is, and Header and Rows are written to <Name>.csv.
+type Dataset struct {
+	Name    string
+	Records any
+	Header  []string
+	Rows    [][]string
+}
+
+// WriteArchive writes every dataset as a JSON file and a CSV file into a zip
+// archive on w.
+func WriteArchive(w io.Writer, datasets []Dataset) error {
+	archive := zip.NewWriter(w)
+	for _, dataset := range datasets {
+		if err := writeJSON(archive, dataset); err != nil {
+			return fmt.Errorf("couldn't write %s.json: %w", dataset.Name, err)
+		}
+		if err := writeCSV(archive, dataset); err != nil {
+			return fmt.Errorf("couldn't write %s.csv: %w", dataset.Name, err)
+		}
+	}
+	return archive.Close()
+}
+
+func writeJSON(archive *zip.Writer, dataset Dataset) error {
+	file, err := archive.Create(dataset.Name + ".json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(dataset.Records)
+}
+
+func writeCSV(archive *zip.Writer, dataset Dataset) error {
+	file, err := archive.Create(dataset.Name + ".csv")
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(file)
+	if err := writer.Write(dataset.Header); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(dataset.Rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
@@ -0,0 +1,59 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"testing"
+)
+
+func TestWriteArchive(t *testing.T) {
+	type chirp struct {
+		ID   string `json:"id"`
+		Body string `json:"body"`
+	}
+	chirps := []chirp{{ID: "1", Body: "hello, world"}, {ID: "2", Body: "second \"chirp\""}}
+
+	var buf bytes.Buffer
+	err := WriteArchive(&buf, []Dataset{{
+		Name:    "chirps",
+		Records: chirps,
+		Header:  []string{"id", "body"},
+		Rows:    [][]string{{"1", "hello, world"}, {"2", "second \"chirp\""}},
+	}})
+	if err != nil {
+		t.Fatalf("WriteArchive() returned error: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Couldn't open the archive: %v", err)
+	}
+	files := map[string][]byte{}
+	for _, file := range archive.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("Couldn't open %s: %v", file.Name, err)
+		}
+		files[file.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+
+	var decoded []chirp
+	if err := json.Unmarshal(files["chirps.json"], &decoded); err != nil {
+		t.Fatalf("chirps.json is not valid JSON: %v", err)
+	}
+	if len(decoded) != 2 || decoded[1].Body != chirps[1].Body {
+		t.Errorf("chirps.json round-tripped to %v, want %v", decoded, chirps)
+	}
+
+	rows, err := csv.NewReader(bytes.NewReader(files["chirps.csv"])).ReadAll()
+	if err != nil {
+		t.Fatalf("chirps.csv is not valid CSV: %v", err)
+	}
+	if len(rows) != 3 || rows[1][1] != "hello, world" {
+		t.Errorf("chirps.csv has rows %v", rows)
+	}
+}
@@ -0,0 +1,179 @@
+// Package fixtures resets Chirpy's database and seeds it with named datasets,
+// for development and tests only.
+//
+// A dataset is a JSON file named after it in the fixtures directory, e.g.
+// fixtures/demo.json:
+//
+//	{
+//	  "users": [
+//	    {"email": "admin@chirpy.example", "password": "correct horse battery",
+//	     "email_verified": true, "roles": ["admin"]},
+//	    {"email": "walt@chirpy.example", "password": "say my name",
+//	     "is_chirpy_red": true}
+//	  ],
+//	  "chirps": [
+//	    {"author": "walt@chirpy.example", "body": "I am the one who knocks"}
+//	  ]
+//	}
+//
+// Datasets only hold users and chirps. Chirpy has no follows, so there is no
+// "follows" key, and a file that has one is rejected like any unknown field.
+package fixtures
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ErrNotAllowed is returned by Guard for any platform fixtures must not touch.
+var ErrNotAllowed = errors.New("fixtures are only available when PLATFORM is dev or test")
+
+// Guard reports whether fixtures may run on platform. Anything other than an
+// explicit dev or test platform, including an unset one, is treated as
+// production.
+func Guard(platform string) error {
+	switch platform {
+	case "dev", "test":
+		return nil
+	default:
+		return ErrNotAllowed
+	}
+}
+
+// Tables are the tables a reset empties, in no particular order. Roles and
+// their permissions are reference data seeded by migrations and are left
+// alone, as is the append-only audit log.
+var Tables = []string{
+	"chirps",
+	"data_exports",
+	"email_verification_tokens",
+	"login_attempts",
+	"mfa_challenges",
+	"oauth_authorization_codes",
+	"oauth_clients",
+	"oidc_login_states",
+	"password_reset_tokens",
+	"personal_access_tokens",
+	"recovery_codes",
+	"refresh_tokens",
+	"report_notes",
+	"report_submissions",
+	"reports",
+	"user_identities",
+	"user_roles",
+	"user_suspensions",
+	"user_totp",
+	"user_warnings",
+	"users",
+	"webauthn_challenges",
+	"webauthn_credentials",
+}
+
+// TruncateStatement returns the statement that empties tables. Every table
+// has to be in Tables. Tables that reference them through foreign keys are
+// emptied too, so truncating users takes everything a user owns with it.
+func TruncateStatement(tables []string) (string, error) {
+	if len(tables) == 0 {
+		return "", errors.New("no tables to truncate")
+	}
+	for _, table := range tables {
+		if !slices.Contains(Tables, table) {
+			return "", fmt.Errorf("%q can't be truncated", table)
+		}
+	}
+	return "TRUNCATE TABLE " + strings.Join(tables, ", ") + " CASCADE", nil
+}
+
+// Dataset is a set of rows to seed, read from a dataset file.
+type Dataset struct {
+	Users  []User  `json:"users"`
+	Chirps []Chirp `json:"chirps"`
+}
+
+type User struct {
+	Email         string   `json:"email"`
+	Password      string   `json:"password"`
+	IsChirpyRed   bool     `json:"is_chirpy_red,omitempty"`
+	EmailVerified bool     `json:"email_verified,omitempty"`
+	Roles         []string `json:"roles,omitempty"`
+}
+
+type Chirp struct {
+	// Author is the email of a user in the same dataset.
+	Author string `json:"author"`
+	Body   string `json:"body"`
+}
+
+// maxChirpLength matches the limit on chirps posted through the API.
+const maxChirpLength = 140
+
+var datasetName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Available lists the datasets in dir.
+func Available(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(paths))
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		if datasetName.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Load reads and validates the dataset called name from dir. Names are
+// restricted to lowercase letters, digits, dashes and underscores, so they
+// can't reach outside dir.
+func Load(dir, name string) (Dataset, error) {
+	if !datasetName.MatchString(name) {
+		return Dataset{}, fmt.Errorf("invalid dataset name %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name+".json"))
+	if err != nil {
+		return Dataset{}, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes and validates a dataset file.
+func Parse(data []byte) (Dataset, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var dataset Dataset
+	if err := decoder.Decode(&dataset); err != nil {
+		return Dataset{}, err
+	}
+
+	emails := make(map[string]bool, len(dataset.Users))
+	for i, user := range dataset.Users {
+		if user.Email == "" || user.Password == "" {
+			return Dataset{}, fmt.Errorf("user %d: email and password are required", i+1)
+		}
+		if emails[user.Email] {
+			return Dataset{}, fmt.Errorf("user %q appears twice", user.Email)
+		}
+		emails[user.Email] = true
+	}
+	for i, chirp := range dataset.Chirps {
+		if !emails[chirp.Author] {
+			return Dataset{}, fmt.Errorf("chirp %d: author %q isn't a user in the dataset", i+1, chirp.Author)
+		}
+		if chirp.Body == "" || len(chirp.Body) > maxChirpLength {
+			return Dataset{}, fmt.Errorf("chirp %d: body must be between 1 and %d characters", i+1, maxChirpLength)
+		}
+	}
+	return dataset, nil
+}
@@ -0,0 +1,149 @@
+package fixtures
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestGuard(t *testing.T) {
+	for _, platform := range []string{"dev", "test"} {
+		if err := Guard(platform); err != nil {
+			t.Errorf("Guard(%q) = %v, want nil", platform, err)
+		}
+	}
+	for _, platform := range []string{"", "prod", "production", "DEV", "staging"} {
+		if err := Guard(platform); !errors.Is(err, ErrNotAllowed) {
+			t.Errorf("Guard(%q) = %v, want ErrNotAllowed", platform, err)
+		}
+	}
+}
+
+func TestTruncateStatement(t *testing.T) {
+	statement, err := TruncateStatement([]string{"chirps", "users"})
+	if err != nil {
+		t.Fatalf("TruncateStatement() returned error: %v", err)
+	}
+	if want := "TRUNCATE TABLE chirps, users CASCADE"; statement != want {
+		t.Errorf("TruncateStatement() = %q, want %q", statement, want)
+	}
+
+	for _, tables := range [][]string{
+		nil,
+		{"audit_log"},
+		{"roles"},
+		{"users; DROP TABLE chirps"},
+		{"chirps", "goose_db_version"},
+	} {
+		if _, err := TruncateStatement(tables); err == nil {
+			t.Errorf("TruncateStatement(%q) was accepted", tables)
+		}
+	}
+}
+
+// TestTablesCoverSchema fails when a migration adds a table that neither
+// resets nor is deliberately kept, so a reset can't silently leave data
+// behind.
+func TestTablesCoverSchema(t *testing.T) {
+	kept := []string{"audit_log", "roles", "role_permissions"}
+	paths, err := filepath.Glob(filepath.Join("..", "..", "sql", "schema", "*.sql"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("couldn't find the migrations: %v", err)
+	}
+	createTable := regexp.MustCompile(`(?m)^CREATE TABLE (\w+)`)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile() returned error: %v", err)
+		}
+		for _, match := range createTable.FindAllStringSubmatch(string(data), -1) {
+			table := match[1]
+			if !slices.Contains(Tables, table) && !slices.Contains(kept, table) {
+				t.Errorf("table %s from %s is neither reset nor kept", table, filepath.Base(path))
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	dataset, err := Parse([]byte(`{
+		"users": [{"email": "a@chirpy.example", "password": "pw", "roles": ["admin"]}],
+		"chirps": [{"author": "a@chirpy.example", "body": "hello"}]
+	}`))
+	if err != nil {
+		t.Fatalf("Parse() returned error: %v", err)
+	}
+	if len(dataset.Users) != 1 || len(dataset.Chirps) != 1 || dataset.Users[0].Roles[0] != "admin" {
+		t.Errorf("Parse() = %+v", dataset)
+	}
+
+	long := make([]byte, maxChirpLength+1)
+	for i := range long {
+		long[i] = 'a'
+	}
+	tests := map[string]string{
+		"unknown field":    `{"users": [], "follows": []}`,
+		"missing password": `{"users": [{"email": "a@chirpy.example"}]}`,
+		"duplicate user":   `{"users": [{"email": "a@chirpy.example", "password": "pw"}, {"email": "a@chirpy.example", "password": "pw"}]}`,
+		"unknown author":   `{"users": [], "chirps": [{"author": "b@chirpy.example", "body": "hi"}]}`,
+		"empty chirp":      `{"users": [{"email": "a@chirpy.example", "password": "pw"}], "chirps": [{"author": "a@chirpy.example", "body": ""}]}`,
+		"long chirp":       `{"users": [{"email": "a@chirpy.example", "password": "pw"}], "chirps": [{"author": "a@chirpy.example", "body": "` + string(long) + `"}]}`,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse([]byte(data)); err == nil {
+				t.Errorf("Parse() accepted %s", data)
+			}
+		})
+	}
+}
+
+func TestLoadAndAvailable(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"small.json":    `{"users": [{"email": "a@chirpy.example", "password": "pw"}]}`,
+		"Ignored.json":  `{}`,
+		"notes.txt":     `not a dataset`,
+		"seed_two.json": `{}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatalf("WriteFile() returned error: %v", err)
+		}
+	}
+
+	available, err := Available(dir)
+	if err != nil {
+		t.Fatalf("Available() returned error: %v", err)
+	}
+	if !slices.Equal(available, []string{"seed_two", "small"}) {
+		t.Errorf("Available() = %v, want [seed_two small]", available)
+	}
+
+	if dataset, err := Load(dir, "small"); err != nil || len(dataset.Users) != 1 {
+		t.Errorf("Load(small) = %+v, %v", dataset, err)
+	}
+	if _, err := Load(dir, "missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load(missing) = %v, want os.ErrNotExist", err)
+	}
+	for _, name := range []string{"../small", "small.json", "", "Ignored"} {
+		if _, err := Load(dir, name); err == nil || errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Load(%q) = %v, want an invalid name error", name, err)
+		}
+	}
+}
+
+func TestShippedDatasetsAreValid(t *testing.T) {
+	dir := filepath.Join("..", "..", "fixtures")
+	names, err := Available(dir)
+	if err != nil {
+		t.Fatalf("Available() returned error: %v", err)
+	}
+	for _, name := range names {
+		if _, err := Load(dir, name); err != nil {
+			t.Errorf("dataset %s: %v", name, err)
+		}
+	}
+}
@@ -10,38 +10,27 @@ import (
 	"github.com/google/uuid"
 )
 
+// MakeJWT signs a token for userID with a shared HS256 secret. Access tokens
+// are signed by a Keyring; this is kept for callers that verify with the
+// same secret they sign with.
 func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	claims := jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
-		Subject:   userID.String(),
-	}
+	claims := newJWTClaims(Claims{UserID: userID}, DefaultTokenPolicy, expiresIn)
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	ss, err := token.SignedString([]byte(tokenSecret))
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 	return ss, nil
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+// ValidateJWT checks a token made by MakeJWT. Only HS256 is accepted, so a
+// token can't pick a weaker algorithm, or an asymmetric one keyed with the
+// secret, for itself.
+func ValidateJWT(tokenString, tokenSecret string) (Claims, error) {
+	keyfunc := func(token *jwt.Token) (interface{}, error) {
 		return []byte(tokenSecret), nil
-	})
-	if err != nil {
-		return uuid.UUID{}, err
-	}
-	id, err := token.Claims.GetSubject()
-	if err != nil {
-		return uuid.UUID{}, err
 	}
-	userID, err := uuid.Parse(id)
-	if err != nil {
-		return uuid.UUID{}, err
-	}
-	return userID, nil
-
+	return parseJWT(tokenString, keyfunc, []string{jwt.SigningMethodHS256.Alg()}, DefaultTokenPolicy)
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -17,13 +18,13 @@ func TestMakeAndValidateJWT(t *testing.T) {
 		t.Fatalf("MakeJWT() returned error: %v", err)
 	}
 
-	DecoderUserID, err := ValidateJWT(ss, tokenSecret)
+	claims, err := ValidateJWT(ss, tokenSecret)
 	if err != nil {
 		t.Fatalf("ValidateJWT() returned error: %v", err)
 	}
 
-	if DecoderUserID != userID {
-		t.Errorf("Decoded user ID %v does not match the input user id %v", DecoderUserID, userID)
+	if claims.UserID != userID {
+		t.Errorf("Decoded user ID %v does not match the input user id %v", claims.UserID, userID)
 	}
 }
 
@@ -78,3 +79,77 @@ func TestBearerToken(t *testing.T) {
 		t.Errorf("Mismatch in expected %s and received %s authentication tokens.", ExpectedAuthToken, authToken)
 	}
 }
+
+func TestValidateJWT_RejectsOtherAlgorithms(t *testing.T) {
+	tokenSecret := "LumianLee"
+	claims := newJWTClaims(Claims{UserID: uuid.New()}, DefaultTokenPolicy, time.Minute)
+
+	for _, method := range []jwt.SigningMethod{jwt.SigningMethodHS512, jwt.SigningMethodNone} {
+		var key interface{} = []byte(tokenSecret)
+		if method == jwt.SigningMethodNone {
+			key = jwt.UnsafeAllowNoneSignatureType
+		}
+		ss, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatalf("Couldn't sign %s token: %v", method.Alg(), err)
+		}
+		if _, err := ValidateJWT(ss, tokenSecret); err == nil {
+			t.Errorf("ValidateJWT() should reject %s tokens, but got none", method.Alg())
+		}
+	}
+}
+
+func TestValidateJWT_RejectsWrongIssuerOrAudience(t *testing.T) {
+	tokenSecret := "LumianLee"
+	policies := map[string]TokenPolicy{
+		"issuer":   {Issuer: "not-chirpy", Audience: DefaultTokenPolicy.Audience},
+		"audience": {Issuer: DefaultTokenPolicy.Issuer, Audience: "not-chirpy-api"},
+	}
+	for name, policy := range policies {
+		claims := newJWTClaims(Claims{UserID: uuid.New()}, policy, time.Minute)
+		ss, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(tokenSecret))
+		if err != nil {
+			t.Fatalf("Couldn't sign token: %v", err)
+		}
+		if _, err := ValidateJWT(ss, tokenSecret); err == nil {
+			t.Errorf("ValidateJWT() should reject a token with the wrong %s, but got none", name)
+		}
+	}
+}
+
+func TestKeyringClaimsRoundTrip(t *testing.T) {
+	policy := TokenPolicy{Issuer: "chirpy", Audience: "chirpy-api", Leeway: 30 * time.Second}
+	keyring := NewKeyring(AlgorithmEdDSA, time.Hour, "", policy)
+	if err := keyring.RotateIfDue(time.Hour); err != nil {
+		t.Fatalf("RotateIfDue() returned error: %v", err)
+	}
+
+	input := Claims{UserID: uuid.New(), SessionID: uuid.New(), ClientID: "client", Scopes: SessionScopes, Roles: []string{RoleAdmin}, IsChirpyRed: true}
+	ss, err := keyring.MakeJWT(input, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT() returned error: %v", err)
+	}
+
+	claims, err := keyring.ValidateJWT(ss)
+	if err != nil {
+		t.Fatalf("ValidateJWT() returned error: %v", err)
+	}
+	if claims.UserID != input.UserID || claims.SessionID != input.SessionID || claims.ClientID != input.ClientID || !claims.IsChirpyRed {
+		t.Errorf("Decoded claims %+v do not match the input claims %+v", claims, input)
+	}
+	if len(claims.Roles) != 1 || claims.Roles[0] != RoleAdmin {
+		t.Errorf("Decoded roles %v do not match %v", claims.Roles, input.Roles)
+	}
+	if len(claims.Scopes) != len(SessionScopes) {
+		t.Errorf("Decoded scopes %v do not match %v", claims.Scopes, SessionScopes)
+	}
+
+	// Leeway lets a token that expired a moment ago through.
+	recent, err := keyring.MakeJWT(input, -time.Second)
+	if err != nil {
+		t.Fatalf("MakeJWT() returned error: %v", err)
+	}
+	if _, err := keyring.ValidateJWT(recent); err != nil {
+		t.Errorf("ValidateJWT() should accept a token within the leeway: %v", err)
+	}
+}
@@ -0,0 +1,378 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmRS256 = "RS256"
+)
+
+// SigningKey is one private key of a Keyring. Its ID is the RFC 7638
+// thumbprint of the public key and goes into the kid header of every token it
+// signs.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	CreatedAt time.Time
+	private   crypto.Signer
+}
+
+func GenerateSigningKey(algorithm string) (*SigningKey, error) {
+	var private crypto.Signer
+	switch algorithm {
+	case AlgorithmEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		private = key
+	case AlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		private = key
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+	return newSigningKey(private, time.Now())
+}
+
+// ParseSigningKeyPEM reads a PKCS #8 encoded Ed25519 or RSA private key.
+func ParseSigningKeyPEM(pemBytes []byte, createdAt time.Time) (*SigningKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("no PKCS #8 private key found")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", parsed)
+	}
+	return newSigningKey(private, createdAt)
+}
+
+func newSigningKey(private crypto.Signer, createdAt time.Time) (*SigningKey, error) {
+	key := &SigningKey{CreatedAt: createdAt, private: private}
+	switch private.(type) {
+	case ed25519.PrivateKey:
+		key.Algorithm = AlgorithmEdDSA
+	case *rsa.PrivateKey:
+		key.Algorithm = AlgorithmRS256
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", private)
+	}
+	thumbprint, err := key.thumbprint()
+	if err != nil {
+		return nil, err
+	}
+	key.ID = thumbprint
+	return key, nil
+}
+
+func (k *SigningKey) MarshalPEM() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.private)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func (k *SigningKey) signingMethod() jwt.SigningMethod {
+	if k.Algorithm == AlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// JWK is the public half of a signing key as published in a JWKS document.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (k *SigningKey) PublicJWK() JWK {
+	jwk := JWK{KeyID: k.ID, Use: "sig", Algorithm: k.Algorithm}
+	switch public := k.private.Public().(type) {
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	}
+	return jwk
+}
+
+// thumbprint hashes the required JWK members in lexicographic order, as
+// RFC 7638 prescribes.
+func (k *SigningKey) thumbprint() (string, error) {
+	jwk := k.PublicJWK()
+	var members any
+	if jwk.KeyType == "OKP" {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	} else {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	}
+	encoded, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// Keyring signs access tokens with its newest key and verifies them with any
+// key that hasn't been retired for longer than the retention period. Rotating
+// therefore never invalidates tokens that are still within their lifetime.
+type Keyring struct {
+	mu        sync.RWMutex
+	algorithm string
+	retention time.Duration
+	dir       string
+	policy    TokenPolicy
+	keys      []*SigningKey
+	loadedAt  time.Time
+}
+
+// keyReloadInterval limits how often a token signed with an unknown key makes
+// the keyring read its directory again.
+const keyReloadInterval = time.Minute
+
+// NewKeyring returns an empty keyring. When dir is not empty, keys are
+// persisted there as <kid>.pem so they survive restarts and can be shared by
+// several instances; otherwise they only live in memory. Tokens are issued
+// and validated according to policy.
+//
+// Instances sharing dir pick up each other's keys by reading it again before
+// deciding whether to rotate, and when they see a token signed with a key
+// they don't know yet.
+func NewKeyring(algorithm string, retention time.Duration, dir string, policy TokenPolicy) *Keyring {
+	return &Keyring{algorithm: algorithm, retention: retention, dir: dir, policy: policy}
+}
+
+// Load reads every key stored in the keyring directory, using the file
+// modification time as the key creation time.
+func (kr *Keyring) Load() error {
+	if kr.dir == "" {
+		return nil
+	}
+	paths, err := filepath.Glob(filepath.Join(kr.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	keys := make([]*SigningKey, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		pemBytes, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		key, err := ParseSigningKeyPEM(pemBytes, info.ModTime())
+		if err != nil {
+			return fmt.Errorf("couldn't load signing key %s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.keys = keys
+	kr.loadedAt = time.Now()
+	return nil
+}
+
+// reloadForUnknownKey reads the keyring directory again, unless it was read
+// within keyReloadInterval, and reports whether it did.
+func (kr *Keyring) reloadForUnknownKey() bool {
+	if kr.dir == "" {
+		return false
+	}
+	kr.mu.RLock()
+	recent := time.Since(kr.loadedAt) < keyReloadInterval
+	kr.mu.RUnlock()
+	return !recent && kr.Load() == nil
+}
+
+// Add makes key the active signing key.
+func (kr *Keyring) Add(key *SigningKey) error {
+	if kr.dir != "" {
+		pemBytes, err := key.MarshalPEM()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(kr.dir, 0o700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(kr.dir, key.ID+".pem"), pemBytes, 0o600); err != nil {
+			return err
+		}
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	for _, existing := range kr.keys {
+		if existing.ID == key.ID {
+			return nil
+		}
+	}
+	kr.keys = append(kr.keys, key)
+	return nil
+}
+
+// RotateIfDue generates a new signing key when there is none yet or the
+// active one is older than interval, then drops keys whose retention period
+// is over. The keys in the directory are read first, so that an instance
+// doesn't rotate when another one sharing the directory just did.
+func (kr *Keyring) RotateIfDue(interval time.Duration) error {
+	if err := kr.Load(); err != nil {
+		return err
+	}
+
+	kr.mu.RLock()
+	due := len(kr.keys) == 0 || time.Since(kr.keys[len(kr.keys)-1].CreatedAt) >= interval
+	kr.mu.RUnlock()
+
+	if due {
+		key, err := GenerateSigningKey(kr.algorithm)
+		if err != nil {
+			return err
+		}
+		if err := kr.Add(key); err != nil {
+			return err
+		}
+	}
+	return kr.prune()
+}
+
+// prune removes every key that was replaced more than the retention period
+// ago. A key is retired at the moment the next one was created.
+func (kr *Keyring) prune() error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	kept := make([]*SigningKey, 0, len(kr.keys))
+	for i, key := range kr.keys {
+		if i < len(kr.keys)-1 && time.Since(kr.keys[i+1].CreatedAt) > kr.retention {
+			if kr.dir != "" {
+				err := os.Remove(filepath.Join(kr.dir, key.ID+".pem"))
+				if err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+			continue
+		}
+		kept = append(kept, key)
+	}
+	kr.keys = kept
+	return nil
+}
+
+func (kr *Keyring) activeKey() (*SigningKey, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	if len(kr.keys) == 0 {
+		return nil, fmt.Errorf("keyring has no signing key")
+	}
+	return kr.keys[len(kr.keys)-1], nil
+}
+
+func (kr *Keyring) lookup(kid string) (*SigningKey, bool) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	for _, key := range kr.keys {
+		if key.ID == kid {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// JWKS returns the public keys of every key still used for verification.
+func (kr *Keyring) JWKS() JWKSet {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	set := JWKSet{Keys: make([]JWK, 0, len(kr.keys))}
+	for _, key := range kr.keys {
+		set.Keys = append(set.Keys, key.PublicJWK())
+	}
+	return set
+}
+
+func (kr *Keyring) MakeJWT(claims Claims, expiresIn time.Duration) (string, error) {
+	key, err := kr.activeKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.signingMethod(), newJWTClaims(claims, kr.policy, expiresIn))
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.private)
+}
+
+func (kr *Keyring) ValidateJWT(tokenString string) (Claims, error) {
+	return parseJWT(tokenString, kr.keyfunc, []string{AlgorithmEdDSA, AlgorithmRS256}, kr.policy)
+}
+
+// keyfunc picks the verification key named by the kid header and insists the
+// token was signed with that key's own algorithm.
+func (kr *Keyring) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := kr.lookup(kid)
+	if !ok && kr.reloadForUnknownKey() {
+		key, ok = kr.lookup(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("signing key %q does not use %s", kid, token.Method.Alg())
+	}
+	return key.private.Public(), nil
+}
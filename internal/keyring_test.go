@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestKeyringMakeAndValidateJWT(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmEdDSA, AlgorithmRS256} {
+		keyring := NewKeyring(algorithm, time.Hour, "", DefaultTokenPolicy)
+		if err := keyring.RotateIfDue(time.Hour); err != nil {
+			t.Fatalf("%s: RotateIfDue() returned error: %v", algorithm, err)
+		}
+
+		userID := uuid.New()
+		ss, err := keyring.MakeJWT(Claims{UserID: userID}, 5*time.Second)
+		if err != nil {
+			t.Fatalf("%s: MakeJWT() returned error: %v", algorithm, err)
+		}
+
+		claims, err := keyring.ValidateJWT(ss)
+		if err != nil {
+			t.Fatalf("%s: ValidateJWT() returned error: %v", algorithm, err)
+		}
+		if claims.UserID != userID {
+			t.Errorf("%s: Decoded user ID %v does not match the input user id %v", algorithm, claims.UserID, userID)
+		}
+	}
+}
+
+func TestKeyringRotationKeepsOldKeysForVerification(t *testing.T) {
+	keyring := NewKeyring(AlgorithmEdDSA, time.Hour, "", DefaultTokenPolicy)
+	if err := keyring.RotateIfDue(time.Hour); err != nil {
+		t.Fatalf("RotateIfDue() returned error: %v", err)
+	}
+	oldToken, err := keyring.MakeJWT(Claims{UserID: uuid.New()}, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT() returned error: %v", err)
+	}
+
+	// An interval of zero forces a new key.
+	if err := keyring.RotateIfDue(0); err != nil {
+		t.Fatalf("RotateIfDue() returned error: %v", err)
+	}
+	if len(keyring.JWKS().Keys) != 2 {
+		t.Fatalf("Expected both keys to be published, got %d", len(keyring.JWKS().Keys))
+	}
+	if _, err := keyring.ValidateJWT(oldToken); err != nil {
+		t.Errorf("Token signed with the retired key should still validate: %v", err)
+	}
+
+	newToken, err := keyring.MakeJWT(Claims{UserID: uuid.New()}, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT() returned error: %v", err)
+	}
+	oldKid, _ := jwtHeader(t, oldToken)["kid"].(string)
+	newKid, _ := jwtHeader(t, newToken)["kid"].(string)
+	if oldKid == newKid {
+		t.Error("Tokens should be signed with the new key after rotation")
+	}
+}
+
+func TestKeyringPrunesRetiredKeys(t *testing.T) {
+	keyring := NewKeyring(AlgorithmEdDSA, 0, "", DefaultTokenPolicy)
+	if err := keyring.RotateIfDue(time.Hour); err != nil {
+		t.Fatalf("RotateIfDue() returned error: %v", err)
+	}
+	oldToken, err := keyring.MakeJWT(Claims{UserID: uuid.New()}, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT() returned error: %v", err)
+	}
+
+	time.Sleep(time.Millisecond)
+	if err := keyring.RotateIfDue(0); err != nil {
+		t.Fatalf("RotateIfDue() returned error: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if err := keyring.RotateIfDue(time.Hour); err != nil {
+		t.Fatalf("RotateIfDue() returned error: %v", err)
+	}
+
+	if len(keyring.JWKS().Keys) != 1 {
+		t.Errorf("Expected the retired key to be pruned, got %d keys", len(keyring.JWKS().Keys))
+	}
+	if _, err := keyring.ValidateJWT(oldToken); err == nil {
+		t.Error("ValidateJWT() should reject tokens signed with a pruned key, but got no error")
+	}
+}
+
+func TestKeyringPersistsKeys(t *testing.T) {
+	dir := t.TempDir()
+	keyring := NewKeyring(AlgorithmRS256, time.Hour, dir, DefaultTokenPolicy)
+	if err := keyring.RotateIfDue(time.Hour); err != nil {
+		t.Fatalf("RotateIfDue() returned error: %v", err)
+	}
+	ss, err := keyring.MakeJWT(Claims{UserID: uuid.New()}, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT() returned error: %v", err)
+	}
+
+	reloaded := NewKeyring(AlgorithmRS256, time.Hour, dir, DefaultTokenPolicy)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if _, err := reloaded.ValidateJWT(ss); err != nil {
+		t.Errorf("Reloaded keyring should validate the token: %v", err)
+	}
+}
+
+func TestKeyringRejectsForeignTokens(t *testing.T) {
+	keyring := NewKeyring(AlgorithmEdDSA, time.Hour, "", DefaultTokenPolicy)
+	if err := keyring.RotateIfDue(time.Hour); err != nil {
+		t.Fatalf("RotateIfDue() returned error: %v", err)
+	}
+
+	other := NewKeyring(AlgorithmEdDSA, time.Hour, "", DefaultTokenPolicy)
+	if err := other.RotateIfDue(time.Hour); err != nil {
+		t.Fatalf("RotateIfDue() returned error: %v", err)
+	}
+	foreign, err := other.MakeJWT(Claims{UserID: uuid.New()}, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT() returned error: %v", err)
+	}
+	if _, err := keyring.ValidateJWT(foreign); err == nil {
+		t.Error("ValidateJWT() should reject tokens signed by another keyring, but got no error")
+	}
+
+	hs256, err := MakeJWT(uuid.New(), "LumianLee", time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT() returned error: %v", err)
+	}
+	if _, err := keyring.ValidateJWT(hs256); err == nil {
+		t.Error("ValidateJWT() should reject HS256 tokens, but got no error")
+	}
+}
+
+func jwtHeader(t *testing.T, tokenString string) map[string]interface{} {
+	t.Helper()
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, &jwt.RegisteredClaims{})
+	if err != nil {
+		t.Fatalf("Couldn't parse token: %v", err)
+	}
+	return token.Header
+}
+
+func TestKeyringsSharingADirectory(t *testing.T) {
+	dir := t.TempDir()
+	first := NewKeyring(AlgorithmEdDSA, time.Hour, dir, DefaultTokenPolicy)
+	second := NewKeyring(AlgorithmEdDSA, time.Hour, dir, DefaultTokenPolicy)
+	if err := first.RotateIfDue(time.Hour); err != nil {
+		t.Fatalf("RotateIfDue() returned error: %v", err)
+	}
+
+	// second started out without keys, but sees the one first just minted
+	// instead of minting its own.
+	if err := second.RotateIfDue(time.Hour); err != nil {
+		t.Fatalf("RotateIfDue() returned error: %v", err)
+	}
+	if len(second.JWKS().Keys) != 1 {
+		t.Errorf("Expected the instances to share one key, got %d", len(second.JWKS().Keys))
+	}
+
+	// A key another instance minted is picked up when a token uses it.
+	if err := first.RotateIfDue(0); err != nil {
+		t.Fatalf("RotateIfDue() returned error: %v", err)
+	}
+	ss, err := first.MakeJWT(Claims{UserID: uuid.New()}, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT() returned error: %v", err)
+	}
+	second.mu.Lock()
+	second.loadedAt = time.Time{}
+	second.mu.Unlock()
+	if _, err := second.ValidateJWT(ss); err != nil {
+		t.Errorf("The other instance should validate a token signed with the new key: %v", err)
+	}
+	if len(second.JWKS().Keys) != 2 {
+		t.Errorf("Expected both keys after reloading, got %d", len(second.JWKS().Keys))
+	}
+}
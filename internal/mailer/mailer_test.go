@@ -0,0 +1,25 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLogMailerSend(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewLogMailer(&buf, "chirpy@example.com")
+
+	msg := Message{To: "lumian@example.com", Subject: "Verify your email", Body: "Token: abc123"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send() returned error: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"From: chirpy@example.com", "To: lumian@example.com", "Subject: Verify your email", "Token: abc123"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Logged message %q does not contain %q", out, want)
+		}
+	}
+}
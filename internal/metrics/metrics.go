@@ -0,0 +1,360 @@
+// Package metrics keeps counters, gauges and histograms in memory and serves
+// them in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets suit latencies measured in seconds, from 5ms to 10s.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metric is one family in the exposition: a name, its help text and type, and
+// the samples it currently holds.
+type metric interface {
+	write(w *bufio.Writer, name string)
+}
+
+type family struct {
+	help   string
+	kind   string
+	metric metric
+}
+
+// Registry holds every metric that Handler serves. Metrics are created
+// through it and live for the life of the process.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+// register panics on a duplicate or invalid name, since both are programming
+// errors caught the first time the program starts.
+func (r *Registry) register(name, help, kind string, m metric, labels []string) {
+	if !validName(name) {
+		panic(fmt.Sprintf("metrics: invalid name %q", name))
+	}
+	for _, label := range labels {
+		if !validName(label) || label == "le" {
+			panic(fmt.Sprintf("metrics: invalid label %q on %s", label, name))
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.families[name]; ok {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.families[name] = family{help: help, kind: kind, metric: m}
+}
+
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{}
+	r.register(name, help, "counter", c, nil)
+	return c
+}
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{vec: newVec(labels, func() *Counter { return &Counter{} })}
+	r.register(name, help, "counter", v, labels)
+	return v
+}
+
+// NewCounterFunc registers a counter whose value is read from f when the
+// metrics are scraped, for totals kept elsewhere such as sql.DBStats.
+func (r *Registry) NewCounterFunc(name, help string, f func() float64) {
+	r.register(name, help, "counter", valueFunc(f), nil)
+}
+
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{}
+	r.register(name, help, "gauge", g, nil)
+	return g
+}
+
+// NewGaugeFunc registers a gauge whose value is read from f when the metrics
+// are scraped.
+func (r *Registry) NewGaugeFunc(name, help string, f func() float64) {
+	r.register(name, help, "gauge", valueFunc(f), nil)
+}
+
+// NewHistogramVec registers histograms with the given upper bucket bounds,
+// which must be sorted. A +Inf bucket is always added.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if !slices.IsSorted(buckets) {
+		panic(fmt.Sprintf("metrics: buckets of %s aren't sorted", name))
+	}
+	v := &HistogramVec{vec: newVec(labels, func() *Histogram { return newHistogram(buckets) })}
+	r.register(name, help, "histogram", v, labels)
+	return v
+}
+
+// Expose writes every metric in the text exposition format, sorted by name.
+func (r *Registry) Expose(w io.Writer) error {
+	r.mu.Lock()
+	families := maps.Clone(r.families)
+	r.mu.Unlock()
+	names := slices.Sorted(maps.Keys(families))
+
+	buffered := bufio.NewWriter(w)
+	for _, name := range names {
+		f := families[name]
+		fmt.Fprintf(buffered, "# HELP %s %s\n", name, escapeHelp(f.help))
+		fmt.Fprintf(buffered, "# TYPE %s %s\n", name, f.kind)
+		f.metric.write(buffered, name)
+	}
+	return buffered.Flush()
+}
+
+// Handler serves the registry to a Prometheus scraper.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		r.Expose(w)
+	})
+}
+
+// Counter only goes up.
+type Counter struct {
+	value atomic.Uint64
+}
+
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+func (c *Counter) Add(n uint64) {
+	c.value.Add(n)
+}
+
+func (c *Counter) Value() uint64 {
+	return c.value.Load()
+}
+
+func (c *Counter) write(w *bufio.Writer, name string) {
+	writeSample(w, name, "", float64(c.Value()))
+}
+
+// Gauge goes up and down.
+type Gauge struct {
+	bits atomic.Uint64
+}
+
+func (g *Gauge) Set(value float64) {
+	g.bits.Store(math.Float64bits(value))
+}
+
+func (g *Gauge) Add(delta float64) {
+	for {
+		old := g.bits.Load()
+		if g.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+func (g *Gauge) write(w *bufio.Writer, name string) {
+	writeSample(w, name, "", g.Value())
+}
+
+type valueFunc func() float64
+
+func (f valueFunc) write(w *bufio.Writer, name string) {
+	writeSample(w, name, "", f())
+}
+
+// Histogram counts observations into buckets.
+type Histogram struct {
+	bounds []float64
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *Histogram {
+	return &Histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (h *Histogram) Observe(value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i, _ := slices.BinarySearch(h.bounds, value); i < len(h.bounds) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += value
+}
+
+func (h *Histogram) writeLabelled(w *bufio.Writer, name, labels string) {
+	h.mu.Lock()
+	counts := slices.Clone(h.counts)
+	count, sum := h.count, h.sum
+	h.mu.Unlock()
+
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += counts[i]
+		writeSample(w, name+"_bucket", joinLabels(labels, `le="`+formatValue(bound)+`"`), float64(cumulative))
+	}
+	writeSample(w, name+"_bucket", joinLabels(labels, `le="+Inf"`), float64(count))
+	writeSample(w, name+"_sum", labels, sum)
+	writeSample(w, name+"_count", labels, float64(count))
+}
+
+// vec keeps one child metric per combination of label values.
+type vec[T any] struct {
+	labels   []string
+	newChild func() T
+	mu       sync.Mutex
+	children map[string]vecChild[T]
+}
+
+type vecChild[T any] struct {
+	values []string
+	metric T
+}
+
+func newVec[T any](labels []string, newChild func() T) vec[T] {
+	return vec[T]{labels: labels, newChild: newChild, children: make(map[string]vecChild[T])}
+}
+
+// with panics when the number of values doesn't match the labels, like a
+// type error would.
+func (v *vec[T]) with(values []string) T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: got %d label values for %d labels", len(values), len(v.labels)))
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	child, ok := v.children[key]
+	if !ok {
+		child = vecChild[T]{values: slices.Clone(values), metric: v.newChild()}
+		v.children[key] = child
+	}
+	return child.metric
+}
+
+// each calls f with the rendered labels of every child, in a stable order.
+func (v *vec[T]) each(f func(labels string, metric T)) {
+	v.mu.Lock()
+	children := make([]vecChild[T], 0, len(v.children))
+	for _, child := range v.children {
+		children = append(children, child)
+	}
+	v.mu.Unlock()
+	slices.SortFunc(children, func(a, b vecChild[T]) int {
+		return slices.Compare(a.values, b.values)
+	})
+
+	for _, child := range children {
+		pairs := make([]string, len(v.labels))
+		for i, label := range v.labels {
+			pairs[i] = label + `="` + escapeLabel(child.values[i]) + `"`
+		}
+		f(strings.Join(pairs, ","), child.metric)
+	}
+}
+
+type CounterVec struct {
+	vec vec[*Counter]
+}
+
+// With returns the counter for the given label values, in the order the
+// labels were declared.
+func (v *CounterVec) With(values ...string) *Counter {
+	return v.vec.with(values)
+}
+
+func (v *CounterVec) write(w *bufio.Writer, name string) {
+	v.vec.each(func(labels string, c *Counter) {
+		writeSample(w, name, labels, float64(c.Value()))
+	})
+}
+
+type HistogramVec struct {
+	vec vec[*Histogram]
+}
+
+// With returns the histogram for the given label values, in the order the
+// labels were declared.
+func (v *HistogramVec) With(values ...string) *Histogram {
+	return v.vec.with(values)
+}
+
+func (v *HistogramVec) write(w *bufio.Writer, name string) {
+	v.vec.each(func(labels string, h *Histogram) {
+		h.writeLabelled(w, name, labels)
+	})
+}
+
+func writeSample(w *bufio.Writer, name, labels string, value float64) {
+	w.WriteString(name)
+	if labels != "" {
+		w.WriteString("{" + labels + "}")
+	}
+	w.WriteString(" " + formatValue(value) + "\n")
+}
+
+func joinLabels(labels, extra string) string {
+	if labels == "" {
+		return extra
+	}
+	return labels + "," + extra
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
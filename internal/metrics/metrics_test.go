@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func render(t *testing.T, r *Registry) string {
+	t.Helper()
+	var out strings.Builder
+	if err := r.Expose(&out); err != nil {
+		t.Fatalf("Expose() returned error: %v", err)
+	}
+	return out.String()
+}
+
+func TestExposition(t *testing.T) {
+	r := NewRegistry()
+	hits := r.NewCounter("hits_total", "Fileserver hits.")
+	hits.Add(3)
+	inFlight := r.NewGauge("in_flight", "Requests being served.")
+	inFlight.Inc()
+	inFlight.Inc()
+	inFlight.Dec()
+	r.NewGaugeFunc("open_connections", "Open connections.", func() float64 { return 7 })
+	requests := r.NewCounterVec("requests_total", "Requests by route and code.", "route", "code")
+	requests.With("GET /api/chirps", "200").Inc()
+	requests.With("GET /api/chirps", "200").Inc()
+	requests.With("POST /api/chirps", "201").Inc()
+	latency := r.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	latency.With("GET /api/chirps").Observe(0.05)
+	latency.With("GET /api/chirps").Observe(0.1)
+	latency.With("GET /api/chirps").Observe(0.5)
+	latency.With("GET /api/chirps").Observe(3)
+
+	want := `# HELP hits_total Fileserver hits.
+# TYPE hits_total counter
+hits_total 3
+# HELP in_flight Requests being served.
+# TYPE in_flight gauge
+in_flight 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="GET /api/chirps",le="0.1"} 2
+latency_seconds_bucket{route="GET /api/chirps",le="1"} 3
+latency_seconds_bucket{route="GET /api/chirps",le="+Inf"} 4
+latency_seconds_sum{route="GET /api/chirps"} 3.65
+latency_seconds_count{route="GET /api/chirps"} 4
+# HELP open_connections Open connections.
+# TYPE open_connections gauge
+open_connections 7
+# HELP requests_total Requests by route and code.
+# TYPE requests_total counter
+requests_total{route="GET /api/chirps",code="200"} 2
+requests_total{route="POST /api/chirps",code="201"} 1
+`
+	if got := render(t, r); got != want {
+		t.Errorf("Expose() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("odd_total", "Help with a \\ and a\nnewline.", "value").With("a \"quoted\" \\ value\n").Inc()
+
+	got := render(t, r)
+	for _, want := range []string{
+		`# HELP odd_total Help with a \\ and a\nnewline.`,
+		`odd_total{value="a \"quoted\" \\ value\n"} 1`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output is missing %s:\n%s", want, got)
+		}
+	}
+}
+
+func TestRegistrationMistakesPanic(t *testing.T) {
+	tests := map[string]func(r *Registry){
+		"duplicate name":   func(r *Registry) { r.NewCounter("a_total", ""); r.NewGauge("a_total", "") },
+		"invalid name":     func(r *Registry) { r.NewCounter("chirpy-hits", "") },
+		"reserved label":   func(r *Registry) { r.NewHistogramVec("h", "", DefaultBuckets, "le") },
+		"unsorted buckets": func(r *Registry) { r.NewHistogramVec("h", "", []float64{1, 0.5}) },
+		"wrong label count": func(r *Registry) {
+			r.NewCounterVec("c_total", "", "route", "code").With("GET /")
+		},
+	}
+	for name, mistake := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("didn't panic")
+				}
+			}()
+			mistake(NewRegistry())
+		})
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	r := NewRegistry()
+	counters := r.NewCounterVec("c_total", "", "worker")
+	histograms := r.NewHistogramVec("h_seconds", "", DefaultBuckets, "worker")
+	gauge := r.NewGauge("g", "")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				counters.With("shared").Inc()
+				histograms.With("shared").Observe(0.01)
+				gauge.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := counters.With("shared").Value(); got != 8000 {
+		t.Errorf("counter = %d, want 8000", got)
+	}
+	if got := gauge.Value(); got != 8000 {
+		t.Errorf("gauge = %v, want 8000", got)
+	}
+	if got := render(t, r); !strings.Contains(got, `h_seconds_count{worker="shared"} 8000`) {
+		t.Errorf("histogram count is off:\n%s", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("hits_total", "Hits.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if !strings.Contains(rec.Body.String(), "hits_total 1\n") {
+		t.Errorf("body = %q", rec.Body.String())
+	}
+}
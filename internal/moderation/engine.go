@@ -0,0 +1,235 @@
+// Package moderation decides what happens to a chirp before it is stored,
+// following rules read from a JSON file that can change while Chirpy runs.
+//
+// A rules file looks like this:
+//
+//	{
+//	  "dry_run": false,
+//	  "rules": [
+//	    {"name": "link-spam", "action": "hold",
+//	     "when": {"links_more_than": 3, "account_younger_than": "24h"}},
+//	    {"name": "flooding", "action": "reject", "message": "Slow down.",
+//	     "when": {"repeats": 5, "repeat_window": "1m"}},
+//	    {"name": "profanity", "action": "mask",
+//	     "when": {"words": ["kerfuffle", "sharbert", "fornax"]}}
+//	  ]
+//	}
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Facts are what rules may ask about a chirp beyond its body. They are only
+// looked up when a rule needs them, and at most once per evaluation.
+type Facts interface {
+	// AccountAge is how long ago the author signed up.
+	AccountAge(ctx context.Context) (time.Duration, error)
+	// RecentRepeats is how many chirps with the same body the author posted
+	// within window, not counting the one being evaluated. body is the chirp
+	// as written, before any masking, and has to be compared with earlier
+	// chirps as written too: comparing with their stored, masked bodies would
+	// let any chirp with a masked word escape repeat rules.
+	RecentRepeats(ctx context.Context, body string, window time.Duration) (int, error)
+}
+
+// Decision is the outcome of evaluating the rules against a chirp.
+type Decision struct {
+	Action Action
+	// Body is the chirp to store, masked where rules said so.
+	Body string
+	// Message is the message of the rule that decided Action, if any.
+	Message string
+	// Matched names the rules that took effect.
+	Matched []string
+	// DryRun names the dry-run rules that matched, with what they would have
+	// done.
+	DryRun []DryRunMatch
+}
+
+type DryRunMatch struct {
+	Rule   string
+	Action Action
+}
+
+// Engine evaluates the current rules. It is safe for concurrent use, and
+// rules can be swapped while evaluations are running.
+type Engine struct {
+	rules atomic.Pointer[[]rule]
+
+	// path is the rules file, or empty when running DefaultConfig.
+	path string
+	// mu guards the last loaded file contents, used to skip reloads.
+	mu     sync.Mutex
+	loaded []byte
+}
+
+// NewEngine returns an engine running config.
+func NewEngine(config Config) (*Engine, error) {
+	rules, err := compile(config)
+	if err != nil {
+		return nil, err
+	}
+	e := &Engine{}
+	e.rules.Store(&rules)
+	return e, nil
+}
+
+// LoadFile returns an engine running the rules in path, which Reload reads
+// again.
+func LoadFile(path string) (*Engine, error) {
+	e := &Engine{path: path}
+	if _, err := e.Reload(context.Background()); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Reload reads the rules file again and switches to it if it changed. An
+// invalid file leaves the current rules in place and returns the error.
+// Engines without a file never reload.
+func (e *Engine) Reload(ctx context.Context) (bool, error) {
+	if e.path == "" {
+		return false, nil
+	}
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		return false, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.loaded != nil && bytes.Equal(data, e.loaded) {
+		return false, nil
+	}
+	config, err := ParseConfig(data)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", e.path, err)
+	}
+	rules, err := compile(config)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", e.path, err)
+	}
+	e.rules.Store(&rules)
+	e.loaded = data
+	return true, nil
+}
+
+// ParseConfig decodes a rules file. Unknown fields are an error, so a typo in
+// a condition doesn't silently widen a rule.
+func ParseConfig(data []byte) (Config, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var config Config
+	if err := decoder.Decode(&config); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// Evaluate runs the rules against body in order.
+func (e *Engine) Evaluate(ctx context.Context, body string, facts Facts) (Decision, error) {
+	rules := *e.rules.Load()
+	decision := Decision{Action: ActionAllow, Body: body}
+	lookup := &cachedFacts{facts: facts}
+
+	for _, r := range rules {
+		matched, err := r.matches(ctx, body, lookup)
+		if err != nil {
+			return Decision{}, fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+		if !matched {
+			continue
+		}
+		if r.DryRun {
+			decision.DryRun = append(decision.DryRun, DryRunMatch{Rule: r.Name, Action: r.Action})
+			continue
+		}
+
+		decision.Matched = append(decision.Matched, r.Name)
+		if r.Action == ActionAllow {
+			break
+		}
+		if r.Action == ActionMask {
+			decision.Body = r.mask(decision.Body)
+		}
+		if r.Action.severity() > decision.Action.severity() {
+			decision.Action = r.Action
+			decision.Message = r.Message
+		}
+	}
+	return decision, nil
+}
+
+// matches checks the cheap conditions first, so facts are only looked up for
+// chirps that could match.
+func (r rule) matches(ctx context.Context, body string, facts *cachedFacts) (bool, error) {
+	if r.words != nil && !r.matchesWords(body) {
+		return false, nil
+	}
+	if r.pattern != nil && !r.pattern.MatchString(body) {
+		return false, nil
+	}
+	if r.When.LinksMoreThan != nil && countLinks(body) <= *r.When.LinksMoreThan {
+		return false, nil
+	}
+	if r.When.AccountYoungerThan > 0 {
+		age, err := facts.AccountAge(ctx)
+		if err != nil {
+			return false, err
+		}
+		if age >= time.Duration(r.When.AccountYoungerThan) {
+			return false, nil
+		}
+	}
+	if r.When.Repeats > 0 {
+		repeats, err := facts.RecentRepeats(ctx, body, time.Duration(r.When.RepeatWindow))
+		if err != nil {
+			return false, err
+		}
+		if repeats+1 < r.When.Repeats {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+type cachedFacts struct {
+	facts      Facts
+	accountAge *time.Duration
+	repeats    map[time.Duration]int
+}
+
+func (c *cachedFacts) AccountAge(ctx context.Context) (time.Duration, error) {
+	if c.accountAge == nil {
+		age, err := c.facts.AccountAge(ctx)
+		if err != nil {
+			return 0, err
+		}
+		c.accountAge = &age
+	}
+	return *c.accountAge, nil
+}
+
+// RecentRepeats caches by window alone: an evaluation asks about one body.
+func (c *cachedFacts) RecentRepeats(ctx context.Context, body string, window time.Duration) (int, error) {
+	if repeats, ok := c.repeats[window]; ok {
+		return repeats, nil
+	}
+	repeats, err := c.facts.RecentRepeats(ctx, body, window)
+	if err != nil {
+		return 0, err
+	}
+	if c.repeats == nil {
+		c.repeats = make(map[time.Duration]int)
+	}
+	c.repeats[window] = repeats
+	return repeats, nil
+}
@@ -0,0 +1,302 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeFacts struct {
+	age         time.Duration
+	repeats     int
+	ageLookups  int
+	repeatCalls int
+}
+
+func (f *fakeFacts) AccountAge(ctx context.Context) (time.Duration, error) {
+	f.ageLookups++
+	return f.age, nil
+}
+
+func (f *fakeFacts) RecentRepeats(ctx context.Context, body string, window time.Duration) (int, error) {
+	f.repeatCalls++
+	return f.repeats, nil
+}
+
+func mustEngine(t *testing.T, config string) *Engine {
+	t.Helper()
+	parsed, err := ParseConfig([]byte(config))
+	if err != nil {
+		t.Fatalf("ParseConfig() returned error: %v", err)
+	}
+	engine, err := NewEngine(parsed)
+	if err != nil {
+		t.Fatalf("NewEngine() returned error: %v", err)
+	}
+	return engine
+}
+
+func TestDefaultConfigMasksBadWords(t *testing.T) {
+	engine, err := NewEngine(DefaultConfig())
+	if err != nil {
+		t.Fatalf("NewEngine() returned error: %v", err)
+	}
+	decision, err := engine.Evaluate(context.Background(), "What a Kerfuffle this is, sharbert! fornax", &fakeFacts{})
+	if err != nil {
+		t.Fatalf("Evaluate() returned error: %v", err)
+	}
+	if decision.Action != ActionMask {
+		t.Errorf("Action = %q, want %q", decision.Action, ActionMask)
+	}
+	if want := "What a **** this is, sharbert! ****"; decision.Body != want {
+		t.Errorf("Body = %q, want %q", decision.Body, want)
+	}
+}
+
+func TestLinkSpamFromNewAccountIsHeld(t *testing.T) {
+	engine := mustEngine(t, `{"rules": [
+		{"name": "link-spam", "action": "hold", "message": "Held for review.",
+		 "when": {"links_more_than": 3, "account_younger_than": "24h"}}
+	]}`)
+	links := "https://a.example www.b.example http://c.example https://d.example"
+
+	tests := []struct {
+		name string
+		body string
+		age  time.Duration
+		want Action
+	}{
+		{"new account with many links", links, time.Hour, ActionHold},
+		{"old account with many links", links, 48 * time.Hour, ActionAllow},
+		{"new account with few links", "https://a.example and www.b.example", time.Hour, ActionAllow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := engine.Evaluate(context.Background(), tt.body, &fakeFacts{age: tt.age})
+			if err != nil {
+				t.Fatalf("Evaluate() returned error: %v", err)
+			}
+			if decision.Action != tt.want {
+				t.Errorf("Action = %q, want %q", decision.Action, tt.want)
+			}
+			if tt.want == ActionHold && decision.Message != "Held for review." {
+				t.Errorf("Message = %q, want the rule's message", decision.Message)
+			}
+		})
+	}
+}
+
+func TestFactsAreOnlyLookedUpWhenNeeded(t *testing.T) {
+	engine := mustEngine(t, `{"rules": [
+		{"name": "link-spam", "action": "hold", "when": {"links_more_than": 3, "account_younger_than": "24h"}},
+		{"name": "new-accounts", "action": "shadow_hide", "when": {"pattern": "buy now", "account_younger_than": "1h"}}
+	]}`)
+	facts := &fakeFacts{age: time.Minute}
+	if _, err := engine.Evaluate(context.Background(), "hello there", facts); err != nil {
+		t.Fatalf("Evaluate() returned error: %v", err)
+	}
+	if facts.ageLookups != 0 {
+		t.Errorf("account age was looked up %d times for a body no rule could match", facts.ageLookups)
+	}
+
+	decision, err := engine.Evaluate(context.Background(), "buy now www.a.example www.b.example www.c.example www.d.example", facts)
+	if err != nil {
+		t.Fatalf("Evaluate() returned error: %v", err)
+	}
+	if facts.ageLookups != 1 {
+		t.Errorf("account age was looked up %d times, want once", facts.ageLookups)
+	}
+	if decision.Action != ActionShadowHide || strings.Join(decision.Matched, ",") != "link-spam,new-accounts" {
+		t.Errorf("got %q from %v, want shadow_hide from both rules", decision.Action, decision.Matched)
+	}
+}
+
+func TestRepeatsCountTheChirpBeingPosted(t *testing.T) {
+	engine := mustEngine(t, `{"rules": [
+		{"name": "flooding", "action": "reject", "when": {"repeats": 5, "repeat_window": "1m"}}
+	]}`)
+	for repeats, want := range map[int]Action{3: ActionAllow, 4: ActionReject, 9: ActionReject} {
+		decision, err := engine.Evaluate(context.Background(), "same again", &fakeFacts{repeats: repeats})
+		if err != nil {
+			t.Fatalf("Evaluate() returned error: %v", err)
+		}
+		if decision.Action != want {
+			t.Errorf("with %d earlier repeats: Action = %q, want %q", repeats, decision.Action, want)
+		}
+	}
+}
+
+// postedFacts remembers every chirp posted, as written and as stored.
+type postedFacts struct {
+	written []string
+	stored  []string
+}
+
+func (f *postedFacts) AccountAge(ctx context.Context) (time.Duration, error) {
+	return time.Hour, nil
+}
+
+func (f *postedFacts) RecentRepeats(ctx context.Context, body string, window time.Duration) (int, error) {
+	repeats := 0
+	for _, written := range f.written {
+		if written == body {
+			repeats++
+		}
+	}
+	return repeats, nil
+}
+
+func TestRepeatsOfMaskedChirps(t *testing.T) {
+	engine := mustEngine(t, `{"rules": [
+		{"name": "profanity", "action": "mask", "when": {"words": ["kerfuffle"]}},
+		{"name": "flooding", "action": "reject", "when": {"repeats": 3, "repeat_window": "1m"}}
+	]}`)
+	facts := &postedFacts{}
+	body := "what a kerfuffle"
+	for i, want := range []Action{ActionMask, ActionMask, ActionReject} {
+		decision, err := engine.Evaluate(context.Background(), body, facts)
+		if err != nil {
+			t.Fatalf("Evaluate() returned error: %v", err)
+		}
+		if decision.Action != want {
+			t.Fatalf("post %d: Action = %q, want %q", i+1, decision.Action, want)
+		}
+		if decision.Action == ActionMask {
+			facts.written = append(facts.written, body)
+			facts.stored = append(facts.stored, decision.Body)
+		}
+	}
+	if facts.stored[0] == body {
+		t.Errorf("expected the stored chirps to be masked, got %q", facts.stored[0])
+	}
+}
+
+func TestAllowStopsEvaluation(t *testing.T) {
+	engine := mustEngine(t, `{"rules": [
+		{"name": "announcements", "action": "allow", "when": {"pattern": "^\\[announcement\\]"}},
+		{"name": "links", "action": "reject", "when": {"links_more_than": 0}}
+	]}`)
+	decision, err := engine.Evaluate(context.Background(), "[announcement] see https://chirpy.example", &fakeFacts{})
+	if err != nil {
+		t.Fatalf("Evaluate() returned error: %v", err)
+	}
+	if decision.Action != ActionAllow || strings.Join(decision.Matched, ",") != "announcements" {
+		t.Errorf("got %q from %v, want allow from announcements only", decision.Action, decision.Matched)
+	}
+}
+
+func TestDryRunRulesOnlyReport(t *testing.T) {
+	engine := mustEngine(t, `{"rules": [
+		{"name": "links", "action": "reject", "dry_run": true, "when": {"links_more_than": 0}},
+		{"name": "shouting", "action": "mask", "when": {"pattern": "[A-Z]{5,}"}}
+	]}`)
+	decision, err := engine.Evaluate(context.Background(), "LOOK AT https://chirpy.example NOWWWW", &fakeFacts{})
+	if err != nil {
+		t.Fatalf("Evaluate() returned error: %v", err)
+	}
+	if decision.Action != ActionMask || decision.Body != "LOOK AT https://chirpy.example ****" {
+		t.Errorf("got %q with body %q, want the mask to apply", decision.Action, decision.Body)
+	}
+	if len(decision.DryRun) != 1 || decision.DryRun[0] != (DryRunMatch{Rule: "links", Action: ActionReject}) {
+		t.Errorf("DryRun = %v, want the links rule", decision.DryRun)
+	}
+
+	engine = mustEngine(t, `{"dry_run": true, "rules": [
+		{"name": "links", "action": "reject", "when": {"links_more_than": 0}}
+	]}`)
+	decision, err = engine.Evaluate(context.Background(), "https://chirpy.example", &fakeFacts{})
+	if err != nil {
+		t.Fatalf("Evaluate() returned error: %v", err)
+	}
+	if decision.Action != ActionAllow || len(decision.DryRun) != 1 {
+		t.Errorf("global dry run: got %q with dry-run matches %v", decision.Action, decision.DryRun)
+	}
+}
+
+func TestInvalidConfigs(t *testing.T) {
+	tests := map[string]string{
+		"unknown action":     `{"rules": [{"name": "a", "action": "delete"}]}`,
+		"missing name":       `{"rules": [{"action": "hold"}]}`,
+		"duplicate name":     `{"rules": [{"name": "a", "action": "hold"}, {"name": "a", "action": "reject"}]}`,
+		"bad pattern":        `{"rules": [{"name": "a", "action": "hold", "when": {"pattern": "("}}]}`,
+		"mask without words": `{"rules": [{"name": "a", "action": "mask", "when": {"links_more_than": 1}}]}`,
+		"repeats alone":      `{"rules": [{"name": "a", "action": "reject", "when": {"repeats": 5}}]}`,
+		"bad duration":       `{"rules": [{"name": "a", "action": "hold", "when": {"account_younger_than": 3600}}]}`,
+		"misspelt condition": `{"rules": [{"name": "a", "action": "hold", "when": {"link_more_than": 1}}]}`,
+	}
+	for name, config := range tests {
+		t.Run(name, func(t *testing.T) {
+			parsed, err := ParseConfig([]byte(config))
+			if err == nil {
+				_, err = NewEngine(parsed)
+			}
+			if err == nil {
+				t.Errorf("config was accepted: %s", config)
+			}
+		})
+	}
+}
+
+func TestReloadKeepsRulesOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	write := func(config string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+			t.Fatalf("WriteFile() returned error: %v", err)
+		}
+	}
+	evaluate := func(engine *Engine) Action {
+		t.Helper()
+		decision, err := engine.Evaluate(context.Background(), "spam spam", &fakeFacts{})
+		if err != nil {
+			t.Fatalf("Evaluate() returned error: %v", err)
+		}
+		return decision.Action
+	}
+
+	write(`{"rules": [{"name": "spam", "action": "hold", "when": {"words": ["spam"]}}]}`)
+	engine, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() returned error: %v", err)
+	}
+	if got := evaluate(engine); got != ActionHold {
+		t.Fatalf("Action = %q, want %q", got, ActionHold)
+	}
+
+	if changed, err := engine.Reload(context.Background()); err != nil || changed {
+		t.Errorf("Reload() of an unchanged file = %v, %v; want false, nil", changed, err)
+	}
+
+	write(`{"rules": [{"name": "spam", "action": "reject", "when": {"words": ["spam"]}}]}`)
+	if changed, err := engine.Reload(context.Background()); err != nil || !changed {
+		t.Fatalf("Reload() = %v, %v; want true, nil", changed, err)
+	}
+	if got := evaluate(engine); got != ActionReject {
+		t.Errorf("after reload Action = %q, want %q", got, ActionReject)
+	}
+
+	write(`{"rules": [{"name": "spam", "action": "obliterate"}]}`)
+	if _, err := engine.Reload(context.Background()); err == nil {
+		t.Error("Reload() accepted an invalid file")
+	}
+	if got := evaluate(engine); got != ActionReject {
+		t.Errorf("after a failed reload Action = %q, want the previous rules' %q", got, ActionReject)
+	}
+}
+
+type failingFacts struct{ fakeFacts }
+
+func (f *failingFacts) AccountAge(ctx context.Context) (time.Duration, error) {
+	return 0, errors.New("database is down")
+}
+
+func TestEvaluateReturnsFactErrors(t *testing.T) {
+	engine := mustEngine(t, `{"rules": [{"name": "new", "action": "hold", "when": {"account_younger_than": "1h"}}]}`)
+	if _, err := engine.Evaluate(context.Background(), "hi", &failingFacts{}); err == nil {
+		t.Error("Evaluate() swallowed the facts error")
+	}
+}
@@ -0,0 +1,207 @@
+package moderation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Action is what a matching rule does to a chirp.
+type Action string
+
+// Actions, from least to most severe. When several rules match, the most
+// severe action wins; allow is special in that it ends evaluation.
+const (
+	// ActionAllow publishes the chirp as it is and skips the rules after it,
+	// so it can carve out exceptions from broader rules.
+	ActionAllow Action = "allow"
+	// ActionMask replaces whatever the rule's words or pattern matched with
+	// asterisks and publishes the rest.
+	ActionMask Action = "mask"
+	// ActionHold keeps the chirp out of public reads until a moderator
+	// approves it. The author is told.
+	ActionHold Action = "hold"
+	// ActionShadowHide keeps the chirp out of public reads without telling
+	// the author, who still sees it as published.
+	ActionShadowHide Action = "shadow_hide"
+	// ActionReject refuses the chirp.
+	ActionReject Action = "reject"
+)
+
+func (a Action) severity() int {
+	switch a {
+	case ActionMask:
+		return 1
+	case ActionHold:
+		return 2
+	case ActionShadowHide:
+		return 3
+	case ActionReject:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// Duration is a time.Duration written as a string such as "24h" or "1m".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New("durations are strings such as \"90s\" or \"24h\"")
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Config is the rules file. Rules run in order.
+type Config struct {
+	// DryRun makes every rule dry-run.
+	DryRun bool   `json:"dry_run,omitempty"`
+	Rules  []Rule `json:"rules"`
+}
+
+// Rule applies Action to chirps that meet every condition in When. A rule
+// without conditions matches every chirp.
+type Rule struct {
+	Name   string     `json:"name"`
+	When   Conditions `json:"when"`
+	Action Action     `json:"action"`
+	// Message is shown to the author when the chirp is rejected or held.
+	Message string `json:"message,omitempty"`
+	// DryRun rules are reported in Decision.DryRun instead of taking effect,
+	// which is how a new rule is tried out.
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+type Conditions struct {
+	// Words matches when any space separated word of the body equals one of
+	// these, ignoring case.
+	Words []string `json:"words,omitempty"`
+	// Pattern is a regular expression (RE2 syntax) the body has to match.
+	Pattern string `json:"pattern,omitempty"`
+	// LinksMoreThan matches bodies with more links than this.
+	LinksMoreThan *int `json:"links_more_than,omitempty"`
+	// AccountYoungerThan matches authors whose account is newer than this.
+	AccountYoungerThan Duration `json:"account_younger_than,omitempty"`
+	// Repeats matches when the author has posted the same body this many
+	// times within RepeatWindow, counting the chirp being posted.
+	Repeats      int      `json:"repeats,omitempty"`
+	RepeatWindow Duration `json:"repeat_window,omitempty"`
+}
+
+// DefaultConfig is used when no rules file is configured. It masks the words
+// Chirpy has always filtered.
+func DefaultConfig() Config {
+	return Config{Rules: []Rule{{
+		Name:   "profanity",
+		When:   Conditions{Words: []string{"kerfuffle", "sharbert", "fornax"}},
+		Action: ActionMask,
+	}}}
+}
+
+// rule is a Rule ready to be evaluated.
+type rule struct {
+	Rule
+	words   map[string]bool
+	pattern *regexp.Regexp
+}
+
+func compile(config Config) ([]rule, error) {
+	rules := make([]rule, 0, len(config.Rules))
+	names := make(map[string]bool, len(config.Rules))
+	for i, r := range config.Rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("rule %d: name is required", i+1)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("rule %q: duplicate name", r.Name)
+		}
+		names[r.Name] = true
+
+		switch r.Action {
+		case ActionAllow, ActionMask, ActionHold, ActionShadowHide, ActionReject:
+		default:
+			return nil, fmt.Errorf("rule %q: unknown action %q", r.Name, r.Action)
+		}
+
+		compiled := rule{Rule: r}
+		if len(r.When.Words) > 0 {
+			compiled.words = make(map[string]bool, len(r.When.Words))
+			for _, word := range r.When.Words {
+				compiled.words[strings.ToLower(word)] = true
+			}
+		}
+		if r.When.Pattern != "" {
+			pattern, err := regexp.Compile(r.When.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+			}
+			compiled.pattern = pattern
+		}
+		if r.Action == ActionMask && compiled.words == nil && compiled.pattern == nil {
+			return nil, fmt.Errorf("rule %q: mask needs words or a pattern to mask", r.Name)
+		}
+		if r.When.LinksMoreThan != nil && *r.When.LinksMoreThan < 0 {
+			return nil, fmt.Errorf("rule %q: links_more_than must not be negative", r.Name)
+		}
+		if r.When.AccountYoungerThan < 0 {
+			return nil, fmt.Errorf("rule %q: account_younger_than must not be negative", r.Name)
+		}
+		if (r.When.Repeats > 0) != (r.When.RepeatWindow > 0) || r.When.Repeats < 0 {
+			return nil, fmt.Errorf("rule %q: repeats and repeat_window go together", r.Name)
+		}
+		if config.DryRun {
+			compiled.DryRun = true
+		}
+		rules = append(rules, compiled)
+	}
+	return rules, nil
+}
+
+// linkPattern finds things that look like links, with or without a scheme.
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+func countLinks(body string) int {
+	return len(linkPattern.FindAllStringIndex(body, -1))
+}
+
+// mask replaces the words and pattern matches of r in body with asterisks.
+// Words are matched the way Chirpy always has: splitting on single spaces, so
+// punctuation next to a word protects it.
+func (r rule) mask(body string) string {
+	if r.words != nil {
+		words := strings.Split(body, " ")
+		for i, word := range words {
+			if r.words[strings.ToLower(word)] {
+				words[i] = "****"
+			}
+		}
+		body = strings.Join(words, " ")
+	}
+	if r.pattern != nil {
+		body = r.pattern.ReplaceAllString(body, "****")
+	}
+	return body
+}
+
+func (r rule) matchesWords(body string) bool {
+	for _, word := range strings.Split(body, " ") {
+		if r.words[strings.ToLower(word)] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"slices"
+	"testing"
+)
+
+func s256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestParseScopes(t *testing.T) {
+	got := ParseScopes("  chirps:read chirps:write\tchirps:read ")
+	want := []string{"chirps:read", "chirps:write"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ParseScopes() = %v, want %v", got, want)
+	}
+	if got := ParseScopes(""); len(got) != 0 {
+		t.Errorf("ParseScopes(\"\") = %v, want no scopes", got)
+	}
+}
+
+func TestVerifyPKCE(t *testing.T) {
+	verifier := "dBjftJeZ4CVP-mJ92IyuQlGYK0fGw5rdLjdQNnmYM-I"
+	challenge := "YOAl1c_-vHva91TSJsg9_e2ZaXWJXFSshtHT1QcLwuw"
+
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		want      bool
+	}{
+		{"matching verifier", verifier, challenge, true},
+		{"wrong verifier", verifier[:42] + "J", challenge, false},
+		{"plain challenge", verifier, verifier, false},
+		{"verifier too short", "short", s256("short"), false},
+		{"invalid characters", verifier[:42] + "+", s256(verifier[:42] + "+"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyPKCE(tt.verifier, tt.challenge); got != tt.want {
+				t.Errorf("VerifyPKCE() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRedirectURI(t *testing.T) {
+	tests := []struct {
+		uri     string
+		wantErr bool
+	}{
+		{"https://app.example.com/callback", false},
+		{"http://localhost:8080/callback", false},
+		{"http://127.0.0.1:49152/callback", false},
+		{"http://[::1]/callback", false},
+		{"http://app.example.com/callback", true},
+		{"https://app.example.com/callback#token", true},
+		{"/callback", true},
+		{"javascript:alert(1)", true},
+		{"com.example.app:/callback", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			err := ValidateRedirectURI(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRedirectURI(%q) error = %v, wantErr %v", tt.uri, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+// Package oidc implements the relying party side of OpenID Connect: the
+// authorization code flow with PKCE, and verification of the ID tokens it
+// returns against the provider's published keys.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config describes one provider registration.
+type Config struct {
+	// Issuer is the provider's issuer URL; its discovery document is served
+	// from Issuer + "/.well-known/openid-configuration".
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes are requested in addition to "openid".
+	Scopes []string
+}
+
+// Metadata is the part of the discovery document the flow uses.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// IDToken holds the verified claims of an ID token.
+type IDToken struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// ErrUnverifiedEmail means the provider didn't vouch for the identity's
+// email address, so it can't be linked to an account by email.
+var ErrUnverifiedEmail = errors.New("identity has no verified email")
+
+// LinkEmail returns the address an identity is linked to an account by: its
+// email, trimmed and lowercased so that a provider's capitalisation can't
+// create a second account. Only addresses the provider has verified count,
+// since linking by email hands over the account that has it.
+func LinkEmail(token IDToken) (string, error) {
+	email := strings.ToLower(strings.TrimSpace(token.Email))
+	if email == "" || !token.EmailVerified {
+		return "", ErrUnverifiedEmail
+	}
+	return email, nil
+}
+
+// Client talks to one provider. Discovery and key fetching happen on first
+// use, so a provider being down doesn't keep the server from starting.
+type Client struct {
+	config     Config
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	metadata *Metadata
+	keys     map[string]any
+}
+
+func NewClient(config Config, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Client{config: config, httpClient: httpClient}
+}
+
+// NewRandomString returns a URL-safe random string for states, nonces and
+// PKCE verifiers.
+func NewRandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("couldn't read random bytes: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// PKCEChallenge derives the S256 code challenge of verifier (RFC 7636).
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the provider URL to send the user's browser to.
+func (c *Client) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	metadata, err := c.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	authURL, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("malformed authorization endpoint: %w", err)
+	}
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", c.config.ClientID)
+	query.Set("redirect_uri", c.config.RedirectURL)
+	query.Set("scope", strings.Join(append([]string{"openid"}, c.config.Scopes...), " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", PKCEChallenge(verifier))
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+	return authURL.String(), nil
+}
+
+// Exchange trades an authorization code for tokens and returns the verified
+// ID token. nonce must be the one sent with the authorization request.
+func (c *Client) Exchange(ctx context.Context, code, verifier, nonce string) (IDToken, error) {
+	metadata, err := c.discover(ctx)
+	if err != nil {
+		return IDToken{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.config.RedirectURL)
+	form.Set("code_verifier", verifier)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return IDToken{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.config.ClientID), url.QueryEscape(c.config.ClientSecret))
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err := c.doJSON(req, &tokens); err != nil {
+		return IDToken{}, fmt.Errorf("token request failed: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return IDToken{}, errors.New("token response has no id_token")
+	}
+	return c.VerifyIDToken(ctx, tokens.IDToken, nonce)
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	AuthorizedBy  string `json:"azp"`
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of
+// an ID token.
+func (c *Client) VerifyIDToken(ctx context.Context, raw, nonce string) (IDToken, error) {
+	metadata, err := c.discover(ctx)
+	if err != nil {
+		return IDToken{}, err
+	}
+
+	claims := idTokenClaims{}
+	_, err = jwt.ParseWithClaims(raw, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return c.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(c.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return IDToken{}, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if claims.Subject == "" {
+		return IDToken{}, errors.New("invalid ID token: missing sub")
+	}
+	if claims.Nonce == "" || claims.Nonce != nonce {
+		return IDToken{}, errors.New("invalid ID token: nonce mismatch")
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedBy != c.config.ClientID {
+		return IDToken{}, errors.New("invalid ID token: issued to another party")
+	}
+
+	// Some providers send email_verified as the string "true".
+	verified := claims.EmailVerified == true || claims.EmailVerified == "true"
+	return IDToken{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: verified,
+		Name:          claims.Name,
+	}, nil
+}
+
+func (c *Client) discover(ctx context.Context) (*Metadata, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.metadata != nil {
+		return c.metadata, nil
+	}
+
+	wellKnown := strings.TrimSuffix(c.config.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+	metadata := &Metadata{}
+	if err := c.doJSON(req, metadata); err != nil {
+		return nil, fmt.Errorf("discovery failed: %w", err)
+	}
+	if metadata.Issuer != c.config.Issuer {
+		return nil, fmt.Errorf("discovery document names issuer %q, expected %q", metadata.Issuer, c.config.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("discovery document is missing endpoints")
+	}
+	c.metadata = metadata
+	return metadata, nil
+}
+
+// key returns the verification key named kid, fetching the provider's JWKS
+// again when it isn't known yet, which is how providers roll their keys.
+func (c *Client) key(ctx context.Context, kid string) (any, error) {
+	c.mu.Lock()
+	key, ok := c.keys[kid]
+	jwksURI := c.metadata.JWKSURI
+	c.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set jwkSet
+	if err := c.doJSON(req, &set); err != nil {
+		return nil, fmt.Errorf("couldn't fetch provider keys: %w", err)
+	}
+	keys := make(map[string]any, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		public, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.KeyID] = public
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.mu.Unlock()
+	key, ok = keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (c *Client) doJSON(req *http.Request, v any) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s: %s", req.URL, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,322 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockProvider is a minimal OpenID provider that issues codes for a fixed
+// user and checks PKCE and client credentials on the token endpoint.
+type mockProvider struct {
+	t        *testing.T
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+	secret   string
+
+	mu     sync.Mutex
+	codes  map[string]pendingCode
+	claims jwt.MapClaims
+}
+
+type pendingCode struct {
+	challenge string
+	nonce     string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("couldn't generate key: %v", err)
+	}
+	p := &mockProvider{t: t, key: key, clientID: "chirpy", secret: "s3cret", codes: map[string]pendingCode{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Metadata{
+			Issuer:                p.server.URL,
+			AuthorizationEndpoint: p.server.URL + "/authorize",
+			TokenEndpoint:         p.server.URL + "/token",
+			JWKSURI:               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwkSet{Keys: []jwk{{
+			KeyType: "RSA",
+			KeyID:   "test-key",
+			Use:     "sig",
+			N:       base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("GET /authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("code_challenge_method") != "S256" || query.Get("client_id") != p.clientID {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		p.mu.Lock()
+		p.codes["code-1"] = pendingCode{challenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+		p.mu.Unlock()
+		redirect, _ := url.Parse(query.Get("redirect_uri"))
+		redirect.RawQuery = url.Values{"code": {"code-1"}, "state": {query.Get("state")}}.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != p.clientID || secret != p.secret {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		p.mu.Lock()
+		pending, found := p.codes[r.FormValue("code")]
+		delete(p.codes, r.FormValue("code"))
+		p.mu.Unlock()
+		if !found || PKCEChallenge(r.FormValue("code_verifier")) != pending.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "opaque",
+			"token_type":   "Bearer",
+			"id_token":     p.sign(pending.nonce),
+		})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	p.claims = jwt.MapClaims{
+		"sub":            "user-42",
+		"email":          "walt@breakingbad.com",
+		"email_verified": true,
+		"name":           "Walter White",
+	}
+	return p
+}
+
+func (p *mockProvider) sign(nonce string) string {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   p.server.URL,
+		"aud":   p.clientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"nonce": nonce,
+	}
+	for k, v := range p.claims {
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test-key"
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		p.t.Fatalf("couldn't sign ID token: %v", err)
+	}
+	return signed
+}
+
+func (p *mockProvider) client() *Client {
+	return NewClient(Config{
+		Issuer:       p.server.URL,
+		ClientID:     p.clientID,
+		ClientSecret: p.secret,
+		RedirectURL:  "https://chirpy.test/api/oidc/mock/callback",
+		Scopes:       []string{"email", "profile"},
+	}, p.server.Client())
+}
+
+// authorize follows the authorization URL like a browser and returns the
+// query of the redirect back to Chirpy.
+func authorize(t *testing.T, authURL string) url.Values {
+	t.Helper()
+	httpClient := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := httpClient.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorization request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected a redirect, got %s", resp.Status)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("malformed redirect: %v", err)
+	}
+	return location.Query()
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	provider := newMockProvider(t)
+	client := provider.client()
+	ctx := context.Background()
+
+	state, _ := NewRandomString()
+	nonce, _ := NewRandomString()
+	verifier, _ := NewRandomString()
+	authURL, err := client.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL returned error: %v", err)
+	}
+	if !strings.Contains(authURL, "scope=openid+email+profile") {
+		t.Errorf("expected the openid scope to be requested, got %s", authURL)
+	}
+
+	callback := authorize(t, authURL)
+	if callback.Get("state") != state {
+		t.Fatalf("state was not passed back")
+	}
+
+	idToken, err := client.Exchange(ctx, callback.Get("code"), verifier, nonce)
+	if err != nil {
+		t.Fatalf("Exchange returned error: %v", err)
+	}
+	want := IDToken{Subject: "user-42", Email: "walt@breakingbad.com", EmailVerified: true, Name: "Walter White"}
+	if idToken != want {
+		t.Errorf("got %+v, want %+v", idToken, want)
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	provider := newMockProvider(t)
+	client := provider.client()
+	ctx := context.Background()
+
+	authURL, _ := client.AuthCodeURL(ctx, "state", "nonce", "the-real-verifier")
+	callback := authorize(t, authURL)
+	if _, err := client.Exchange(ctx, callback.Get("code"), "another-verifier", "nonce"); err == nil {
+		t.Error("expected the exchange to fail with the wrong PKCE verifier")
+	}
+}
+
+func TestVerifyIDTokenChecks(t *testing.T) {
+	provider := newMockProvider(t)
+	client := provider.client()
+	ctx := context.Background()
+
+	if _, err := client.VerifyIDToken(ctx, provider.sign("nonce"), "another-nonce"); err == nil {
+		t.Error("expected a nonce mismatch to be rejected")
+	}
+
+	provider.claims["aud"] = "someone-else"
+	if _, err := client.VerifyIDToken(ctx, provider.sign("nonce"), "nonce"); err == nil {
+		t.Error("expected a token for another audience to be rejected")
+	}
+	delete(provider.claims, "aud")
+
+	provider.claims["iss"] = "https://evil.test"
+	if _, err := client.VerifyIDToken(ctx, provider.sign("nonce"), "nonce"); err == nil {
+		t.Error("expected a token from another issuer to be rejected")
+	}
+	delete(provider.claims, "iss")
+
+	provider.claims["exp"] = time.Now().Add(-time.Hour).Unix()
+	if _, err := client.VerifyIDToken(ctx, provider.sign("nonce"), "nonce"); err == nil {
+		t.Error("expected an expired token to be rejected")
+	}
+	delete(provider.claims, "exp")
+
+	forged := provider.sign("nonce")
+	forged = forged[:len(forged)-4] + "AAAA"
+	if _, err := client.VerifyIDToken(ctx, forged, "nonce"); err == nil {
+		t.Error("expected a token with a bad signature to be rejected")
+	}
+}
+
+func TestVerifyIDTokenStringEmailVerified(t *testing.T) {
+	provider := newMockProvider(t)
+	provider.claims["email_verified"] = "true"
+	idToken, err := provider.client().VerifyIDToken(context.Background(), provider.sign("nonce"), "nonce")
+	if err != nil {
+		t.Fatalf("VerifyIDToken returned error: %v", err)
+	}
+	if !idToken.EmailVerified {
+		t.Error("expected email_verified \"true\" to count as verified")
+	}
+}
+
+func TestDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	provider := newMockProvider(t)
+	client := NewClient(Config{Issuer: provider.server.URL + "/", ClientID: "chirpy"}, provider.server.Client())
+	if _, err := client.AuthCodeURL(context.Background(), "s", "n", "v"); err == nil {
+		t.Error("expected a discovery document for another issuer to be rejected")
+	}
+}
+
+func TestPKCEChallenge(t *testing.T) {
+	// BASE64URL(SHA256(verifier)) without padding, as RFC 7636 section 4.2
+	// defines S256.
+	if got := PKCEChallenge("chirpy-pkce-verifier"); got != "rm_CzV8KYkjiy6bX_rPGwu5MDgonhGF5Dl2OFy9aP1s" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestStateCookie(t *testing.T) {
+	state, err := NewRandomString()
+	if err != nil {
+		t.Fatalf("NewRandomString() returned error: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	http.SetCookie(rec, StateCookie(state, "/api/oidc/acme", true, 10*time.Minute))
+	cookie := rec.Result().Cookies()[0]
+	if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/api/oidc/acme" {
+		t.Errorf("StateCookie() = %+v, want an HttpOnly, Secure, SameSite=Lax cookie on the provider's path", cookie)
+	}
+
+	callback := func(cookies ...*http.Cookie) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/oidc/acme/callback?state="+state, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		return req
+	}
+	if err := CheckState(callback(cookie), state); err != nil {
+		t.Errorf("CheckState() with the browser's cookie = %v, want nil", err)
+	}
+
+	other := *cookie
+	other.Value = "attacker-state"
+	for name, req := range map[string]*http.Request{
+		"no cookie":     callback(),
+		"other browser": callback(&other),
+	} {
+		if err := CheckState(req, state); err != ErrStateMismatch {
+			t.Errorf("CheckState() with %s = %v, want ErrStateMismatch", name, err)
+		}
+	}
+	if err := CheckState(callback(cookie), ""); err != ErrStateMismatch {
+		t.Errorf("CheckState() with no state = %v, want ErrStateMismatch", err)
+	}
+}
+
+func TestLinkEmail(t *testing.T) {
+	email, err := LinkEmail(IDToken{Subject: "248289761001", Email: " Alice@Example.COM ", EmailVerified: true})
+	if err != nil || email != "alice@example.com" {
+		t.Errorf("LinkEmail() = %q, %v, want alice@example.com", email, err)
+	}
+
+	for name, token := range map[string]IDToken{
+		"unverified email": {Subject: "248289761001", Email: "alice@example.com"},
+		"no email":         {Subject: "248289761001", EmailVerified: true},
+		"blank email":      {Subject: "248289761001", Email: "  ", EmailVerified: true},
+	} {
+		if _, err := LinkEmail(token); err != ErrUnverifiedEmail {
+			t.Errorf("LinkEmail() with %s = %v, want ErrUnverifiedEmail", name, err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package oidc
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const stateCookieName = "chirpy_oidc_state"
+
+// ErrStateMismatch means the callback didn't come from the browser that
+// started the login.
+var ErrStateMismatch = errors.New("login state doesn't match this browser")
+
+// StateCookie ties state to the browser that starts a login, so that nobody
+// can finish a login they started in someone else's browser by getting them
+// to open the callback URL. SameSite=Lax still sends it on the provider's
+// redirect back, which is a top-level GET.
+func StateCookie(state, path string, secure bool, ttl time.Duration) *http.Cookie {
+	return &http.Cookie{
+		Name:     stateCookieName,
+		Value:    state,
+		Path:     path,
+		MaxAge:   int(ttl.Seconds()),
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// ClearStateCookie removes the cookie StateCookie set.
+func ClearStateCookie(path string, secure bool) *http.Cookie {
+	cookie := StateCookie("", path, secure, 0)
+	cookie.MaxAge = -1
+	return cookie
+}
+
+// CheckState returns ErrStateMismatch unless req carries the cookie
+// StateCookie set for state.
+func CheckState(req *http.Request, state string) error {
+	cookie, err := req.Cookie(stateCookieName)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		return ErrStateMismatch
+	}
+	return nil
+}
@@ -2,34 +2,24 @@ package auth
 
 import (
 	"log"
-	"unsafe"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
-func StringToBytes(string_ string) (bytes []byte) {
-	return unsafe.Slice(unsafe.StringData(string_), len(string_))
-}
-
-func BytesToString(bytes []byte) string {
-	return unsafe.String(unsafe.SliceData(bytes), len(bytes))
-}
-
+// HashPassword hashes password with bcrypt at the default cost. New hashes
+// should come from a PasswordHasher; this stays for the existing bcrypt
+// hashes and their tests.
 func HashPassword(password string) (string, error) {
-	passwordBytes := StringToBytes(password)
-	hashedPasswordBytes, err := bcrypt.GenerateFromPassword(passwordBytes, bcrypt.DefaultCost)
+	hashedPasswordBytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		log.Printf("An error ocurred during hashing process: %v", err)
 		return "", err
 	}
-	hashedPassword := BytesToString(hashedPasswordBytes)
-	return hashedPassword, nil
+	return string(hashedPasswordBytes), nil
 }
 
 func CheckPasswordHash(hash, password string) error {
-	hashBytes := StringToBytes(hash)
-	passwordBytes := StringToBytes(password)
-	if err := bcrypt.CompareHashAndPassword(hashBytes, passwordBytes); err != nil {
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
 		log.Printf("The password is incorrect: %v", err)
 		return err
 	}
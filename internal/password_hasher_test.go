@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testArgon2idParams keep the tests fast; production uses
+// DefaultArgon2idParams.
+var testArgon2idParams = Argon2idParams{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestArgon2idHasherRoundTrip(t *testing.T) {
+	hasher := NewArgon2idHasher(testArgon2idParams)
+	hash, err := hasher.Hash("LumianLee")
+	if err != nil {
+		t.Fatalf("Hash returned error: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("unexpected hash format %q", hash)
+	}
+
+	needsRehash, err := hasher.Verify(hash, "LumianLee")
+	if err != nil {
+		t.Fatalf("Verify returned error for the right password: %v", err)
+	}
+	if needsRehash {
+		t.Error("expected a hash with the current parameters not to need rehashing")
+	}
+
+	if _, err := hasher.Verify(hash, "KleinMoretti"); !errors.Is(err, ErrPasswordMismatch) {
+		t.Errorf("expected ErrPasswordMismatch for the wrong password, got %v", err)
+	}
+}
+
+func TestArgon2idHasherAcceptsLongPasswords(t *testing.T) {
+	hasher := NewArgon2idHasher(testArgon2idParams)
+	long := strings.Repeat("a", 100)
+	hash, err := hasher.Hash(long)
+	if err != nil {
+		t.Fatalf("Hash returned error: %v", err)
+	}
+	if _, err := hasher.Verify(hash, strings.Repeat("a", 72)); !errors.Is(err, ErrPasswordMismatch) {
+		t.Error("expected passwords to be compared beyond 72 bytes")
+	}
+}
+
+func TestArgon2idHasherRecognisesBcrypt(t *testing.T) {
+	hasher := NewArgon2idHasher(testArgon2idParams)
+	legacy, err := HashPassword("LumianLee")
+	if err != nil {
+		t.Fatalf("HashPassword returned error: %v", err)
+	}
+
+	needsRehash, err := hasher.Verify(legacy, "LumianLee")
+	if err != nil {
+		t.Fatalf("Verify returned error for a bcrypt hash: %v", err)
+	}
+	if !needsRehash {
+		t.Error("expected a bcrypt hash to need rehashing")
+	}
+
+	if _, err := hasher.Verify(legacy, "KleinMoretti"); !errors.Is(err, ErrPasswordMismatch) {
+		t.Errorf("expected ErrPasswordMismatch for the wrong password, got %v", err)
+	}
+}
+
+func TestArgon2idHasherRehashesOnParameterChange(t *testing.T) {
+	hash, err := NewArgon2idHasher(testArgon2idParams).Hash("LumianLee")
+	if err != nil {
+		t.Fatalf("Hash returned error: %v", err)
+	}
+
+	stronger := testArgon2idParams
+	stronger.Iterations = 2
+	needsRehash, err := NewArgon2idHasher(stronger).Verify(hash, "LumianLee")
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	if !needsRehash {
+		t.Error("expected a hash with weaker parameters to need rehashing")
+	}
+}
+
+func TestArgon2idHasherRejectsMalformedHash(t *testing.T) {
+	hasher := NewArgon2idHasher(testArgon2idParams)
+	for _, hash := range []string{"", "plaintext", "$argon2id$v=19$m=1024$abc$def", "$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$a2V5"} {
+		if _, err := hasher.Verify(hash, "LumianLee"); err == nil || errors.Is(err, ErrPasswordMismatch) {
+			t.Errorf("expected a format error for %q, got %v", hash, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// prefixLength is the number of hex characters that select a bucket, as in
+// the Pwned Passwords range API.
+const prefixLength = 5
+
+// Corpus is a set of breached passwords, kept as SHA-1 hashes bucketed by the
+// first five hex characters the way the Pwned Passwords k-anonymity API
+// serves them. Only suffixes are stored, so a corpus can be fed straight from
+// downloaded range files.
+type Corpus struct {
+	buckets map[string]map[string]struct{}
+}
+
+// LoadCorpus reads a breach corpus from r. Every line is either a full
+// uppercase or lowercase SHA-1 hash, or a range file line of the form
+// PREFIX:SUFFIX, optionally followed by :COUNT. Blank lines and lines starting
+// with # are ignored.
+func LoadCorpus(r io.Reader) (*Corpus, error) {
+	corpus := &Corpus{buckets: make(map[string]map[string]struct{})}
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, ":")
+		var hash string
+		switch {
+		case len(fields[0]) == sha1.Size*2:
+			hash = fields[0]
+		case len(fields) >= 2 && len(fields[0]) == prefixLength:
+			hash = fields[0] + fields[1]
+		}
+		hash = strings.ToUpper(hash)
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("line %d: expected a SHA-1 hash or PREFIX:SUFFIX", lineNumber)
+		}
+		corpus.add(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return corpus, nil
+}
+
+func LoadCorpusFile(path string) (*Corpus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadCorpus(f)
+}
+
+func (c *Corpus) add(hash string) {
+	prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+	bucket, ok := c.buckets[prefix]
+	if !ok {
+		bucket = make(map[string]struct{})
+		c.buckets[prefix] = bucket
+	}
+	bucket[suffix] = struct{}{}
+}
+
+// Contains reports whether password is in the corpus.
+func (c *Corpus) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	_, found := c.buckets[hash[:prefixLength]][hash[prefixLength:]]
+	return found
+}
+
+// Len returns the number of hashes in the corpus.
+func (c *Corpus) Len() int {
+	n := 0
+	for _, bucket := range c.buckets {
+		n += len(bucket)
+	}
+	return n
+}
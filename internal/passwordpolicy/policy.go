@@ -0,0 +1,147 @@
+// Package passwordpolicy decides whether a password is strong enough to be
+// accepted.
+package passwordpolicy
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rule names reported in violations.
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleEntropy   = "entropy"
+	RuleUserInfo  = "user_info"
+	RuleBreached  = "breached"
+)
+
+// Violation is one rule a password failed.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type Policy struct {
+	// MinLength and MaxLength count characters, not bytes.
+	MinLength int
+	MaxLength int
+	// MinEntropyBits is the lowest EstimateEntropy a password may have.
+	MinEntropyBits float64
+	// Breached, when set, rejects passwords found in a breach corpus.
+	Breached *Corpus
+}
+
+var DefaultPolicy = Policy{
+	MinLength:      10,
+	MaxLength:      256,
+	MinEntropyBits: 40,
+}
+
+// Check returns every rule password fails, or nil when it is acceptable.
+// userInputs are values the password must not contain, such as the user's
+// email address.
+func (p Policy) Check(password string, userInputs ...string) []Violation {
+	var violations []Violation
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, Violation{RuleMinLength, fmt.Sprintf("Password must be at least %d characters long", p.MinLength)})
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, Violation{RuleMaxLength, fmt.Sprintf("Password must be at most %d characters long", p.MaxLength)})
+	}
+	if EstimateEntropy(password) < p.MinEntropyBits {
+		violations = append(violations, Violation{RuleEntropy, "Password is too predictable; use a longer password or a wider mix of characters"})
+	}
+
+	lowered := strings.ToLower(password)
+	for _, input := range userInputs {
+		for _, part := range userInfoParts(input) {
+			if strings.Contains(lowered, part) {
+				violations = append(violations, Violation{RuleUserInfo, "Password must not contain your email address or name"})
+				break
+			}
+		}
+	}
+
+	if p.Breached != nil && p.Breached.Contains(password) {
+		violations = append(violations, Violation{RuleBreached, "Password has appeared in a data breach; choose a different one"})
+	}
+	return violations
+}
+
+// userInfoParts splits an email address into the pieces worth looking for.
+// Short pieces are skipped so that "al@x.io" doesn't forbid every password
+// with "al" in it.
+func userInfoParts(input string) []string {
+	local, _, _ := strings.Cut(strings.ToLower(input), "@")
+	fields := strings.FieldsFunc(local, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	parts := make([]string, 0, len(fields)+1)
+	if len(local) >= 4 {
+		parts = append(parts, local)
+	}
+	for _, field := range fields {
+		if len(field) >= 4 && field != local {
+			parts = append(parts, field)
+		}
+	}
+	return parts
+}
+
+// EstimateEntropy approximates the strength of password in bits as if its
+// characters were drawn at random from the classes it uses. Repeats and runs
+// such as "aaaa" or "1234" add little, since they are what people type when
+// a policy asks for length.
+func EstimateEntropy(password string) float64 {
+	var lower, upper, digit, symbol, other bool
+	var effective float64
+	var prev rune = -1
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < utf8.RuneSelf && unicode.IsPrint(r):
+			symbol = true
+		default:
+			other = true
+		}
+
+		if r == prev || r == prev+1 || r == prev-1 {
+			effective += 0.25
+		} else {
+			effective++
+		}
+		prev = r
+	}
+
+	pool := 0
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if digit {
+		pool += 10
+	}
+	if symbol {
+		pool += 33
+	}
+	if other {
+		pool += 100
+	}
+	if pool == 0 {
+		return 0
+	}
+	return effective * math.Log2(float64(pool))
+}
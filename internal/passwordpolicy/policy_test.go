@@ -0,0 +1,101 @@
+package passwordpolicy
+
+import (
+	"strings"
+	"testing"
+)
+
+func rules(violations []Violation) []string {
+	names := make([]string, 0, len(violations))
+	for _, v := range violations {
+		names = append(names, v.Rule)
+	}
+	return names
+}
+
+func TestCheckAcceptsStrongPassword(t *testing.T) {
+	if violations := DefaultPolicy.Check("correct horse battery staple", "walt@breakingbad.com"); violations != nil {
+		t.Errorf("expected no violations, got %v", rules(violations))
+	}
+}
+
+func TestCheckRejectsEmptyPassword(t *testing.T) {
+	got := rules(DefaultPolicy.Check(""))
+	want := []string{RuleMinLength, RuleEntropy}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCheckReportsEveryFailedRule(t *testing.T) {
+	corpus, err := LoadCorpus(strings.NewReader("CBFDAC6008F9CAB4083784CBD1874F76618D2A97:2401\n"))
+	if err != nil {
+		t.Fatalf("LoadCorpus returned error: %v", err)
+	}
+	policy := DefaultPolicy
+	policy.MinLength = 12
+	policy.Breached = corpus
+
+	got := rules(policy.Check("password123", "password@example.com"))
+	want := []string{RuleMinLength, RuleUserInfo, RuleBreached}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCheckMaxLength(t *testing.T) {
+	got := rules(DefaultPolicy.Check(strings.Repeat("aB3$", 100)))
+	if len(got) != 1 || got[0] != RuleMaxLength {
+		t.Errorf("expected only %s, got %v", RuleMaxLength, got)
+	}
+}
+
+func TestEstimateEntropy(t *testing.T) {
+	tests := []struct {
+		password string
+		atLeast  float64
+		below    float64
+	}{
+		{"", 0, 0.1},
+		{"aaaaaaaaaaaaaaaa", 0, 25},
+		{"abcdefghijklmnop", 0, 25},
+		{"12345678901234", 0, 20},
+		{"Tr0ub4dor&3", 60, 80},
+		{"correct horse battery staple", 100, 200},
+	}
+	for _, tt := range tests {
+		got := EstimateEntropy(tt.password)
+		if got < tt.atLeast || got >= tt.below {
+			t.Errorf("EstimateEntropy(%q) = %.1f, want [%v, %v)", tt.password, got, tt.atLeast, tt.below)
+		}
+	}
+}
+
+func TestLoadCorpusFormats(t *testing.T) {
+	input := `# full hashes and range file lines
+cbfdac6008f9cab4083784cbd1874f76618d2a97
+87457:2E7A5AE6A49466A6AC578B98ADBA78C6AA6:3
+
+`
+	corpus, err := LoadCorpus(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("LoadCorpus returned error: %v", err)
+	}
+	if corpus.Len() != 2 {
+		t.Errorf("expected 2 hashes, got %d", corpus.Len())
+	}
+	for _, password := range []string{"password123", "Tr0ub4dor&3"} {
+		if !corpus.Contains(password) {
+			t.Errorf("expected %q to be in the corpus", password)
+		}
+	}
+	if corpus.Contains("correct horse battery staple") {
+		t.Error("expected an unlisted password not to be in the corpus")
+	}
+}
+
+func TestLoadCorpusRejectsGarbage(t *testing.T) {
+	if _, err := LoadCorpus(strings.NewReader("not a hash\n")); err == nil {
+		t.Error("expected an error for a malformed line")
+	}
+}
@@ -0,0 +1,39 @@
+package auth
+
+import "slices"
+
+// Permissions guard the admin API. They are granted through roles, which are
+// stored in the database; access tokens from an interactive login carry the
+// user's roles.
+const (
+	PermissionMetricsRead     = "metrics:read"
+	PermissionDataReset       = "data:reset"
+	PermissionLoginUnlock     = "login:unlock"
+	PermissionRolesManage     = "roles:manage"
+	PermissionUsersSuspend    = "users:suspend"
+	PermissionReportsModerate = "reports:moderate"
+	PermissionChirpsModerate  = "chirps:moderate"
+	PermissionAuditRead       = "audit:read"
+)
+
+// Built-in roles, created by the migration that introduced roles.
+const (
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+)
+
+// Outranks reports whether someone holding caller may suspend or otherwise
+// sanction someone holding target. Whoever can manage roles outranks
+// everyone; anyone else may only act on users whose permissions are all
+// ones they hold themselves, so a moderator can't lock an admin out.
+func Outranks(caller, target []string) bool {
+	if slices.Contains(caller, PermissionRolesManage) {
+		return true
+	}
+	for _, permission := range target {
+		if !slices.Contains(caller, permission) {
+			return false
+		}
+	}
+	return true
+}
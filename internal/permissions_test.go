@@ -0,0 +1,30 @@
+package auth
+
+import "testing"
+
+func TestOutranks(t *testing.T) {
+	admin := []string{PermissionAuditRead, PermissionRolesManage, PermissionUsersSuspend}
+	moderator := []string{PermissionReportsModerate, PermissionUsersSuspend}
+
+	tests := []struct {
+		name   string
+		caller []string
+		target []string
+		want   bool
+	}{
+		{"moderator on a plain user", moderator, nil, true},
+		{"moderator on another moderator", moderator, moderator, true},
+		{"moderator on an admin", moderator, admin, false},
+		{"moderator on a user with one extra permission", moderator, []string{PermissionDataReset}, false},
+		{"admin on an admin", admin, admin, true},
+		{"admin on anyone", admin, []string{PermissionDataReset, PermissionMetricsRead}, true},
+		{"nobody on a moderator", nil, moderator, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Outranks(tt.caller, tt.target); got != tt.want {
+				t.Errorf("Outranks(%v, %v) = %v, want %v", tt.caller, tt.target, got, tt.want)
+			}
+		})
+	}
+}
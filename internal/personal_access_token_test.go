@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"testing"
+)
+
+func TestMakePersonalAccessToken(t *testing.T) {
+	token, err := MakePersonalAccessToken()
+	if err != nil {
+		t.Fatalf("MakePersonalAccessToken returned error: %v", err)
+	}
+	if !IsPersonalAccessToken(token) {
+		t.Errorf("token %q is missing the %q prefix", token, PersonalAccessTokenPrefix)
+	}
+	if len(token) != len(PersonalAccessTokenPrefix)+64 {
+		t.Errorf("unexpected token length %d", len(token))
+	}
+
+	other, err := MakePersonalAccessToken()
+	if err != nil {
+		t.Fatalf("MakePersonalAccessToken returned error: %v", err)
+	}
+	if token == other {
+		t.Error("expected two calls to return different tokens")
+	}
+}
+
+func TestIsPersonalAccessTokenRejectsJWT(t *testing.T) {
+	if IsPersonalAccessToken("eyJhbGciOiJFZERTQSJ9.e30.sig") {
+		t.Error("expected a JWT not to be taken for a personal access token")
+	}
+}
+
+func TestDelegatedScopesExcludeAccount(t *testing.T) {
+	if HasScope(DelegatedScopes, ScopeAccount) {
+		t.Errorf("delegated tokens must not be able to carry %q", ScopeAccount)
+	}
+	if !HasScope(SessionScopes, ScopeAccount) {
+		t.Errorf("session tokens must carry %q", ScopeAccount)
+	}
+}
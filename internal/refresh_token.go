@@ -3,11 +3,14 @@ package auth
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"fmt"
 )
 
 func MakeRefreshToken() (string, error) {
 	key := make([]byte, 32)
-	rand.Read(key)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("couldn't read random bytes: %w", err)
+	}
 	encodedStr := hex.EncodeToString(key)
 	return encodedStr, nil
 }
@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"testing"
+)
+
+func TestMakeRefreshToken(t *testing.T) {
+	first, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("MakeRefreshToken() returned error: %v", err)
+	}
+	second, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("MakeRefreshToken() returned error: %v", err)
+	}
+
+	if len(first) != 64 {
+		t.Errorf("Expected a 64 character token, got %d characters", len(first))
+	}
+	if first == second {
+		t.Error("Two refresh tokens should never be equal")
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	// sha256("abc") from FIPS 180-2, matching encode(sha256('abc'), 'hex') in PostgreSQL.
+	expected := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if hash := HashToken("abc"); hash != expected {
+		t.Errorf("HashToken(\"abc\") = %s, expected %s", hash, expected)
+	}
+}
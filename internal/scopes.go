@@ -0,0 +1,28 @@
+package auth
+
+import "slices"
+
+const (
+	// ScopeChirpsRead is needed to read chirps with a token. Chirps are public,
+	// but a token lets its user see their own chirps that moderation held or
+	// hid.
+	ScopeChirpsRead   = "chirps:read"
+	ScopeChirpsWrite  = "chirps:write"
+	ScopeProfileWrite = "profile:write"
+	// ScopeAccount covers credentials, sessions, tokens, exports and deletion.
+	// It is never granted to personal access tokens or OAuth clients, so a
+	// leaked token can't be used to take the account over.
+	ScopeAccount = "account"
+)
+
+// SessionScopes are granted to access tokens issued for an interactive login,
+// which may do anything the user can.
+var SessionScopes = []string{ScopeChirpsRead, ScopeChirpsWrite, ScopeProfileWrite, ScopeAccount}
+
+// DelegatedScopes are the scopes a user may grant to a personal access token
+// or a third-party OAuth client.
+var DelegatedScopes = []string{ScopeChirpsRead, ScopeChirpsWrite, ScopeProfileWrite}
+
+func HasScope(scopes []string, scope string) bool {
+	return slices.Contains(scopes, scope)
+}
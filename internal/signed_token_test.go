@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMakeAndParseSignedToken(t *testing.T) {
+	id := uuid.New()
+	token := MakeSignedToken(id, "email-verification", "LumianLee")
+
+	parsedID, err := ParseSignedToken(token, "email-verification", "LumianLee")
+	if err != nil {
+		t.Fatalf("ParseSignedToken() returned error: %v", err)
+	}
+	if parsedID != id {
+		t.Errorf("Parsed id %v does not match the input id %v", parsedID, id)
+	}
+}
+
+func TestParseSignedToken_Rejected(t *testing.T) {
+	id := uuid.New()
+	token := MakeSignedToken(id, "email-verification", "LumianLee")
+	other := MakeSignedToken(uuid.New(), "email-verification", "LumianLee")
+
+	cases := map[string]struct {
+		token   string
+		purpose string
+		secret  string
+	}{
+		"wrong secret":      {token, "email-verification", "KleinMoretti"},
+		"wrong purpose":     {token, "password-reset", "LumianLee"},
+		"swapped signature": {token[:22] + other[22:], "email-verification", "LumianLee"},
+		"missing signature": {token[:22], "email-verification", "LumianLee"},
+		"garbage":           {"not-a-token", "email-verification", "LumianLee"},
+	}
+	for name, c := range cases {
+		if _, err := ParseSignedToken(c.token, c.purpose, c.secret); err == nil {
+			t.Errorf("%s: ParseSignedToken() should return error, but got none", name)
+		}
+	}
+}
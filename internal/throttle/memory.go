@@ -0,0 +1,79 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps counters in process memory. Limits are per instance and
+// reset on restart.
+type MemoryStore struct {
+	mu       sync.Mutex
+	counters map[string]Counter
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: make(map[string]Counter)}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (Counter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counters[key], nil
+}
+
+func (s *MemoryStore) Fail(ctx context.Context, key string, now, windowStart time.Time) (Counter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fail(key, now, windowStart), nil
+}
+
+func (s *MemoryStore) FailIfUnchanged(ctx context.Context, key string, seen Counter, now, windowStart time.Time) (Counter, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c := s.counters[key]; c.Failures != seen.Failures || !c.LastFailure.Equal(seen.LastFailure) {
+		return c, false, nil
+	}
+	return s.fail(key, now, windowStart), true, nil
+}
+
+// fail is Fail for a caller holding mu.
+func (s *MemoryStore) fail(key string, now, windowStart time.Time) Counter {
+	c := s.counters[key]
+	if c.LastFailure.Before(windowStart) {
+		c.Failures = 0
+	}
+	c.Failures++
+	c.LastFailure = now
+	s.counters[key] = c
+	return c
+}
+
+func (s *MemoryStore) Forgive(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.counters[key]; ok && c.Failures > 0 {
+		c.Failures--
+		s.counters[key] = c
+	}
+	return nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.counters, key)
+	return nil
+}
+
+func (s *MemoryStore) Cleanup(ctx context.Context, cutoff time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, c := range s.counters {
+		if c.LastFailure.Before(cutoff) {
+			delete(s.counters, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package throttle
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+)
+
+// PostgresStore keeps counters in the login_attempts table, so every
+// instance sharing the database enforces the same limits.
+type PostgresStore struct {
+	queries *database.Queries
+}
+
+func NewPostgresStore(queries *database.Queries) *PostgresStore {
+	return &PostgresStore{queries: queries}
+}
+
+func (s *PostgresStore) Get(ctx context.Context, key string) (Counter, error) {
+	attempts, err := s.queries.GetLoginAttempts(ctx, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Counter{}, nil
+	}
+	if err != nil {
+		return Counter{}, err
+	}
+	return Counter{Failures: int(attempts.Failures), LastFailure: attempts.LastFailureAt}, nil
+}
+
+func (s *PostgresStore) Fail(ctx context.Context, key string, now, windowStart time.Time) (Counter, error) {
+	attempts, err := s.queries.RecordLoginFailure(ctx, database.RecordLoginFailureParams{
+		Key:         key,
+		FailedAt:    now,
+		WindowStart: windowStart,
+	})
+	if err != nil {
+		return Counter{}, err
+	}
+	return Counter{Failures: int(attempts.Failures), LastFailure: attempts.LastFailureAt}, nil
+}
+
+func (s *PostgresStore) FailIfUnchanged(ctx context.Context, key string, seen Counter, now, windowStart time.Time) (Counter, bool, error) {
+	attempts, err := s.queries.RecordLoginFailureIfUnchanged(ctx, database.RecordLoginFailureIfUnchangedParams{
+		Key:               key,
+		FailedAt:          now,
+		WindowStart:       windowStart,
+		SeenFailures:      int32(seen.Failures),
+		SeenLastFailureAt: seen.LastFailure,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return Counter{}, false, nil
+	}
+	if err != nil {
+		return Counter{}, false, err
+	}
+	return Counter{Failures: int(attempts.Failures), LastFailure: attempts.LastFailureAt}, true, nil
+}
+
+func (s *PostgresStore) Forgive(ctx context.Context, key string) error {
+	return s.queries.ForgiveLoginFailure(ctx, key)
+}
+
+func (s *PostgresStore) Reset(ctx context.Context, key string) error {
+	return s.queries.ResetLoginAttempts(ctx, key)
+}
+
+func (s *PostgresStore) Cleanup(ctx context.Context, cutoff time.Time) error {
+	return s.queries.DeleteLoginAttemptsBefore(ctx, cutoff)
+}
@@ -0,0 +1,140 @@
+// Package throttle slows down repeated failures, such as password guesses,
+// by making callers wait longer after every failed attempt.
+package throttle
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Counter is the failure history of one key.
+type Counter struct {
+	Failures    int
+	LastFailure time.Time
+}
+
+// Store keeps failure counters. It must be safe for concurrent use; stores
+// shared by several instances let them enforce the same limits.
+type Store interface {
+	Get(ctx context.Context, key string) (Counter, error)
+	// Fail records a failure for key at now. Failures older than
+	// windowStart are forgotten first, so the count starts over.
+	Fail(ctx context.Context, key string, now, windowStart time.Time) (Counter, error)
+	// FailIfUnchanged is Fail, but only while key's counter still equals
+	// seen. It returns false, and records nothing, when another failure was
+	// recorded or forgiven since seen was read.
+	FailIfUnchanged(ctx context.Context, key string, seen Counter, now, windowStart time.Time) (Counter, bool, error)
+	// Forgive takes one failure off key's count, for an attempt that was
+	// counted up front and then succeeded.
+	Forgive(ctx context.Context, key string) error
+	Reset(ctx context.Context, key string) error
+	// Cleanup drops counters whose last failure is before cutoff.
+	Cleanup(ctx context.Context, cutoff time.Time) error
+}
+
+// Policy decides how long a key has to wait after its failures.
+type Policy struct {
+	// FreeAttempts failures are allowed before any delay applies.
+	FreeAttempts int
+	// BaseDelay is the wait after the first failure past FreeAttempts. It
+	// doubles with every further failure, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutAfter failures lock the key out for LockoutDuration.
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	// Window is how long a key has to go without failures for its counter
+	// to start over. It should be at least LockoutDuration.
+	Window time.Duration
+}
+
+// Delay returns how long to wait after the last failure of c.
+func (p Policy) Delay(c Counter) time.Duration {
+	if p.LockoutAfter > 0 && c.Failures >= p.LockoutAfter {
+		return p.LockoutDuration
+	}
+	excess := c.Failures - p.FreeAttempts
+	if excess <= 0 {
+		return 0
+	}
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(excess-1))
+	if delay > float64(p.MaxDelay) {
+		return p.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+// Limiter applies a Policy to the keys of a Store. Keys are namespaced with
+// the limiter's prefix so several limiters can share one store.
+type Limiter struct {
+	store  Store
+	prefix string
+	policy Policy
+}
+
+func NewLimiter(store Store, prefix string, policy Policy) *Limiter {
+	return &Limiter{store: store, prefix: prefix, policy: policy}
+}
+
+// takeTries bounds how often Take rereads a counter that other attempts keep
+// changing under it.
+const takeTries = 10
+
+// RetryAfter returns how long key still has to wait, or zero when it may try
+// again now.
+func (l *Limiter) RetryAfter(ctx context.Context, key string, now time.Time) (time.Duration, error) {
+	c, err := l.store.Get(ctx, l.prefix+key)
+	if err != nil {
+		return 0, err
+	}
+	return l.wait(c, now), nil
+}
+
+func (l *Limiter) wait(c Counter, now time.Time) time.Duration {
+	if c.Failures == 0 || c.LastFailure.Before(now.Add(-l.policy.Window)) {
+		return 0
+	}
+	return max(c.LastFailure.Add(l.policy.Delay(c)).Sub(now), 0)
+}
+
+// Take is RetryAfter for an attempt about to be made. When key may try now,
+// Take counts the attempt as a failure before it is made and returns zero;
+// otherwise it counts nothing and returns the wait. Checking and counting
+// are one step, so parallel attempts can't all find the same clean counter
+// before any of them has failed. An attempt that succeeds should be given
+// back with Forgive or Reset.
+func (l *Limiter) Take(ctx context.Context, key string, now time.Time) (time.Duration, error) {
+	for range takeTries {
+		c, err := l.store.Get(ctx, l.prefix+key)
+		if err != nil {
+			return 0, err
+		}
+		if wait := l.wait(c, now); wait > 0 {
+			return wait, nil
+		}
+		_, counted, err := l.store.FailIfUnchanged(ctx, l.prefix+key, c, now, now.Add(-l.policy.Window))
+		if err != nil {
+			return 0, err
+		}
+		if counted {
+			return 0, nil
+		}
+	}
+	// Other attempts on key keep getting counted first; this one can wait.
+	return max(l.policy.BaseDelay, time.Second), nil
+}
+
+// Fail records a failed attempt for key and returns the resulting counter.
+func (l *Limiter) Fail(ctx context.Context, key string, now time.Time) (Counter, error) {
+	return l.store.Fail(ctx, l.prefix+key, now, now.Add(-l.policy.Window))
+}
+
+// Forgive gives back an attempt Take counted that turned out to succeed.
+func (l *Limiter) Forgive(ctx context.Context, key string) error {
+	return l.store.Forgive(ctx, l.prefix+key)
+}
+
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	return l.store.Reset(ctx, l.prefix+key)
+}
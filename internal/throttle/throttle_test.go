@@ -0,0 +1,198 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	FreeAttempts:    3,
+	BaseDelay:       time.Second,
+	MaxDelay:        30 * time.Second,
+	LockoutAfter:    10,
+	LockoutDuration: 15 * time.Minute,
+	Window:          time.Hour,
+}
+
+func TestPolicyDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{8, 16 * time.Second},
+		{9, 30 * time.Second},
+		{10, 15 * time.Minute},
+		{25, 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := testPolicy.Delay(Counter{Failures: tt.failures}); got != tt.want {
+			t.Errorf("Delay after %d failures: got %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLimiterProgressiveDelay(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewLimiter(NewMemoryStore(), "account:", testPolicy)
+	now := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		if _, err := limiter.Fail(ctx, "walt@breakingbad.com", now); err != nil {
+			t.Fatalf("Fail returned error: %v", err)
+		}
+	}
+	wait, err := limiter.RetryAfter(ctx, "walt@breakingbad.com", now)
+	if err != nil {
+		t.Fatalf("RetryAfter returned error: %v", err)
+	}
+	if wait != 0 {
+		t.Errorf("expected no wait within the free attempts, got %v", wait)
+	}
+
+	limiter.Fail(ctx, "walt@breakingbad.com", now)
+	limiter.Fail(ctx, "walt@breakingbad.com", now)
+	wait, _ = limiter.RetryAfter(ctx, "walt@breakingbad.com", now.Add(500*time.Millisecond))
+	if wait != 1500*time.Millisecond {
+		t.Errorf("expected 1.5s left of a 2s delay, got %v", wait)
+	}
+	wait, _ = limiter.RetryAfter(ctx, "walt@breakingbad.com", now.Add(2*time.Second))
+	if wait != 0 {
+		t.Errorf("expected the delay to be over, got %v", wait)
+	}
+
+	other, _ := limiter.RetryAfter(ctx, "jesse@breakingbad.com", now)
+	if other != 0 {
+		t.Errorf("expected other keys not to be affected, got %v", other)
+	}
+}
+
+func TestLimiterLockoutAndReset(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewLimiter(NewMemoryStore(), "ip:", testPolicy)
+	now := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < testPolicy.LockoutAfter; i++ {
+		limiter.Fail(ctx, "203.0.113.7", now)
+	}
+	wait, _ := limiter.RetryAfter(ctx, "203.0.113.7", now.Add(time.Minute))
+	if wait != 14*time.Minute {
+		t.Errorf("expected 14m left of the lockout, got %v", wait)
+	}
+
+	if err := limiter.Reset(ctx, "203.0.113.7"); err != nil {
+		t.Fatalf("Reset returned error: %v", err)
+	}
+	wait, _ = limiter.RetryAfter(ctx, "203.0.113.7", now.Add(time.Minute))
+	if wait != 0 {
+		t.Errorf("expected no wait after a reset, got %v", wait)
+	}
+}
+
+func TestLimiterWindowStartsOver(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewLimiter(NewMemoryStore(), "account:", testPolicy)
+	now := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		limiter.Fail(ctx, "walt@breakingbad.com", now)
+	}
+	counter, err := limiter.Fail(ctx, "walt@breakingbad.com", now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("Fail returned error: %v", err)
+	}
+	if counter.Failures != 1 {
+		t.Errorf("expected the count to start over after the window, got %d", counter.Failures)
+	}
+}
+
+func TestLimiterTakeInParallel(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	limiter := NewLimiter(store, "account:", testPolicy)
+	now := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+
+	// Every guess starts before any of them fails, so only counting the
+	// attempt as it is let through keeps the burst to the free attempts.
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait, err := limiter.Take(ctx, "walt@breakingbad.com", now)
+			if err != nil {
+				t.Errorf("Take returned error: %v", err)
+			}
+			if wait == 0 {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := int(allowed.Load()); got == 0 || got > testPolicy.FreeAttempts+1 {
+		t.Errorf("expected at most %d attempts to go ahead, got %d", testPolicy.FreeAttempts+1, got)
+	}
+	c, _ := store.Get(ctx, "account:walt@breakingbad.com")
+	if c.Failures != int(allowed.Load()) {
+		t.Errorf("expected every attempt that went ahead to be counted, got %d for %d", c.Failures, allowed.Load())
+	}
+}
+
+func TestLimiterTakeAndForgive(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewLimiter(NewMemoryStore(), "ip:", testPolicy)
+	now := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+
+	// Attempts that are given back never add up to a delay.
+	for i := 0; i < 2*testPolicy.LockoutAfter; i++ {
+		wait, err := limiter.Take(ctx, "203.0.113.7", now)
+		if err != nil {
+			t.Fatalf("Take returned error: %v", err)
+		}
+		if wait != 0 {
+			t.Fatalf("attempt %d: expected no wait, got %v", i, wait)
+		}
+		if err := limiter.Forgive(ctx, "203.0.113.7"); err != nil {
+			t.Fatalf("Forgive returned error: %v", err)
+		}
+	}
+
+	for i := 0; i <= testPolicy.FreeAttempts; i++ {
+		limiter.Take(ctx, "203.0.113.7", now)
+	}
+	wait, _ := limiter.Take(ctx, "203.0.113.7", now)
+	if wait != time.Second {
+		t.Errorf("expected the attempt after the free ones to wait 1s, got %v", wait)
+	}
+	wait, _ = limiter.Take(ctx, "203.0.113.7", now)
+	if wait != time.Second {
+		t.Errorf("expected a refused attempt not to be counted, got a wait of %v", wait)
+	}
+}
+
+func TestMemoryStoreCleanup(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+	store.Fail(ctx, "old", now.Add(-2*time.Hour), now.Add(-3*time.Hour))
+	store.Fail(ctx, "recent", now, now.Add(-time.Hour))
+
+	if err := store.Cleanup(ctx, now.Add(-time.Hour)); err != nil {
+		t.Fatalf("Cleanup returned error: %v", err)
+	}
+	if c, _ := store.Get(ctx, "old"); c.Failures != 0 {
+		t.Error("expected the old counter to be removed")
+	}
+	if c, _ := store.Get(ctx, "recent"); c.Failures != 1 {
+		t.Error("expected the recent counter to be kept")
+	}
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHOTPRFC6238Vectors checks the test vectors from RFC 6238 Appendix B.
+func TestHOTPRFC6238Vectors(t *testing.T) {
+	seeds := map[string]struct {
+		key     []byte
+		newHash func() hash.Hash
+	}{
+		"SHA1":   {[]byte("12345678901234567890"), sha1.New},
+		"SHA256": {[]byte("12345678901234567890123456789012"), sha256.New},
+		"SHA512": {[]byte("1234567890123456789012345678901234567890123456789012345678901234"), sha512.New},
+	}
+	tests := []struct {
+		unix int64
+		mode string
+		want string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111109, "SHA256", "68084774"},
+		{1111111109, "SHA512", "25091201"},
+		{1111111111, "SHA1", "14050471"},
+		{1111111111, "SHA256", "67062674"},
+		{1111111111, "SHA512", "99943326"},
+		{1234567890, "SHA1", "89005924"},
+		{1234567890, "SHA256", "91819424"},
+		{1234567890, "SHA512", "93441116"},
+		{2000000000, "SHA1", "69279037"},
+		{2000000000, "SHA256", "90698825"},
+		{2000000000, "SHA512", "38618901"},
+		{20000000000, "SHA1", "65353130"},
+		{20000000000, "SHA256", "77737706"},
+		{20000000000, "SHA512", "47863826"},
+	}
+
+	for _, tt := range tests {
+		seed := seeds[tt.mode]
+		step := TOTPStep(time.Unix(tt.unix, 0))
+		if got := hotp(seed.key, uint64(step), 8, seed.newHash); got != tt.want {
+			t.Errorf("%s at %d: got %s, want %s", tt.mode, tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateTOTPCodeUsesSixDigitSHA1(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	code, err := GenerateTOTPCode(secret, time.Unix(59, 0))
+	if err != nil {
+		t.Fatalf("GenerateTOTPCode returned error: %v", err)
+	}
+	if code != "287082" {
+		t.Errorf("got %s, want the last six digits of the RFC 6238 vector", code)
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret returned error: %v", err)
+	}
+	now := time.Now()
+	code, err := GenerateTOTPCode(secret, now)
+	if err != nil {
+		t.Fatalf("GenerateTOTPCode returned error: %v", err)
+	}
+
+	step, ok := ValidateTOTPCode(secret, code, now, 0)
+	if !ok {
+		t.Fatal("expected the current code to be accepted")
+	}
+	if step != TOTPStep(now) {
+		t.Errorf("expected step %d, got %d", TOTPStep(now), step)
+	}
+
+	if _, ok := ValidateTOTPCode(secret, code, now, step); ok {
+		t.Error("expected a code to be rejected once its step was used")
+	}
+	if _, ok := ValidateTOTPCode(secret, code, now.Add(TOTPPeriod), 0); !ok {
+		t.Error("expected the previous step's code to be accepted")
+	}
+	if _, ok := ValidateTOTPCode(secret, code, now.Add(3*TOTPPeriod), 0); ok {
+		t.Error("expected a code outside the skew window to be rejected")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI("Chirpy", "walt@breakingbad.com", "JBSWY3DPEHPK3PXP"))
+	if err != nil {
+		t.Fatalf("TOTPURI returned an unparsable URI: %v", err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" {
+		t.Errorf("unexpected scheme or type in %s", uri)
+	}
+	if uri.Path != "/Chirpy:walt@breakingbad.com" {
+		t.Errorf("unexpected label %q", uri.Path)
+	}
+	query := uri.Query()
+	if query.Get("secret") != "JBSWY3DPEHPK3PXP" || query.Get("issuer") != "Chirpy" {
+		t.Errorf("unexpected query %q", uri.RawQuery)
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes returned error: %v", err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("expected 10 codes, got %d", len(codes))
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("unexpected code format %q", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+
+	typed := " " + strings.ToUpper(strings.ReplaceAll(codes[0], "-", " ")) + " "
+	if NormalizeRecoveryCode(typed) != NormalizeRecoveryCode(codes[0]) {
+		t.Errorf("expected %q to normalize like %q", typed, codes[0])
+	}
+}
@@ -0,0 +1,117 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers (RFC 9053) of the signatures passkeys use.
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// SupportedAlgorithms is offered to authenticators in order of preference.
+var SupportedAlgorithms = []int64{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters.
+const (
+	coseKeyType   = 1
+	coseAlgorithm = 3
+	coseCurve     = -1 // n for RSA keys
+	coseX         = -2 // e for RSA keys
+	coseY         = -3
+
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+)
+
+// publicKey is a credential public key along with the algorithm it signs with.
+type publicKey struct {
+	Algorithm int64
+	Key       crypto.PublicKey
+}
+
+// parsePublicKey decodes a COSE_Key as found in attested credential data.
+func parsePublicKey(data []byte) (publicKey, error) {
+	value, n, err := decodeCBOR(data)
+	if err != nil {
+		return publicKey{}, err
+	}
+	if n != len(data) {
+		return publicKey{}, errors.New("trailing bytes after COSE key")
+	}
+	m, ok := value.(map[any]any)
+	if !ok {
+		return publicKey{}, errors.New("COSE key is not a map")
+	}
+	keyType, _ := m[int64(coseKeyType)].(int64)
+	alg, _ := m[int64(coseAlgorithm)].(int64)
+
+	switch {
+	case keyType == coseKeyTypeEC2 && alg == AlgES256:
+		curve, _ := m[int64(coseCurve)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if curve != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return publicKey{}, errors.New("invalid P-256 key")
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return publicKey{}, errors.New("P-256 point is not on the curve")
+		}
+		return publicKey{Algorithm: alg, Key: key}, nil
+	case keyType == coseKeyTypeOKP && alg == AlgEdDSA:
+		curve, _ := m[int64(coseCurve)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		if curve != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return publicKey{}, errors.New("invalid Ed25519 key")
+		}
+		return publicKey{Algorithm: alg, Key: ed25519.PublicKey(x)}, nil
+	case keyType == coseKeyTypeRSA && alg == AlgRS256:
+		n, _ := m[int64(coseCurve)].([]byte)
+		e, _ := m[int64(coseX)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return publicKey{}, errors.New("invalid RSA key")
+		}
+		exponent := int(new(big.Int).SetBytes(e).Int64())
+		return publicKey{Algorithm: alg, Key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}}, nil
+	default:
+		return publicKey{}, fmt.Errorf("unsupported COSE key type %d with algorithm %d", keyType, alg)
+	}
+}
+
+// verify checks signature over signed.
+func (k publicKey) verify(signed, signature []byte) error {
+	switch key := k.Key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(signed)
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return ErrInvalidSignature
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, signed, signature) {
+			return ErrInvalidSignature
+		}
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(signed)
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+			return ErrInvalidSignature
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", k.Key)
+	}
+	return nil
+}
@@ -0,0 +1,415 @@
+// Package webauthn implements the relying party side of WebAuthn
+// registration and authentication ceremonies for passkeys. Attestation
+// statements aren't verified: the relying party asks for none, since any
+// authenticator the user chooses is acceptable.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Authenticator data flags.
+const (
+	flagUserPresent       = 0x01
+	flagUserVerified      = 0x04
+	flagAttestedData      = 0x40
+	flagExtensionDataIncl = 0x80
+)
+
+// Timeout is how long the browser gives the user to complete a ceremony.
+const Timeout = 5 * time.Minute
+
+var (
+	ErrInvalidSignature = errors.New("webauthn: invalid signature")
+	// ErrSignCountRegressed means the authenticator's signature counter went
+	// backwards, which suggests the credential was cloned.
+	ErrSignCountRegressed = errors.New("webauthn: signature counter did not increase")
+)
+
+// RelyingParty is the site credentials are scoped to.
+type RelyingParty struct {
+	// ID is the registrable domain credentials are bound to, such as
+	// "chirpy.example".
+	ID   string
+	Name string
+	// Origins lists the exact origins ceremonies may run on.
+	Origins []string
+}
+
+// User is the account a credential is registered for.
+type User struct {
+	// ID is an opaque handle the authenticator returns on login; it must not
+	// contain personal information.
+	ID          []byte
+	Name        string
+	DisplayName string
+}
+
+// Credential is a verified, newly registered public key credential.
+type Credential struct {
+	ID []byte
+	// PublicKey is the COSE_Key from the authenticator, to be passed back to
+	// VerifyAssertion.
+	PublicKey  []byte
+	SignCount  uint32
+	Transports []string
+}
+
+// NewChallenge returns a random challenge, base64url encoded.
+func NewChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("couldn't read random bytes: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Binary values in options and responses are base64url encoded, the JSON
+// format of PublicKeyCredential.parseCreationOptionsFromJSON and toJSON.
+
+type rpEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type userEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type credentialParameter struct {
+	Type      string `json:"type"`
+	Algorithm int64  `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type authenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	RequireResident  bool   `json:"requireResidentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions are the PublicKeyCredentialCreationOptions of a
+// registration ceremony.
+type CreationOptions struct {
+	RP                     rpEntity               `json:"rp"`
+	User                   userEntity             `json:"user"`
+	Challenge              string                 `json:"challenge"`
+	PubKeyCredParams       []credentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection authenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions are the PublicKeyCredentialRequestOptions of an
+// authentication ceremony. AllowCredentials is left empty, so the user picks
+// any passkey they have for the site.
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// Descriptor describes an existing credential so the browser can skip it.
+func Descriptor(id []byte, transports []string) CredentialDescriptor {
+	return CredentialDescriptor{Type: "public-key", ID: base64.RawURLEncoding.EncodeToString(id), Transports: transports}
+}
+
+// CreationOptions asks for a discoverable, user-verifying credential, so it
+// can sign in on its own without a password.
+func (rp *RelyingParty) CreationOptions(challenge string, user User, exclude []CredentialDescriptor) CreationOptions {
+	params := make([]credentialParameter, 0, len(SupportedAlgorithms))
+	for _, alg := range SupportedAlgorithms {
+		params = append(params, credentialParameter{Type: "public-key", Algorithm: alg})
+	}
+	if exclude == nil {
+		exclude = []CredentialDescriptor{}
+	}
+	return CreationOptions{
+		RP: rpEntity{ID: rp.ID, Name: rp.Name},
+		User: userEntity{
+			ID:          base64.RawURLEncoding.EncodeToString(user.ID),
+			Name:        user.Name,
+			DisplayName: user.DisplayName,
+		},
+		Challenge:          challenge,
+		PubKeyCredParams:   params,
+		Timeout:            Timeout.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: authenticatorSelection{
+			ResidentKey:      "required",
+			RequireResident:  true,
+			UserVerification: "required",
+		},
+		Attestation: "none",
+	}
+}
+
+func (rp *RelyingParty) RequestOptions(challenge string) RequestOptions {
+	return RequestOptions{
+		Challenge:        challenge,
+		Timeout:          Timeout.Milliseconds(),
+		RPID:             rp.ID,
+		AllowCredentials: []CredentialDescriptor{},
+		UserVerification: "required",
+	}
+}
+
+// RegistrationResponse is the JSON form of the PublicKeyCredential returned
+// by navigator.credentials.create.
+type RegistrationResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON"`
+		AttestationObject string   `json:"attestationObject"`
+		Transports        []string `json:"transports"`
+	} `json:"response"`
+}
+
+// AssertionResponse is the JSON form of the PublicKeyCredential returned by
+// navigator.credentials.get.
+type AssertionResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+// CredentialID decodes the ID of the credential used, so its public key can
+// be looked up.
+func (r AssertionResponse) CredentialID() ([]byte, error) {
+	return decodeBase64URL(r.RawID)
+}
+
+// UserHandle decodes the user ID the authenticator stored with the
+// credential.
+func (r AssertionResponse) UserHandle() ([]byte, error) {
+	return decodeBase64URL(r.Response.UserHandle)
+}
+
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// verifyClientData checks the client data the browser signed over and
+// returns its hash.
+func (rp *RelyingParty) verifyClientData(encoded, ceremony, challenge string) ([]byte, error) {
+	raw, err := decodeBase64URL(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("webauthn: invalid client data encoding: %w", err)
+	}
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("webauthn: invalid client data: %w", err)
+	}
+	if data.Type != ceremony {
+		return nil, fmt.Errorf("webauthn: client data is for %q, not %q", data.Type, ceremony)
+	}
+	if subtle.ConstantTimeCompare([]byte(data.Challenge), []byte(challenge)) != 1 {
+		return nil, errors.New("webauthn: challenge mismatch")
+	}
+	if !slices.Contains(rp.Origins, data.Origin) {
+		return nil, fmt.Errorf("webauthn: unexpected origin %q", data.Origin)
+	}
+	if data.CrossOrigin {
+		return nil, errors.New("webauthn: cross-origin ceremonies are not allowed")
+	}
+	hash := sha256.Sum256(raw)
+	return hash[:], nil
+}
+
+type authenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+func parseAuthenticatorData(data []byte) (authenticatorData, error) {
+	if len(data) < 37 {
+		return authenticatorData{}, errors.New("webauthn: authenticator data too short")
+	}
+	ad := authenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+	if ad.Flags&flagAttestedData != 0 {
+		// AAGUID (16 bytes), credential ID length (2 bytes), credential ID,
+		// then the credential public key.
+		if len(rest) < 18 {
+			return authenticatorData{}, errors.New("webauthn: attested credential data too short")
+		}
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength == 0 || len(rest) < idLength {
+			return authenticatorData{}, errors.New("webauthn: invalid credential ID")
+		}
+		ad.CredentialID, rest = rest[:idLength], rest[idLength:]
+		_, n, err := decodeCBOR(rest)
+		if err != nil {
+			return authenticatorData{}, fmt.Errorf("webauthn: invalid credential public key: %w", err)
+		}
+		ad.PublicKey, rest = rest[:n], rest[n:]
+	}
+	if ad.Flags&flagExtensionDataIncl != 0 {
+		_, n, err := decodeCBOR(rest)
+		if err != nil {
+			return authenticatorData{}, fmt.Errorf("webauthn: invalid extension data: %w", err)
+		}
+		rest = rest[n:]
+	}
+	if len(rest) != 0 {
+		return authenticatorData{}, errors.New("webauthn: trailing bytes in authenticator data")
+	}
+	return ad, nil
+}
+
+// checkAuthenticatorData verifies the RP ID hash and that the user was both
+// present and verified.
+func (rp *RelyingParty) checkAuthenticatorData(ad authenticatorData) error {
+	want := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(ad.RPIDHash, want[:]) {
+		return errors.New("webauthn: credential is for another relying party")
+	}
+	if ad.Flags&flagUserPresent == 0 {
+		return errors.New("webauthn: user was not present")
+	}
+	if ad.Flags&flagUserVerified == 0 {
+		return errors.New("webauthn: user was not verified")
+	}
+	return nil
+}
+
+// VerifyRegistration checks the response to a registration ceremony started
+// with challenge and returns the new credential.
+func (rp *RelyingParty) VerifyRegistration(challenge string, response RegistrationResponse) (Credential, error) {
+	if response.Type != "public-key" {
+		return Credential{}, fmt.Errorf("webauthn: unexpected credential type %q", response.Type)
+	}
+	if _, err := rp.verifyClientData(response.Response.ClientDataJSON, "webauthn.create", challenge); err != nil {
+		return Credential{}, err
+	}
+
+	rawAttestation, err := decodeBase64URL(response.Response.AttestationObject)
+	if err != nil {
+		return Credential{}, fmt.Errorf("webauthn: invalid attestation object encoding: %w", err)
+	}
+	value, _, err := decodeCBOR(rawAttestation)
+	if err != nil {
+		return Credential{}, fmt.Errorf("webauthn: invalid attestation object: %w", err)
+	}
+	attestation, ok := value.(map[any]any)
+	if !ok {
+		return Credential{}, errors.New("webauthn: attestation object is not a map")
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return Credential{}, errors.New("webauthn: attestation object has no authenticator data")
+	}
+
+	ad, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return Credential{}, err
+	}
+	if err := rp.checkAuthenticatorData(ad); err != nil {
+		return Credential{}, err
+	}
+	if ad.CredentialID == nil {
+		return Credential{}, errors.New("webauthn: no attested credential data")
+	}
+	if _, err := parsePublicKey(ad.PublicKey); err != nil {
+		return Credential{}, fmt.Errorf("webauthn: %w", err)
+	}
+	rawID, err := decodeBase64URL(response.RawID)
+	if err != nil || !bytes.Equal(rawID, ad.CredentialID) {
+		return Credential{}, errors.New("webauthn: credential ID mismatch")
+	}
+
+	return Credential{
+		ID:         ad.CredentialID,
+		PublicKey:  ad.PublicKey,
+		SignCount:  ad.SignCount,
+		Transports: response.Response.Transports,
+	}, nil
+}
+
+// VerifyAssertion checks the response to an authentication ceremony started
+// with challenge against the stored credential, and returns the new value of
+// its signature counter. Authenticators that don't keep a counter always
+// report zero.
+func (rp *RelyingParty) VerifyAssertion(challenge string, response AssertionResponse, storedPublicKey []byte, storedSignCount uint32) (uint32, error) {
+	if response.Type != "public-key" {
+		return 0, fmt.Errorf("webauthn: unexpected credential type %q", response.Type)
+	}
+	clientDataHash, err := rp.verifyClientData(response.Response.ClientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return 0, err
+	}
+
+	rawAuthData, err := decodeBase64URL(response.Response.AuthenticatorData)
+	if err != nil {
+		return 0, fmt.Errorf("webauthn: invalid authenticator data encoding: %w", err)
+	}
+	ad, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+	if err := rp.checkAuthenticatorData(ad); err != nil {
+		return 0, err
+	}
+
+	key, err := parsePublicKey(storedPublicKey)
+	if err != nil {
+		return 0, fmt.Errorf("webauthn: %w", err)
+	}
+	signature, err := decodeBase64URL(response.Response.Signature)
+	if err != nil {
+		return 0, fmt.Errorf("webauthn: invalid signature encoding: %w", err)
+	}
+	signed := append(append([]byte(nil), rawAuthData...), clientDataHash...)
+	if err := key.verify(signed, signature); err != nil {
+		return 0, err
+	}
+
+	if (ad.SignCount != 0 || storedSignCount != 0) && ad.SignCount <= storedSignCount {
+		return 0, ErrSignCountRegressed
+	}
+	return ad.SignCount, nil
+}
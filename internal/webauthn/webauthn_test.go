@@ -0,0 +1,357 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// cborPair keeps map entries in order, so encodings are deterministic.
+type cborPair struct {
+	key, value any
+}
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, n)
+	}
+}
+
+func encodeCBOR(v any) []byte {
+	switch v := v.(type) {
+	case int:
+		if v >= 0 {
+			return cborHead(0, uint64(v))
+		}
+		return cborHead(1, uint64(-1-v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case []cborPair:
+		out := cborHead(5, uint64(len(v)))
+		for _, pair := range v {
+			out = append(out, encodeCBOR(pair.key)...)
+			out = append(out, encodeCBOR(pair.value)...)
+		}
+		return out
+	default:
+		panic("encodeCBOR: unsupported type")
+	}
+}
+
+// softAuthenticator is a platform authenticator in software, holding a single
+// credential.
+type softAuthenticator struct {
+	rpID         string
+	origin       string
+	signer       crypto.Signer
+	credentialID []byte
+	signCount    uint32
+	flags        byte
+}
+
+func newSoftAuthenticator(t *testing.T, alg int64, rpID, origin string) *softAuthenticator {
+	t.Helper()
+	var signer crypto.Signer
+	var err error
+	switch alg {
+	case AlgES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		t.Fatalf("unsupported algorithm %d", alg)
+	}
+	if err != nil {
+		t.Fatalf("couldn't generate key: %v", err)
+	}
+	credentialID := make([]byte, 16)
+	rand.Read(credentialID)
+	return &softAuthenticator{
+		rpID:         rpID,
+		origin:       origin,
+		signer:       signer,
+		credentialID: credentialID,
+		flags:        flagUserPresent | flagUserVerified,
+	}
+}
+
+func (a *softAuthenticator) coseKey() []byte {
+	switch pub := a.signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		return encodeCBOR([]cborPair{
+			{coseKeyType, coseKeyTypeEC2},
+			{coseAlgorithm, AlgES256},
+			{coseCurve, coseCurveP256},
+			{coseX, pub.X.FillBytes(make([]byte, 32))},
+			{coseY, pub.Y.FillBytes(make([]byte, 32))},
+		})
+	case ed25519.PublicKey:
+		return encodeCBOR([]cborPair{
+			{coseKeyType, coseKeyTypeOKP},
+			{coseAlgorithm, AlgEdDSA},
+			{coseCurve, coseCurveEd25519},
+			{coseX, []byte(pub)},
+		})
+	}
+	panic("unsupported key")
+}
+
+func (a *softAuthenticator) clientData(ceremony, challenge string) []byte {
+	data, _ := json.Marshal(clientData{Type: ceremony, Challenge: challenge, Origin: a.origin})
+	return data
+}
+
+func (a *softAuthenticator) authenticatorData(flags byte, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, attested...)
+}
+
+func (a *softAuthenticator) create(challenge string) RegistrationResponse {
+	attested := make([]byte, 16) // all-zero AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialID)))
+	attested = append(attested, a.credentialID...)
+	attested = append(attested, a.coseKey()...)
+
+	attestationObject := encodeCBOR([]cborPair{
+		{"fmt", "none"},
+		{"attStmt", []cborPair{}},
+		{"authData", a.authenticatorData(a.flags|flagAttestedData, attested)},
+	})
+
+	var response RegistrationResponse
+	response.ID = base64.RawURLEncoding.EncodeToString(a.credentialID)
+	response.RawID = response.ID
+	response.Type = "public-key"
+	response.Response.ClientDataJSON = base64.RawURLEncoding.EncodeToString(a.clientData("webauthn.create", challenge))
+	response.Response.AttestationObject = base64.RawURLEncoding.EncodeToString(attestationObject)
+	response.Response.Transports = []string{"internal"}
+	return response
+}
+
+func (a *softAuthenticator) get(t *testing.T, challenge string, userHandle []byte) AssertionResponse {
+	t.Helper()
+	a.signCount++
+	authData := a.authenticatorData(a.flags, nil)
+	clientDataJSON := a.clientData("webauthn.get", challenge)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authData...), clientDataHash[:]...)
+
+	var signature []byte
+	var err error
+	switch signer := a.signer.(type) {
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(signed)
+		signature, err = ecdsa.SignASN1(rand.Reader, signer, digest[:])
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(signer, signed)
+	}
+	if err != nil {
+		t.Fatalf("couldn't sign assertion: %v", err)
+	}
+
+	var response AssertionResponse
+	response.ID = base64.RawURLEncoding.EncodeToString(a.credentialID)
+	response.RawID = response.ID
+	response.Type = "public-key"
+	response.Response.ClientDataJSON = base64.RawURLEncoding.EncodeToString(clientDataJSON)
+	response.Response.AuthenticatorData = base64.RawURLEncoding.EncodeToString(authData)
+	response.Response.Signature = base64.RawURLEncoding.EncodeToString(signature)
+	response.Response.UserHandle = base64.RawURLEncoding.EncodeToString(userHandle)
+	return response
+}
+
+var testRP = &RelyingParty{ID: "chirpy.test", Name: "Chirpy", Origins: []string{"https://chirpy.test"}}
+
+func newTestChallenge(t *testing.T) string {
+	t.Helper()
+	challenge, err := NewChallenge()
+	if err != nil {
+		t.Fatalf("NewChallenge() returned error: %v", err)
+	}
+	return challenge
+}
+
+func TestRegisterThenLogin(t *testing.T) {
+	for _, alg := range []int64{AlgES256, AlgEdDSA} {
+		authenticator := newSoftAuthenticator(t, alg, testRP.ID, testRP.Origins[0])
+
+		challenge := newTestChallenge(t)
+		credential, err := testRP.VerifyRegistration(challenge, authenticator.create(challenge))
+		if err != nil {
+			t.Fatalf("alg %d: VerifyRegistration() returned error: %v", alg, err)
+		}
+		if string(credential.ID) != string(authenticator.credentialID) {
+			t.Errorf("alg %d: credential ID = %x, want %x", alg, credential.ID, authenticator.credentialID)
+		}
+
+		userHandle := []byte("user-handle")
+		signCount := credential.SignCount
+		for range 2 {
+			challenge = newTestChallenge(t)
+			response := authenticator.get(t, challenge, userHandle)
+			signCount, err = testRP.VerifyAssertion(challenge, response, credential.PublicKey, signCount)
+			if err != nil {
+				t.Fatalf("alg %d: VerifyAssertion() returned error: %v", alg, err)
+			}
+			if handle, _ := response.UserHandle(); string(handle) != string(userHandle) {
+				t.Errorf("alg %d: user handle = %q, want %q", alg, handle, userHandle)
+			}
+		}
+		if signCount != 2 {
+			t.Errorf("alg %d: sign count = %d, want 2", alg, signCount)
+		}
+	}
+}
+
+func TestVerifyRegistrationRejects(t *testing.T) {
+	challenge := newTestChallenge(t)
+	tests := []struct {
+		name   string
+		modify func(a *softAuthenticator)
+		use    string
+	}{
+		{"other challenge", func(a *softAuthenticator) {}, newTestChallenge(t)},
+		{"other origin", func(a *softAuthenticator) { a.origin = "https://evil.test" }, challenge},
+		{"other relying party", func(a *softAuthenticator) { a.rpID = "evil.test" }, challenge},
+		{"user not verified", func(a *softAuthenticator) { a.flags = flagUserPresent }, challenge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newSoftAuthenticator(t, AlgES256, testRP.ID, testRP.Origins[0])
+			tt.modify(authenticator)
+			if _, err := testRP.VerifyRegistration(tt.use, authenticator.create(challenge)); err == nil {
+				t.Error("expected VerifyRegistration() to fail")
+			}
+		})
+	}
+}
+
+func TestVerifyAssertionRejects(t *testing.T) {
+	authenticator := newSoftAuthenticator(t, AlgES256, testRP.ID, testRP.Origins[0])
+	challenge := newTestChallenge(t)
+	credential, err := testRP.VerifyRegistration(challenge, authenticator.create(challenge))
+	if err != nil {
+		t.Fatalf("VerifyRegistration() returned error: %v", err)
+	}
+
+	t.Run("other challenge", func(t *testing.T) {
+		response := authenticator.get(t, newTestChallenge(t), nil)
+		if _, err := testRP.VerifyAssertion(challenge, response, credential.PublicKey, 0); err == nil {
+			t.Error("expected VerifyAssertion() to fail")
+		}
+	})
+
+	t.Run("registration client data", func(t *testing.T) {
+		response := authenticator.get(t, challenge, nil)
+		response.Response.ClientDataJSON = base64.RawURLEncoding.EncodeToString(authenticator.clientData("webauthn.create", challenge))
+		if _, err := testRP.VerifyAssertion(challenge, response, credential.PublicKey, 0); err == nil {
+			t.Error("expected VerifyAssertion() to fail")
+		}
+	})
+
+	t.Run("other key", func(t *testing.T) {
+		other := newSoftAuthenticator(t, AlgES256, testRP.ID, testRP.Origins[0])
+		response := other.get(t, challenge, nil)
+		_, err := testRP.VerifyAssertion(challenge, response, credential.PublicKey, 0)
+		if !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("VerifyAssertion() error = %v, want %v", err, ErrInvalidSignature)
+		}
+	})
+
+	t.Run("sign count regressed", func(t *testing.T) {
+		response := authenticator.get(t, challenge, nil)
+		_, err := testRP.VerifyAssertion(challenge, response, credential.PublicKey, authenticator.signCount+10)
+		if !errors.Is(err, ErrSignCountRegressed) {
+			t.Errorf("VerifyAssertion() error = %v, want %v", err, ErrSignCountRegressed)
+		}
+	})
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want any
+	}{
+		{"zero", []byte{0x00}, int64(0)},
+		{"negative", []byte{0x20}, int64(-1)},
+		{"two byte integer", []byte{0x19, 0x03, 0xe8}, int64(1000)},
+		{"negative two byte integer", []byte{0x39, 0x03, 0xe7}, int64(-1000)},
+		{"text", []byte{0x61, 0x61}, "a"},
+		{"true", []byte{0xf5}, true},
+		{"null", []byte{0xf6}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, n, err := decodeCBOR(tt.data)
+			if err != nil {
+				t.Fatalf("decodeCBOR() returned error: %v", err)
+			}
+			if got != tt.want || n != len(tt.data) {
+				t.Errorf("decodeCBOR() = %v, %d, want %v, %d", got, n, tt.want, len(tt.data))
+			}
+		})
+	}
+
+	got, _, err := decodeCBOR([]byte{0xa2, 0x01, 0x43, 0x01, 0x02, 0x03, 0x61, 0x6b, 0x82, 0x01, 0x20})
+	if err != nil {
+		t.Fatalf("decodeCBOR() returned error: %v", err)
+	}
+	m := got.(map[any]any)
+	if b := m[int64(1)].([]byte); len(b) != 3 || b[2] != 3 {
+		t.Errorf("map[1] = %v, want [1 2 3]", m[int64(1)])
+	}
+	if a := m["k"].([]any); len(a) != 2 || a[1] != int64(-1) {
+		t.Errorf("map[k] = %v, want [1 -1]", m["k"])
+	}
+}
+
+func TestDecodeCBORRejects(t *testing.T) {
+	deep := make([]byte, maxCBORDepth+2)
+	for i := range deep {
+		deep[i] = 0x81
+	}
+	deep[len(deep)-1] = 0x00
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"truncated integer", []byte{0x19, 0x03}},
+		{"truncated string", []byte{0x63, 0x61}},
+		{"indefinite length", []byte{0x5f, 0x41, 0x00, 0xff}},
+		{"huge array", []byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"duplicate key", []byte{0xa2, 0x01, 0x01, 0x01, 0x02}},
+		{"array key", []byte{0xa1, 0x80, 0x01}},
+		{"nested too deeply", deep},
+		{"float", []byte{0xf9, 0x3c, 0x00}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeCBOR(tt.data); err == nil {
+				t.Error("expected decodeCBOR() to fail")
+			}
+		})
+	}
+}
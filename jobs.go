@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// runPeriodically calls job every interval until ctx is cancelled. Errors are
+// logged and the job is retried on the next tick.
+func runPeriodically(ctx context.Context, name string, interval time.Duration, job func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := job(ctx); err != nil {
+			log.Printf("Background job %s failed: %v\n", name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeDeletedAccounts removes the users whose deletion grace period is over.
+// Their chirps and refresh tokens go with them through ON DELETE CASCADE.
+// Their export archives live on disk, so their rows are deleted first to
+// find the files, which are removed once the users are gone.
+func (cfg *apiConfig) purgeDeletedAccounts(ctx context.Context) error {
+	now := sql.NullTime{Time: time.Now(), Valid: true}
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	exportFiles, err := qtx.DeleteDataExportsOfUsersPastGracePeriod(ctx, now)
+	if err != nil {
+		return err
+	}
+	deleted, err := qtx.DeleteUsersPastGracePeriod(ctx, now)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	removeExportFiles(exportFiles)
+	for _, userID := range deleted {
+		log.Printf("Deleted account %s after its grace period\n", userID)
+	}
+	return nil
+}
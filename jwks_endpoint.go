@@ -0,0 +1,12 @@
+package main
+
+import (
+	"net/http"
+)
+
+// JWKS publishes the public halves of the access token signing keys so other
+// services can verify Chirpy tokens without being able to mint them.
+func (cfg *apiConfig) JWKS(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, cfg.keyring.JWKS())
+}
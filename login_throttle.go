@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	auth "github.com/SergioFloresCorrea/Chirpy/internal"
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/SergioFloresCorrea/Chirpy/internal/throttle"
+)
+
+// Failed logins are counted per account and per client IP. The account limit
+// stops guessing one user's password from many addresses; the looser IP
+// limit stops one address from trying a common password on many accounts.
+var (
+	accountLoginPolicy = throttle.Policy{
+		FreeAttempts:    5,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutAfter:    10,
+		LockoutDuration: 15 * time.Minute,
+		Window:          time.Hour,
+	}
+	ipLoginPolicy = throttle.Policy{
+		FreeAttempts:    20,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutAfter:    100,
+		LockoutDuration: time.Hour,
+		Window:          time.Hour,
+	}
+)
+
+type loginThrottle struct {
+	store   throttle.Store
+	account *throttle.Limiter
+	ip      *throttle.Limiter
+}
+
+// newLoginThrottle picks the counter store from LOGIN_THROTTLE_STORE.
+// "memory" keeps counters per instance; anything else shares them through
+// the database.
+func newLoginThrottle(queries *database.Queries) *loginThrottle {
+	var store throttle.Store
+	switch os.Getenv("LOGIN_THROTTLE_STORE") {
+	case "memory":
+		store = throttle.NewMemoryStore()
+	default:
+		store = throttle.NewPostgresStore(queries)
+	}
+	return &loginThrottle{
+		store:   store,
+		account: throttle.NewLimiter(store, "account:", accountLoginPolicy),
+		ip:      throttle.NewLimiter(store, "ip:", ipLoginPolicy),
+	}
+}
+
+// accountKey identifies an account by the email typed in, whether or not a
+// user has it, so lockouts look the same for unknown addresses.
+func accountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// take lets a login for email from ip go ahead, counting it as failed until
+// it is shown to be right, or returns how long the login has to wait. A
+// login that goes ahead has to end in fail, or in passed and then succeed.
+func (t *loginThrottle) take(ctx context.Context, email, ip string) (time.Duration, error) {
+	now := time.Now()
+	key := accountKey(email)
+	wait, err := t.account.Take(ctx, key, now)
+	if err != nil || wait > 0 {
+		return wait, err
+	}
+	wait, err = t.ip.Take(ctx, ip, now)
+	if err != nil || wait > 0 {
+		if err := t.account.Forgive(ctx, key); err != nil {
+			log.Printf("Couldn't forgive login attempt: %v\n", err)
+		}
+		return wait, err
+	}
+	return 0, nil
+}
+
+// fail logs a failed login, which take has already counted. The log line
+// carries a fingerprint of the email rather than the address itself, and
+// reads the same whether the account exists or the password was wrong.
+func (t *loginThrottle) fail(ctx context.Context, email, ip string) {
+	log.Printf("Failed login for account %s from %s\n", auth.HashToken(accountKey(email))[:16], ip)
+}
+
+// passed gives the IP back the attempt take counted, once a credential
+// turned out right.
+func (t *loginThrottle) passed(ctx context.Context, ip string) {
+	if err := t.ip.Forgive(ctx, ip); err != nil {
+		log.Printf("Couldn't forgive login attempt: %v\n", err)
+	}
+}
+
+// succeed clears the account's failures once the user is fully logged in.
+func (t *loginThrottle) succeed(ctx context.Context, email string) {
+	if err := t.account.Reset(ctx, accountKey(email)); err != nil {
+		log.Printf("Couldn't reset failed logins: %v\n", err)
+	}
+}
+
+// checkCurrentPassword confirms a signed-in user's password before a
+// sensitive change. The check counts against the same limits as a login, so
+// a stolen access token can't be used to guess the password. On failure it
+// has already responded, with message for a wrong password.
+func (cfg *apiConfig) checkCurrentPassword(w http.ResponseWriter, req *http.Request, user database.User, password, message string) bool {
+	ip := clientIP(req)
+	wait, err := cfg.loginThrottle.take(req.Context(), user.Email, ip)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return false
+	}
+	if wait > 0 {
+		respondWithRetryAfter(w, wait)
+		return false
+	}
+
+	if _, err := cfg.passwordHasher.Verify(user.HashedPassword, password); err != nil {
+		cfg.loginThrottle.fail(req.Context(), user.Email, ip)
+		respondWithError(w, http.StatusUnauthorized, message)
+		return false
+	}
+	cfg.loginThrottle.passed(req.Context(), ip)
+	cfg.loginThrottle.succeed(req.Context(), user.Email)
+	return true
+}
+
+func (cfg *apiConfig) purgeLoginAttempts(ctx context.Context) error {
+	window := max(accountLoginPolicy.Window, ipLoginPolicy.Window, resetEmailPolicy.Window, resetIPPolicy.Window)
+	return cfg.loginThrottle.store.Cleanup(ctx, time.Now().Add(-window))
+}
+
+func respondWithRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	respondWithError(w, http.StatusTooManyRequests, "Too many failed login attempts, try again later")
+}
+
+// UnlockLogin clears the failed login counters of an account, an IP address
+// or both. It requires the login:unlock permission.
+func (cfg *apiConfig) UnlockLogin(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		Email string `json:"email"`
+		IP    string `json:"ip"`
+	}
+
+	caller := principalFromContext(req.Context())
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	if expectedJson.Email == "" && expectedJson.IP == "" {
+		respondWithError(w, http.StatusBadRequest, "Provide an email, an ip or both")
+		return
+	}
+
+	if expectedJson.Email != "" {
+		if err := cfg.loginThrottle.account.Reset(req.Context(), accountKey(expectedJson.Email)); err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+			return
+		}
+	}
+	if expectedJson.IP != "" {
+		if err := cfg.loginThrottle.ip.Reset(req.Context(), expectedJson.IP); err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+			return
+		}
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(caller.UserID),
+		Action:     "login.unlocked",
+		TargetType: "login_throttle",
+		TargetID:   accountKey(expectedJson.Email),
+		Details:    map[string]string{"ip": expectedJson.IP},
+	})
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/SergioFloresCorrea/Chirpy/internal/mailer"
+)
+
+// newMailer picks the mail transport from MAILER. "smtp" delivers through
+// SMTP_HOST; anything else logs messages to MAIL_LOG_PATH, or stdout when
+// that is unset.
+func newMailer() (mailer.Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@chirpy.local"
+	}
+
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return nil, fmt.Errorf("MAILER is smtp but SMTP_HOST is not set")
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return mailer.NewSMTPMailer(host, port, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from), nil
+	default:
+		path := os.Getenv("MAIL_LOG_PATH")
+		if path == "" {
+			return mailer.NewLogMailer(os.Stdout, from), nil
+		}
+		return mailer.NewFileMailer(path, from)
+	}
+}
+
+func (cfg *apiConfig) sendMail(ctx context.Context, to, subject, body string) {
+	err := cfg.mailer.Send(ctx, mailer.Message{To: to, Subject: subject, Body: body})
+	if err != nil {
+		log.Printf("Couldn't send %q mail: %v\n", subject, err)
+	}
+}
@@ -1,23 +1,54 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
 	"os"
-	"sync/atomic"
+	"path/filepath"
+	"time"
 
+	auth "github.com/SergioFloresCorrea/Chirpy/internal"
 	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/SergioFloresCorrea/Chirpy/internal/fixtures"
+	"github.com/SergioFloresCorrea/Chirpy/internal/mailer"
+	"github.com/SergioFloresCorrea/Chirpy/internal/moderation"
+	"github.com/SergioFloresCorrea/Chirpy/internal/oidc"
+	"github.com/SergioFloresCorrea/Chirpy/internal/passwordpolicy"
+	"github.com/SergioFloresCorrea/Chirpy/internal/webauthn"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
+// signingKeyRetention is how long a replaced signing key keeps verifying
+// tokens. It comfortably outlasts the one hour access token lifetime and gives
+// JWKS consumers time to refresh their caches.
+const signingKeyRetention = 24 * time.Hour
+
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	dbQueries      *database.Queries
-	platform       string
-	secret         string
-	polkaKey       string
+	metrics                *appMetrics
+	metricsToken           string
+	db                     *sql.DB
+	dbQueries              *database.Queries
+	platform               string
+	secret                 string
+	keyring                *auth.Keyring
+	polkaKey               string
+	baseURL                string
+	mailer                 mailer.Mailer
+	unverifiedRestrictions unverifiedRestrictions
+	deletionGracePeriod    time.Duration
+	exportDir              string
+	fixturesDir            string
+	loginThrottle          *loginThrottle
+	passwordResetThrottle  *loginThrottle
+	passwordHasher         auth.PasswordHasher
+	dummyPasswordHash      string
+	passwordPolicy         passwordpolicy.Policy
+	oidcProviders          map[string]*oidc.Client
+	relyingParty           *webauthn.RelyingParty
+	moderation             *moderation.Engine
 }
 
 func main() {
@@ -29,38 +60,271 @@ func main() {
 	}
 	dbURL := os.Getenv("DB_URL")
 	platform := os.Getenv("PLATFORM")
+	fixturesDir := os.Getenv("FIXTURES_DIR")
+	if fixturesDir == "" {
+		fixturesDir = "fixtures"
+	}
 	tokenSecret := os.Getenv("SECRET")
 	polkaKey := os.Getenv("POLKA_KEY")
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:" + port
+	}
+	restrictionList, ok := os.LookupEnv("UNVERIFIED_RESTRICTIONS")
+	if !ok {
+		restrictionList = defaultUnverifiedRestrictions
+	}
+	restrictions, err := parseUnverifiedRestrictions(restrictionList)
+	if err != nil {
+		log.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	deletionGracePeriod := 14 * 24 * time.Hour
+	if gracePeriod := os.Getenv("ACCOUNT_DELETION_GRACE_PERIOD"); gracePeriod != "" {
+		deletionGracePeriod, err = time.ParseDuration(gracePeriod)
+		if err != nil {
+			log.Printf("Invalid ACCOUNT_DELETION_GRACE_PERIOD: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	jwtAlgorithm := os.Getenv("JWT_ALGORITHM")
+	if jwtAlgorithm == "" {
+		jwtAlgorithm = auth.AlgorithmEdDSA
+	}
+	keyRotationInterval := 30 * 24 * time.Hour
+	if interval := os.Getenv("JWT_KEY_ROTATION_INTERVAL"); interval != "" {
+		keyRotationInterval, err = time.ParseDuration(interval)
+		if err != nil {
+			log.Printf("Invalid JWT_KEY_ROTATION_INTERVAL: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	tokenPolicy := auth.DefaultTokenPolicy
+	tokenPolicy.Leeway = 30 * time.Second
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		tokenPolicy.Issuer = issuer
+	}
+	if audience := os.Getenv("JWT_AUDIENCE"); audience != "" {
+		tokenPolicy.Audience = audience
+	}
+	if leeway := os.Getenv("JWT_LEEWAY"); leeway != "" {
+		tokenPolicy.Leeway, err = time.ParseDuration(leeway)
+		if err != nil {
+			log.Printf("Invalid JWT_LEEWAY: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	keyring := auth.NewKeyring(jwtAlgorithm, signingKeyRetention, os.Getenv("JWT_KEY_DIR"), tokenPolicy)
+	if err := keyring.Load(); err != nil {
+		log.Printf("We couldn't load the signing keys: %v\n", err)
+		os.Exit(1)
+	}
+	if err := keyring.RotateIfDue(keyRotationInterval); err != nil {
+		log.Printf("We couldn't create a signing key: %v\n", err)
+		os.Exit(1)
+	}
+	exportDir := os.Getenv("EXPORT_DIR")
+	if exportDir == "" {
+		exportDir = filepath.Join(os.TempDir(), "chirpy-exports")
+	}
+	mailSender, err := newMailer()
+	if err != nil {
+		log.Printf("We couldn't set up the mailer: %v\n", err)
+		os.Exit(1)
+	}
+	passwordHasher, err := newPasswordHasher()
+	if err != nil {
+		log.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	dummyPasswordHash, err := passwordHasher.Hash("chirpy-dummy-password")
+	if err != nil {
+		log.Printf("We couldn't hash a password: %v\n", err)
+		os.Exit(1)
+	}
+	passwordPolicy, err := newPasswordPolicy()
+	if err != nil {
+		log.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	oidcProviders, err := newOIDCProviders(baseURL)
+	if err != nil {
+		log.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	relyingParty, err := newRelyingParty(baseURL)
+	if err != nil {
+		log.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	moderationEngine, err := newModerationEngine()
+	if err != nil {
+		log.Printf("%v\n", err)
+		os.Exit(1)
+	}
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		log.Printf("We couldn't access the database: %v\n", err)
 		os.Exit(1)
 	}
-	dbQueries := database.New(db)
-	apiCfg := &apiConfig{dbQueries: dbQueries, platform: platform, secret: tokenSecret, polkaKey: polkaKey}
+	chirpyMetrics := newAppMetrics(db)
+	dbQueries := database.New(chirpyMetrics.instrument(db))
+	loginThrottle := newLoginThrottle(dbQueries)
+	apiCfg := &apiConfig{
+		db:                     db,
+		dbQueries:              dbQueries,
+		metrics:                chirpyMetrics,
+		metricsToken:           os.Getenv("METRICS_TOKEN"),
+		platform:               platform,
+		secret:                 tokenSecret,
+		keyring:                keyring,
+		polkaKey:               polkaKey,
+		baseURL:                baseURL,
+		mailer:                 mailSender,
+		unverifiedRestrictions: restrictions,
+		deletionGracePeriod:    deletionGracePeriod,
+		exportDir:              exportDir,
+		fixturesDir:            fixturesDir,
+		loginThrottle:          loginThrottle,
+		passwordResetThrottle:  newPasswordResetThrottle(loginThrottle.store),
+		passwordHasher:         passwordHasher,
+		dummyPasswordHash:      dummyPasswordHash,
+		passwordPolicy:         passwordPolicy,
+		oidcProviders:          oidcProviders,
+		relyingParty:           relyingParty,
+		moderation:             moderationEngine,
+	}
+	apiCfg.bootstrapAdmin(context.Background())
+	if apiCfg.metricsToken == "" && !openMetricsPlatform(platform) {
+		log.Printf("METRICS_TOKEN isn't set, so /metrics is disabled on platform %s\n", platform)
+	}
+
 	mux := http.NewServeMux()
 	mux.Handle("/app/", apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(".")))))
 	mux.HandleFunc("GET /api/healthz", ServerReady)
-	mux.HandleFunc("GET /admin/metrics", apiCfg.CountRequests)
-	mux.HandleFunc("POST /admin/reset", apiCfg.ResetCounterRequests)
+	mux.HandleFunc("GET /metrics", apiCfg.ServeMetrics)
+	mux.HandleFunc("GET /.well-known/jwks.json", apiCfg.JWKS)
+	mux.Handle("GET /admin/metrics", apiCfg.middlewareRequirePermission(auth.PermissionMetricsRead, http.HandlerFunc(apiCfg.CountRequests)))
+	mux.Handle("GET /admin/audit", apiCfg.middlewareRequirePermission(auth.PermissionAuditRead, http.HandlerFunc(apiCfg.ListAuditLog)))
+	mux.Handle("GET /admin/audit/export", apiCfg.middlewareRequirePermission(auth.PermissionAuditRead, http.HandlerFunc(apiCfg.ExportAuditLog)))
+	mux.Handle("POST /admin/login/unlock", apiCfg.middlewareRequirePermission(auth.PermissionLoginUnlock, http.HandlerFunc(apiCfg.UnlockLogin)))
+
+	roleMux := http.NewServeMux()
+	roleMux.HandleFunc("GET /admin/roles", apiCfg.ListRoles)
+	roleMux.HandleFunc("GET /admin/users/{userID}/roles", apiCfg.GetUserRoles)
+	roleMux.HandleFunc("POST /admin/users/{userID}/roles", apiCfg.GrantUserRole)
+	roleMux.HandleFunc("DELETE /admin/users/{userID}/roles/{role}", apiCfg.RevokeUserRole)
+	rolesHandler := apiCfg.middlewareRequirePermission(auth.PermissionRolesManage, roleMux)
+	mux.Handle("/admin/roles", rolesHandler)
+	mux.Handle("/admin/users/", rolesHandler)
+
+	suspensionMux := http.NewServeMux()
+	suspensionMux.HandleFunc("GET /admin/users/{userID}/suspensions", apiCfg.ListUserSuspensions)
+	suspensionMux.HandleFunc("POST /admin/users/{userID}/suspensions", apiCfg.SuspendUser)
+	suspensionMux.HandleFunc("DELETE /admin/users/{userID}/suspensions/{suspensionID}", apiCfg.LiftUserSuspension)
+	suspensionsHandler := apiCfg.middlewareRequirePermission(auth.PermissionUsersSuspend, suspensionMux)
+	mux.Handle("/admin/users/{userID}/suspensions", suspensionsHandler)
+	mux.Handle("/admin/users/{userID}/suspensions/", suspensionsHandler)
+
+	reportMux := http.NewServeMux()
+	reportMux.HandleFunc("GET /admin/reports", apiCfg.ListReports)
+	reportMux.HandleFunc("GET /admin/reports/{reportID}", apiCfg.GetReport)
+	reportMux.HandleFunc("POST /admin/reports/{reportID}/claim", apiCfg.ClaimReport)
+	reportMux.HandleFunc("POST /admin/reports/{reportID}/notes", apiCfg.AddReportNote)
+	reportMux.HandleFunc("POST /admin/reports/{reportID}/resolve", apiCfg.ResolveReport)
+	reportMux.HandleFunc("GET /admin/users/{userID}/warnings", apiCfg.ListUserWarnings)
+	reportsHandler := apiCfg.middlewareRequirePermission(auth.PermissionReportsModerate, reportMux)
+	mux.Handle("/admin/reports", reportsHandler)
+	mux.Handle("/admin/reports/", reportsHandler)
+	mux.Handle("/admin/users/{userID}/warnings", reportsHandler)
+
+	chirpModerationMux := http.NewServeMux()
+	chirpModerationMux.HandleFunc("GET /admin/chirps/held", apiCfg.ListHeldChirps)
+	chirpModerationMux.HandleFunc("POST /admin/chirps/{chirpID}/approve", apiCfg.ApproveHeldChirp)
+	chirpModerationMux.HandleFunc("POST /admin/chirps/{chirpID}/reject", apiCfg.RejectHeldChirp)
+	mux.Handle("/admin/chirps/", apiCfg.middlewareRequirePermission(auth.PermissionChirpsModerate, chirpModerationMux))
+
+	// Fixtures wipe data, so outside dev and test their routes don't exist.
+	if fixtures.Guard(platform) == nil {
+		fixtureMux := http.NewServeMux()
+		fixtureMux.HandleFunc("GET /admin/fixtures", apiCfg.ListFixtures)
+		fixtureMux.HandleFunc("POST /admin/fixtures/reset", apiCfg.ResetFixtures)
+		fixtureMux.HandleFunc("POST /admin/fixtures/truncate", apiCfg.TruncateFixtures)
+		fixtureMux.HandleFunc("POST /admin/fixtures/load", apiCfg.LoadFixtures)
+		fixturesHandler := apiCfg.middlewareRequirePermission(auth.PermissionDataReset, fixtureMux)
+		mux.Handle("/admin/fixtures", fixturesHandler)
+		mux.Handle("/admin/fixtures/", fixturesHandler)
+		log.Printf("Fixtures are enabled on platform %s, reading datasets from %s\n", platform, fixturesDir)
+	}
 
 	mux.HandleFunc("POST /api/chirps", apiCfg.ValidateAndSaveChirp)
 	mux.HandleFunc("GET /api/chirps", apiCfg.GetAllChirps)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.GetChirpByID)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.DeleteChirpByID)
+	mux.HandleFunc("POST /api/reports", apiCfg.CreateReport)
 
 	mux.HandleFunc("POST /api/users", apiCfg.CreateUser)
-	mux.HandleFunc("PUT /api/users", apiCfg.UpdateOwnEmail)
+	mux.HandleFunc("POST /api/users/me/password", apiCfg.ChangeOwnPassword)
+	mux.HandleFunc("POST /api/users/me/email", apiCfg.RequestEmailChange)
+	mux.HandleFunc("DELETE /api/users/me", apiCfg.DeleteOwnAccount)
+	mux.HandleFunc("POST /api/users/me/export", apiCfg.RequestDataExport)
+	mux.HandleFunc("GET /api/users/me/export/{exportID}", apiCfg.GetDataExport)
+	mux.HandleFunc("POST /api/users/me/mfa/totp", apiCfg.EnrollTOTP)
+	mux.HandleFunc("POST /api/users/me/mfa/totp/confirm", apiCfg.ConfirmTOTP)
+	mux.HandleFunc("DELETE /api/users/me/mfa/totp", apiCfg.DisableTOTP)
+	mux.HandleFunc("POST /api/users/me/mfa/recovery-codes", apiCfg.RegenerateRecoveryCodes)
+	mux.HandleFunc("GET /api/users/me/passkeys", apiCfg.ListPasskeys)
+	mux.HandleFunc("POST /api/users/me/passkeys/register/begin", apiCfg.BeginPasskeyRegistration)
+	mux.HandleFunc("POST /api/users/me/passkeys/register/finish", apiCfg.FinishPasskeyRegistration)
+	mux.HandleFunc("PATCH /api/users/me/passkeys/{passkeyID}", apiCfg.RenamePasskey)
+	mux.HandleFunc("DELETE /api/users/me/passkeys/{passkeyID}", apiCfg.DeletePasskey)
+	mux.HandleFunc("POST /api/users/verify", apiCfg.VerifyEmail)
+	mux.HandleFunc("POST /api/users/verify/resend", apiCfg.ResendVerificationEmail)
+
+	mux.HandleFunc("POST /api/password/forgot", apiCfg.ForgotPassword)
+	mux.HandleFunc("POST /api/password/reset", apiCfg.ResetPassword)
 
 	mux.HandleFunc("POST /api/login", apiCfg.LoginUser)
+	mux.HandleFunc("POST /api/login/mfa", apiCfg.CompleteMFALogin)
+	mux.HandleFunc("POST /api/login/passkey/begin", apiCfg.BeginPasskeyLogin)
+	mux.HandleFunc("POST /api/login/passkey/finish", apiCfg.FinishPasskeyLogin)
+	mux.HandleFunc("GET /api/oidc/{provider}/login", apiCfg.StartOIDCLogin)
+	mux.HandleFunc("GET /api/oidc/{provider}/callback", apiCfg.OIDCCallback)
 	mux.HandleFunc("POST /api/refresh", apiCfg.RefreshAccessToken)
 	mux.HandleFunc("POST /api/revoke", apiCfg.RevokeRefreshToken)
 
+	mux.HandleFunc("GET /api/sessions", apiCfg.ListSessions)
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", apiCfg.RevokeSession)
+	mux.HandleFunc("POST /api/sessions/revoke-all", apiCfg.RevokeAllSessions)
+	mux.HandleFunc("GET /api/tokens", apiCfg.ListPersonalAccessTokens)
+	mux.HandleFunc("POST /api/tokens", apiCfg.CreatePersonalAccessToken)
+	mux.HandleFunc("DELETE /api/tokens/{tokenID}", apiCfg.RevokePersonalAccessToken)
+	mux.HandleFunc("GET /api/oauth/clients", apiCfg.ListOAuthClients)
+	mux.HandleFunc("POST /api/oauth/clients", apiCfg.CreateOAuthClient)
+	mux.HandleFunc("DELETE /api/oauth/clients/{clientID}", apiCfg.DeleteOAuthClient)
+
+	mux.HandleFunc("GET /oauth/authorize", apiCfg.StartOAuthAuthorization)
+	mux.HandleFunc("POST /oauth/authorize", apiCfg.CompleteOAuthAuthorization)
+	mux.HandleFunc("POST /oauth/token", apiCfg.OAuthToken)
+	mux.HandleFunc("POST /oauth/revoke", apiCfg.RevokeOAuthToken)
+	mux.HandleFunc("POST /oauth/introspect", apiCfg.IntrospectOAuthToken)
+
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.UpgradoUserToRed)
 
+	go runPeriodically(context.Background(), "purge deleted accounts", 10*time.Minute, apiCfg.purgeDeletedAccounts)
+	go runPeriodically(context.Background(), "purge expired exports", time.Hour, apiCfg.purgeExpiredExports)
+	go runPeriodically(context.Background(), "purge login attempts", time.Hour, apiCfg.purgeLoginAttempts)
+	go runPeriodically(context.Background(), "purge OIDC login states", time.Hour, apiCfg.purgeExpiredOIDCLoginStates)
+	go runPeriodically(context.Background(), "purge OAuth codes", time.Hour, apiCfg.purgeExpiredOAuthCodes)
+	go runPeriodically(context.Background(), "purge WebAuthn challenges", time.Hour, apiCfg.purgeExpiredWebAuthnChallenges)
+	go runPeriodically(context.Background(), "reload moderation rules", 30*time.Second, apiCfg.reloadModerationRules)
+	go runPeriodically(context.Background(), "rotate signing keys", time.Hour, func(ctx context.Context) error {
+		return keyring.RotateIfDue(keyRotationInterval)
+	})
+
 	srv := &http.Server{
 		Addr:    ":" + port,
-		Handler: mux,
+		Handler: middlewareRequestID(chirpyMetrics.middlewareInstrument(mux)),
 	}
 
 	log.Printf("Serving on port: %s\n", port)
@@ -69,7 +333,7 @@ func main() {
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		cfg.fileserverHits.Add(1)
+		cfg.metrics.fileserverHits.Inc()
 		next.ServeHTTP(w, req)
 	})
 }
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/SergioFloresCorrea/Chirpy/internal/metrics"
+)
+
+// appMetrics are the metrics Chirpy updates as it runs. Database pool stats
+// aren't here: they are read from sql.DB when scraped.
+type appMetrics struct {
+	registry        *metrics.Registry
+	fileserverHits  *metrics.Counter
+	requests        *metrics.CounterVec
+	requestDuration *metrics.HistogramVec
+	inFlight        *metrics.Gauge
+	queryDuration   *metrics.HistogramVec
+	queryErrors     *metrics.CounterVec
+	logins          *metrics.CounterVec
+	chirps          *metrics.CounterVec
+}
+
+func newAppMetrics(db *sql.DB) *appMetrics {
+	registry := metrics.NewRegistry()
+	m := &appMetrics{
+		registry:        registry,
+		fileserverHits:  registry.NewCounter("chirpy_fileserver_hits_total", "Requests served from /app/."),
+		requests:        registry.NewCounterVec("chirpy_http_requests_total", "HTTP requests by route pattern and status code.", "route", "code"),
+		requestDuration: registry.NewHistogramVec("chirpy_http_request_duration_seconds", "HTTP request latency by route pattern and status code.", metrics.DefaultBuckets, "route", "code"),
+		inFlight:        registry.NewGauge("chirpy_http_requests_in_flight", "HTTP requests being served."),
+		queryDuration:   registry.NewHistogramVec("chirpy_db_query_duration_seconds", "Database query latency by sqlc query name.", metrics.DefaultBuckets, "query"),
+		queryErrors:     registry.NewCounterVec("chirpy_db_query_errors_total", "Database queries that failed, by sqlc query name.", "query"),
+		logins:          registry.NewCounterVec("chirpy_logins_total", "Login attempts by method and result.", "method", "result"),
+		chirps:          registry.NewCounterVec("chirpy_chirps_posted_total", "Chirps posted, by what moderation did with them.", "outcome"),
+	}
+
+	stats := func(f func(sql.DBStats) float64) func() float64 {
+		return func() float64 { return f(db.Stats()) }
+	}
+	registry.NewGaugeFunc("chirpy_db_max_open_connections", "Maximum number of open database connections.",
+		stats(func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }))
+	registry.NewGaugeFunc("chirpy_db_open_connections", "Open database connections, in use or idle.",
+		stats(func(s sql.DBStats) float64 { return float64(s.OpenConnections) }))
+	registry.NewGaugeFunc("chirpy_db_connections_in_use", "Database connections in use.",
+		stats(func(s sql.DBStats) float64 { return float64(s.InUse) }))
+	registry.NewGaugeFunc("chirpy_db_connections_idle", "Idle database connections.",
+		stats(func(s sql.DBStats) float64 { return float64(s.Idle) }))
+	registry.NewCounterFunc("chirpy_db_connection_waits_total", "Times a query waited for a free database connection.",
+		stats(func(s sql.DBStats) float64 { return float64(s.WaitCount) }))
+	registry.NewCounterFunc("chirpy_db_connection_wait_seconds_total", "Time spent waiting for a free database connection.",
+		stats(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }))
+	registry.NewCounterFunc("chirpy_db_connections_closed_max_idle_total", "Connections closed because of the idle connection limit.",
+		stats(func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }))
+	registry.NewCounterFunc("chirpy_db_connections_closed_max_idle_time_total", "Connections closed because they were idle too long.",
+		stats(func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }))
+	registry.NewCounterFunc("chirpy_db_connections_closed_max_lifetime_total", "Connections closed because they reached their maximum lifetime.",
+		stats(func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }))
+
+	start := float64(time.Now().Unix())
+	registry.NewGaugeFunc("process_start_time_seconds", "Start time of the process since the Unix epoch, in seconds.",
+		func() float64 { return start })
+	registry.NewGaugeFunc("go_goroutines", "Goroutines that currently exist.",
+		func() float64 { return float64(runtime.NumGoroutine()) })
+	registry.NewGaugeFunc("go_memstats_heap_alloc_bytes", "Bytes of allocated heap objects.",
+		func() float64 {
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			return float64(stats.HeapAlloc)
+		})
+	return m
+}
+
+// countLogin records the outcome of checking a login credential.
+func (m *appMetrics) countLogin(method string, ok bool) {
+	result := "failure"
+	if ok {
+		result = "success"
+	}
+	m.logins.With(method, result).Inc()
+}
+
+// ServeMetrics serves the metrics to Prometheus. Scrapers have to send
+// METRICS_TOKEN as a bearer token. Without a token the endpoint is only open
+// on dev and test platforms; elsewhere it doesn't exist, so a deployment that
+// forgets to set one exposes nothing.
+func (cfg *apiConfig) ServeMetrics(w http.ResponseWriter, req *http.Request) {
+	if cfg.metricsToken == "" {
+		if !openMetricsPlatform(cfg.platform) {
+			respondWithError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+	} else {
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.metricsToken)) != 1 {
+			respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+	}
+	cfg.metrics.registry.Handler().ServeHTTP(w, req)
+}
+
+// openMetricsPlatform reports whether /metrics may be served without a token.
+func openMetricsPlatform(platform string) bool {
+	return platform == "dev" || platform == "test"
+}
+
+// statusRecorder remembers the status code a handler responded with.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// middlewareInstrument counts and times the requests mux serves. It has to
+// wrap mux directly: the route label is the pattern mux matched, which it
+// sets on the request it was given. Route groups served by a nested mux show
+// up under the group's pattern, and requests nothing matched as "unmatched",
+// so the number of label values stays bounded.
+func (m *appMetrics) middlewareInstrument(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		mux.ServeHTTP(recorder, req)
+
+		route := req.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		code := strconv.Itoa(status)
+		m.requests.With(route, code).Inc()
+		m.requestDuration.With(route, code).Observe(time.Since(start).Seconds())
+	})
+}
+
+// instrumentedDB times the queries sqlc sends through it, labelled with the
+// query's name from its "-- name:" comment.
+type instrumentedDB struct {
+	db      database.DBTX
+	metrics *appMetrics
+}
+
+func (m *appMetrics) instrument(db database.DBTX) database.DBTX {
+	return instrumentedDB{db: db, metrics: m}
+}
+
+// queriesWithTx is dbQueries.WithTx for instrumented queries.
+func (cfg *apiConfig) queriesWithTx(tx *sql.Tx) *database.Queries {
+	return database.New(cfg.metrics.instrument(tx))
+}
+
+func (i instrumentedDB) observe(query string, start time.Time, err error) {
+	name := queryName(query)
+	i.metrics.queryDuration.With(name).Observe(time.Since(start).Seconds())
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		i.metrics.queryErrors.With(name).Inc()
+	}
+}
+
+func queryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "other"
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
+
+func (i instrumentedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := i.db.ExecContext(ctx, query, args...)
+	i.observe(query, start, err)
+	return result, err
+}
+
+func (i instrumentedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return i.db.PrepareContext(ctx, query)
+}
+
+func (i instrumentedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := i.db.QueryContext(ctx, query, args...)
+	i.observe(query, start, err)
+	return rows, err
+}
+
+func (i instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := i.db.QueryRowContext(ctx, query, args...)
+	i.observe(query, start, row.Err())
+	return row
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	auth "github.com/SergioFloresCorrea/Chirpy/internal"
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	totpIssuer          = "Chirpy"
+	recoveryCodeCount   = 10
+	mfaChallengePurpose = "mfa_challenge"
+	mfaChallengeTTL     = 5 * time.Minute
+	// mfaMaxAttempts bounds the guesses per challenge, so brute forcing a
+	// six digit code means going through the password check again and again.
+	mfaMaxAttempts = 5
+)
+
+// EnrollTOTP generates a new TOTP secret for the caller. It only takes
+// effect once ConfirmTOTP sees a code produced from it; until then it can be
+// replaced by enrolling again.
+func (cfg *apiConfig) EnrollTOTP(w http.ResponseWriter, req *http.Request) {
+	type ResponseJson struct {
+		Secret     string `json:"secret"`
+		OtpauthURI string `json:"otpauth_uri"`
+	}
+
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	_, err = cfg.dbQueries.UpsertTOTPEnrollment(req.Context(), database.UpsertTOTPEnrollmentParams{
+		UserID:    user.ID,
+		CreatedAt: time.Now(),
+		Secret:    secret,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, ResponseJson{
+		Secret:     secret,
+		OtpauthURI: auth.TOTPURI(totpIssuer, user.Email, secret),
+	})
+}
+
+// ConfirmTOTP turns on two-factor authentication once the caller proves
+// their authenticator produces valid codes, and hands out recovery codes.
+func (cfg *apiConfig) ConfirmTOTP(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		Code string `json:"code"`
+	}
+
+	type ResponseJson struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	totp, err := cfg.dbQueries.GetTOTPByUserID(req.Context(), userID)
+	if err != nil || totp.ConfirmedAt.Valid {
+		respondWithError(w, http.StatusBadRequest, "No pending two-factor enrollment")
+		return
+	}
+
+	step, ok := auth.ValidateTOTPCode(totp.Secret, expectedJson.Code, time.Now(), totp.LastUsedStep)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid code")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	confirmed, err := qtx.ConfirmTOTP(req.Context(), database.ConfirmTOTPParams{
+		ConfirmedAt:  sql.NullTime{Time: time.Now(), Valid: true},
+		LastUsedStep: step,
+		UserID:       userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if confirmed == 0 {
+		respondWithError(w, http.StatusBadRequest, "No pending two-factor enrollment")
+		return
+	}
+
+	codes, err := replaceRecoveryCodes(req.Context(), qtx, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(userID),
+		Action:     "user.mfa_enabled",
+		TargetType: "user",
+		TargetID:   userID.String(),
+	})
+	respondWithJSON(w, http.StatusOK, ResponseJson{RecoveryCodes: codes})
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of the caller,
+// including unused ones.
+func (cfg *apiConfig) RegenerateRecoveryCodes(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		Password string `json:"password"`
+	}
+
+	type ResponseJson struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if !cfg.checkCurrentPassword(w, req, user, expectedJson.Password, "Password is incorrect") {
+		return
+	}
+
+	totp, err := cfg.dbQueries.GetTOTPByUserID(req.Context(), userID)
+	if err != nil || !totp.ConfirmedAt.Valid {
+		respondWithError(w, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	codes, err := replaceRecoveryCodes(req.Context(), qtx, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(userID),
+		Action:     "user.recovery_codes_regenerated",
+		TargetType: "user",
+		TargetID:   userID.String(),
+	})
+	respondWithJSON(w, http.StatusOK, ResponseJson{RecoveryCodes: codes})
+}
+
+// DisableTOTP turns two-factor authentication off and discards the recovery
+// codes. It asks for the password so a stolen access token can't strip the
+// second factor.
+func (cfg *apiConfig) DisableTOTP(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		Password string `json:"password"`
+	}
+
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if !cfg.checkCurrentPassword(w, req, user, expectedJson.Password, "Password is incorrect") {
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	deleted, err := qtx.DeleteTOTP(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Two-factor authentication is not enabled")
+		return
+	}
+
+	if err := qtx.DeleteRecoveryCodesForUser(req.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(userID),
+		Action:     "user.mfa_disabled",
+		TargetType: "user",
+		TargetID:   userID.String(),
+	})
+	cfg.sendMail(req.Context(), user.Email, "Two-factor authentication was turned off",
+		"Two-factor authentication was just turned off for your Chirpy account. If this wasn't you, change your password right away.")
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+// replaceRecoveryCodes discards the user's recovery codes and stores the
+// hashes of a fresh set, which is returned in clear text exactly once.
+func replaceRecoveryCodes(ctx context.Context, queries *database.Queries, userID uuid.UUID) ([]string, error) {
+	if err := queries.DeleteRecoveryCodesForUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		err := queries.CreateRecoveryCode(ctx, database.CreateRecoveryCodeParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UserID:    userID,
+			CodeHash:  auth.HashToken(auth.NormalizeRecoveryCode(code)),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// startMFAChallenge answers a correct password for an account with two-factor
+// authentication by handing out a short-lived token for CompleteMFALogin
+// instead of a session.
+func (cfg *apiConfig) startMFAChallenge(w http.ResponseWriter, req *http.Request, userID uuid.UUID, deviceName string) {
+	type ResponseJson struct {
+		MFARequired bool      `json:"mfa_required"`
+		MFAToken    string    `json:"mfa_token"`
+		ExpiresAt   time.Time `json:"expires_at"`
+	}
+
+	challenge, err := cfg.dbQueries.CreateMFAChallenge(req.Context(), database.CreateMFAChallengeParams{
+		ID:         uuid.New(),
+		CreatedAt:  time.Now(),
+		UserID:     userID,
+		DeviceName: deviceName,
+		ExpiresAt:  time.Now().Add(mfaChallengeTTL),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, ResponseJson{
+		MFARequired: true,
+		MFAToken:    auth.MakeSignedToken(challenge.ID, mfaChallengePurpose, cfg.secret),
+		ExpiresAt:   challenge.ExpiresAt,
+	})
+}
+
+// CompleteMFALogin finishes a login started by LoginUser with either a TOTP
+// code or one of the user's recovery codes.
+func (cfg *apiConfig) CompleteMFALogin(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	challengeID, err := auth.ParseSignedToken(expectedJson.MFAToken, mfaChallengePurpose, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+
+	challenge, err := cfg.dbQueries.GetMFAChallenge(req.Context(), challengeID)
+	if err != nil || challenge.UsedAt.Valid || !time.Now().Before(challenge.ExpiresAt) {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), challenge.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+
+	// Codes are guessed against the same limits as passwords, so fresh
+	// challenges don't buy fresh guesses.
+	ip := clientIP(req)
+	wait, err := cfg.loginThrottle.take(req.Context(), user.Email, ip)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if wait > 0 {
+		respondWithRetryAfter(w, wait)
+		return
+	}
+
+	counted, err := cfg.dbQueries.RecordMFAChallengeAttempt(req.Context(), database.RecordMFAChallengeAttemptParams{
+		ID:       challenge.ID,
+		Attempts: mfaMaxAttempts,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if counted == 0 {
+		respondWithError(w, http.StatusUnauthorized, "Too many attempts, log in again")
+		return
+	}
+	totp, err := cfg.dbQueries.GetTOTPByUserID(req.Context(), user.ID)
+	if err != nil || !totp.ConfirmedAt.Valid {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	var verified bool
+	switch {
+	case expectedJson.Code != "":
+		step, ok := auth.ValidateTOTPCode(totp.Secret, expectedJson.Code, time.Now(), totp.LastUsedStep)
+		if ok {
+			// Only one request can move the step forward, so a code that is
+			// replayed concurrently is still accepted once.
+			advanced, err := qtx.SetTOTPLastUsedStep(req.Context(), database.SetTOTPLastUsedStepParams{
+				LastUsedStep: step,
+				UserID:       user.ID,
+			})
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+				return
+			}
+			verified = advanced == 1
+		}
+	case expectedJson.RecoveryCode != "":
+		used, err := qtx.UseRecoveryCode(req.Context(), database.UseRecoveryCodeParams{
+			UsedAt:   sql.NullTime{Time: time.Now(), Valid: true},
+			UserID:   user.ID,
+			CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(expectedJson.RecoveryCode)),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+			return
+		}
+		verified = used == 1
+	default:
+		respondWithError(w, http.StatusBadRequest, "Provide either code or recovery_code")
+		return
+	}
+	if !verified {
+		cfg.loginThrottle.fail(req.Context(), user.Email, ip)
+		cfg.metrics.countLogin("mfa", false)
+		respondWithError(w, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+
+	consumed, err := qtx.UseMFAChallenge(req.Context(), database.UseMFAChallengeParams{
+		UsedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		ID:     challenge.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if consumed == 0 {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	cfg.loginThrottle.passed(req.Context(), ip)
+	cfg.metrics.countLogin("mfa", true)
+
+	if expectedJson.RecoveryCode != "" {
+		cfg.recordAudit(req.Context(), req, auditEntry{
+			ActorID:    actor(user.ID),
+			Action:     "user.recovery_code_used",
+			TargetType: "user",
+			TargetID:   user.ID.String(),
+		})
+	}
+	if cfg.refuseSuspendedLogin(w, req, user.ID) {
+		return
+	}
+	cfg.completeLogin(w, req, user, challenge.DeviceName)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	auth "github.com/SergioFloresCorrea/Chirpy/internal"
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type OAuthClient struct {
+	ID           string    `json:"client_id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Public       bool      `json:"public"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func toOAuthClient(client database.OauthClient) OAuthClient {
+	return OAuthClient{
+		ID:           client.ID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		Scopes:       client.Scopes,
+		Public:       !client.SecretHash.Valid,
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+// CreateOAuthClient registers a third-party application owned by the caller.
+// Confidential clients get a secret, which is only ever shown in this
+// response; public clients such as mobile apps can't keep one and rely on
+// PKCE alone.
+func (cfg *apiConfig) CreateOAuthClient(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       []string `json:"scopes"`
+		Public       bool     `json:"public"`
+	}
+	type ResponseJson struct {
+		OAuthClient
+		ClientSecret string `json:"client_secret,omitempty"`
+	}
+
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	name := strings.TrimSpace(expectedJson.Name)
+	if name == "" {
+		respondWithError(w, http.StatusBadRequest, "Client name is required")
+		return
+	}
+	if len(expectedJson.RedirectURIs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one redirect URI is required")
+		return
+	}
+	for _, redirectURI := range expectedJson.RedirectURIs {
+		if err := auth.ValidateRedirectURI(redirectURI); err != nil {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid redirect URI %q: %v", redirectURI, err))
+			return
+		}
+	}
+	if len(expectedJson.Scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one scope is required")
+		return
+	}
+	for _, scope := range expectedJson.Scopes {
+		if !auth.HasScope(auth.DelegatedScopes, scope) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Scope %q can't be granted to an OAuth client", scope))
+			return
+		}
+	}
+
+	var secret string
+	var secretHash sql.NullString
+	if !expectedJson.Public {
+		var err error
+		secret, err = auth.MakeRefreshToken()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashToken(secret), Valid: true}
+	}
+
+	client, err := cfg.dbQueries.CreateOAuthClient(req.Context(), database.CreateOAuthClientParams{
+		ID:           uuid.NewString(),
+		CreatedAt:    time.Now(),
+		OwnerID:      userID,
+		Name:         name,
+		SecretHash:   secretHash,
+		RedirectUris: expectedJson.RedirectURIs,
+		Scopes:       expectedJson.Scopes,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(userID),
+		Action:     "oauth_client.created",
+		TargetType: "oauth_client",
+		TargetID:   client.ID,
+		Details:    map[string]any{"name": client.Name, "redirect_uris": client.RedirectUris, "scopes": client.Scopes},
+	})
+	respondWithJSON(w, http.StatusCreated, ResponseJson{
+		OAuthClient:  toOAuthClient(client),
+		ClientSecret: secret,
+	})
+}
+
+func (cfg *apiConfig) ListOAuthClients(w http.ResponseWriter, req *http.Request) {
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	clients, err := cfg.dbQueries.ListOAuthClientsForOwner(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	responseJson := make([]OAuthClient, 0, len(clients))
+	for _, client := range clients {
+		responseJson = append(responseJson, toOAuthClient(client))
+	}
+	respondWithJSON(w, http.StatusOK, responseJson)
+}
+
+// DeleteOAuthClient removes a client along with every authorization code and
+// refresh token issued to it.
+func (cfg *apiConfig) DeleteOAuthClient(w http.ResponseWriter, req *http.Request) {
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	clientID := req.PathValue("clientID")
+	deleted, err := cfg.dbQueries.DeleteOAuthClient(req.Context(), database.DeleteOAuthClientParams{
+		ID:      clientID,
+		OwnerID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Client not found")
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(userID),
+		Action:     "oauth_client.deleted",
+		TargetType: "oauth_client",
+		TargetID:   clientID,
+	})
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,751 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	auth "github.com/SergioFloresCorrea/Chirpy/internal"
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	oauthCodeTTL        = 2 * time.Minute
+	oauthAccessTokenTTL = time.Hour
+)
+
+// oauthScopeDescriptions is what the consent screen tells the user a scope
+// lets the client do.
+var oauthScopeDescriptions = map[string]string{
+	auth.ScopeChirpsRead:   "Read chirps, including yours that moderators held or hid",
+	auth.ScopeChirpsWrite:  "Post and delete chirps as you",
+	auth.ScopeProfileWrite: "Change your email address",
+}
+
+// oauthError is an error response as defined by RFC 6749, section 5.2.
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *oauthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func respondWithOAuthError(w http.ResponseWriter, code int, errorCode, description string) {
+	w.Header().Set("Cache-Control", "no-store")
+	if code == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+	}
+	respondWithJSON(w, code, oauthError{Code: errorCode, Description: description})
+}
+
+// authorizationRequest is a validated request to /oauth/authorize.
+type authorizationRequest struct {
+	Client        database.OauthClient
+	RedirectURI   string
+	Scopes        []string
+	State         string
+	CodeChallenge string
+}
+
+// parseAuthorizationRequest validates the parameters of an authorization
+// request. Until the client and redirect URI check out, the returned
+// request has no RedirectURI and errors must be shown to the user rather
+// than sent to the client.
+func (cfg *apiConfig) parseAuthorizationRequest(ctx context.Context, params url.Values) (authorizationRequest, error) {
+	client, err := cfg.dbQueries.GetOAuthClient(ctx, params.Get("client_id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return authorizationRequest{}, &oauthError{"invalid_client", "Unknown client"}
+	}
+	if err != nil {
+		return authorizationRequest{}, err
+	}
+
+	redirectURI := params.Get("redirect_uri")
+	if redirectURI == "" && len(client.RedirectUris) == 1 {
+		redirectURI = client.RedirectUris[0]
+	}
+	if !slices.Contains(client.RedirectUris, redirectURI) {
+		return authorizationRequest{Client: client}, &oauthError{"invalid_request", "The redirect URI isn't registered for this client"}
+	}
+
+	ar := authorizationRequest{
+		Client:        client,
+		RedirectURI:   redirectURI,
+		State:         params.Get("state"),
+		CodeChallenge: params.Get("code_challenge"),
+	}
+	if params.Get("response_type") != "code" {
+		return ar, &oauthError{"unsupported_response_type", "Only the code response type is supported"}
+	}
+	if ar.CodeChallenge == "" || params.Get("code_challenge_method") != "S256" {
+		return ar, &oauthError{"invalid_request", "PKCE with the S256 method is required"}
+	}
+
+	ar.Scopes = auth.ParseScopes(params.Get("scope"))
+	if len(ar.Scopes) == 0 {
+		ar.Scopes = client.Scopes
+	}
+	for _, scope := range ar.Scopes {
+		if !auth.HasScope(client.Scopes, scope) {
+			return ar, &oauthError{"invalid_scope", fmt.Sprintf("The client can't request %q", scope)}
+		}
+	}
+	return ar, nil
+}
+
+// redirectToClient sends the browser back to the client with params added to
+// the redirect URI.
+func redirectToClient(w http.ResponseWriter, req *http.Request, ar authorizationRequest, params url.Values) {
+	if ar.State != "" {
+		params.Set("state", ar.State)
+	}
+	target, err := url.Parse(ar.RedirectURI)
+	if err != nil {
+		renderOAuthError(w, http.StatusInternalServerError, "The client's redirect URI is invalid.")
+		return
+	}
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	target.RawQuery = query.Encode()
+	http.Redirect(w, req, target.String(), http.StatusSeeOther)
+}
+
+func redirectWithOAuthError(w http.ResponseWriter, req *http.Request, ar authorizationRequest, oauthErr *oauthError) {
+	redirectToClient(w, req, ar, url.Values{
+		"error":             {oauthErr.Code},
+		"error_description": {oauthErr.Description},
+	})
+}
+
+var consentTemplate = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Authorize {{.ClientName}}</title></head>
+<body>
+{{if .ClientName}}
+<h1>{{.ClientName}} wants to access your Chirpy account</h1>
+{{end}}
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+{{if .Scopes}}
+<p>It will be able to:</p>
+<ul>
+{{range .Scopes}}<li>{{.}}</li>
+{{end}}</ul>
+<form method="post" action="/oauth/authorize">
+{{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}<label>Email <input type="email" name="email" value="{{.Email}}" required></label>
+<label>Password <input type="password" name="password" required></label>
+<label>Authenticator code, if enabled <input type="text" name="code" inputmode="numeric" autocomplete="one-time-code"></label>
+<button type="submit" name="decision" value="allow">Allow</button>
+<button type="submit" name="decision" value="deny" formnovalidate>Deny</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+type consentPage struct {
+	ClientName string
+	Scopes     []string
+	Params     map[string]string
+	Email      string
+	Error      string
+}
+
+func writeConsentPage(w http.ResponseWriter, code int, page consentPage) {
+	// The page takes a password, so it must not be framed by another site.
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.WriteHeader(code)
+	if err := consentTemplate.Execute(w, page); err != nil {
+		log.Printf("Couldn't render the consent page: %v\n", err)
+	}
+}
+
+func renderOAuthError(w http.ResponseWriter, code int, message string) {
+	writeConsentPage(w, code, consentPage{Error: message})
+}
+
+func renderConsent(w http.ResponseWriter, code int, ar authorizationRequest, email, message string) {
+	scopes := make([]string, 0, len(ar.Scopes))
+	for _, scope := range ar.Scopes {
+		scopes = append(scopes, oauthScopeDescriptions[scope])
+	}
+	writeConsentPage(w, code, consentPage{
+		ClientName: ar.Client.Name,
+		Scopes:     scopes,
+		Params: map[string]string{
+			"client_id":             ar.Client.ID,
+			"redirect_uri":          ar.RedirectURI,
+			"response_type":         "code",
+			"scope":                 strings.Join(ar.Scopes, " "),
+			"state":                 ar.State,
+			"code_challenge":        ar.CodeChallenge,
+			"code_challenge_method": "S256",
+		},
+		Email: email,
+		Error: message,
+	})
+}
+
+// authorizationRequestOrError parses the authorization request in params. If
+// it is invalid, it either shows an error page or redirects the error back to
+// the client, and returns false.
+func (cfg *apiConfig) authorizationRequestOrError(w http.ResponseWriter, req *http.Request, params url.Values) (authorizationRequest, bool) {
+	ar, err := cfg.parseAuthorizationRequest(req.Context(), params)
+	if err == nil {
+		return ar, true
+	}
+	var oauthErr *oauthError
+	if !errors.As(err, &oauthErr) {
+		renderOAuthError(w, http.StatusInternalServerError, "Something went wrong, try again later.")
+		return authorizationRequest{}, false
+	}
+	if ar.RedirectURI == "" {
+		renderOAuthError(w, http.StatusBadRequest, oauthErr.Description+".")
+		return authorizationRequest{}, false
+	}
+	redirectWithOAuthError(w, req, ar, oauthErr)
+	return authorizationRequest{}, false
+}
+
+// StartOAuthAuthorization shows the consent screen of the authorization code
+// flow (RFC 6749, section 4.1). Clients must use PKCE.
+func (cfg *apiConfig) StartOAuthAuthorization(w http.ResponseWriter, req *http.Request) {
+	ar, ok := cfg.authorizationRequestOrError(w, req, req.URL.Query())
+	if !ok {
+		return
+	}
+	renderConsent(w, http.StatusOK, ar, "", "")
+}
+
+// CompleteOAuthAuthorization handles the consent form. The user signs in on
+// the form itself, so the client never sees the password, and allowing sends
+// the browser back to the client with a single-use authorization code.
+func (cfg *apiConfig) CompleteOAuthAuthorization(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		renderOAuthError(w, http.StatusBadRequest, "The form couldn't be read.")
+		return
+	}
+	ar, ok := cfg.authorizationRequestOrError(w, req, req.PostForm)
+	if !ok {
+		return
+	}
+
+	if req.PostForm.Get("decision") != "allow" {
+		redirectWithOAuthError(w, req, ar, &oauthError{"access_denied", "The user denied the request"})
+		return
+	}
+
+	email := req.PostForm.Get("email")
+	password := req.PostForm.Get("password")
+	ip := clientIP(req)
+	wait, err := cfg.loginThrottle.take(req.Context(), email, ip)
+	if err != nil {
+		renderConsent(w, http.StatusInternalServerError, ar, email, "Something went wrong, try again later.")
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		renderConsent(w, http.StatusTooManyRequests, ar, email, "Too many failed login attempts, try again later.")
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByEmail(req.Context(), email)
+	if err != nil {
+		cfg.passwordHasher.Verify(cfg.dummyPasswordHash, password)
+		cfg.loginThrottle.fail(req.Context(), email, ip)
+		cfg.metrics.countLogin("oauth_consent", false)
+		renderConsent(w, http.StatusUnauthorized, ar, email, "Incorrect email or password.")
+		return
+	}
+	needsRehash, err := cfg.passwordHasher.Verify(user.HashedPassword, password)
+	if err != nil {
+		cfg.loginThrottle.fail(req.Context(), email, ip)
+		cfg.metrics.countLogin("oauth_consent", false)
+		renderConsent(w, http.StatusUnauthorized, ar, email, "Incorrect email or password.")
+		return
+	}
+	if cfg.unverifiedRestrictions.blocks(actionLogin, user) {
+		renderConsent(w, http.StatusForbidden, ar, email, "Verify your email address before authorizing apps.")
+		return
+	}
+	suspension, suspended, err := cfg.activeSuspension(req.Context(), user.ID)
+	if err != nil {
+		renderConsent(w, http.StatusInternalServerError, ar, email, "Something went wrong, try again later.")
+		return
+	}
+	if suspended {
+		renderConsent(w, http.StatusForbidden, ar, email, suspensionMessage(suspension))
+		return
+	}
+
+	verified, err := cfg.verifyTOTPIfEnabled(req.Context(), user.ID, req.PostForm.Get("code"))
+	if err != nil {
+		renderConsent(w, http.StatusInternalServerError, ar, email, "Something went wrong, try again later.")
+		return
+	}
+	if !verified {
+		cfg.loginThrottle.fail(req.Context(), email, ip)
+		cfg.metrics.countLogin("oauth_consent", false)
+		renderConsent(w, http.StatusUnauthorized, ar, email, "Enter the current code from your authenticator app.")
+		return
+	}
+
+	cfg.loginThrottle.passed(req.Context(), ip)
+	cfg.loginThrottle.succeed(req.Context(), email)
+	cfg.metrics.countLogin("oauth_consent", true)
+	if needsRehash {
+		cfg.rehashPassword(req.Context(), user.ID, password)
+	}
+
+	code, err := auth.MakeRefreshToken()
+	if err != nil {
+		renderConsent(w, http.StatusInternalServerError, ar, email, "Something went wrong, try again later.")
+		return
+	}
+	err = cfg.dbQueries.CreateOAuthAuthorizationCode(req.Context(), database.CreateOAuthAuthorizationCodeParams{
+		CodeHash:      auth.HashToken(code),
+		CreatedAt:     time.Now(),
+		ClientID:      ar.Client.ID,
+		UserID:        user.ID,
+		RedirectUri:   ar.RedirectURI,
+		Scopes:        ar.Scopes,
+		CodeChallenge: ar.CodeChallenge,
+		FamilyID:      uuid.New(),
+		ExpiresAt:     time.Now().Add(oauthCodeTTL),
+	})
+	if err != nil {
+		renderConsent(w, http.StatusInternalServerError, ar, email, "Something went wrong, try again later.")
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(user.ID),
+		Action:     "oauth_client.authorized",
+		TargetType: "oauth_client",
+		TargetID:   ar.Client.ID,
+		Details:    map[string]any{"scopes": ar.Scopes},
+	})
+	redirectToClient(w, req, ar, url.Values{"code": {code}})
+}
+
+// verifyTOTPIfEnabled checks code against the user's authenticator, if they
+// have one. Users without two-factor authentication always pass.
+func (cfg *apiConfig) verifyTOTPIfEnabled(ctx context.Context, userID uuid.UUID, code string) (bool, error) {
+	totp, err := cfg.dbQueries.GetTOTPByUserID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !totp.ConfirmedAt.Valid {
+		return true, nil
+	}
+
+	step, ok := auth.ValidateTOTPCode(totp.Secret, code, time.Now(), totp.LastUsedStep)
+	if !ok {
+		return false, nil
+	}
+	advanced, err := cfg.dbQueries.SetTOTPLastUsedStep(ctx, database.SetTOTPLastUsedStepParams{
+		LastUsedStep: step,
+		UserID:       userID,
+	})
+	if err != nil {
+		return false, err
+	}
+	return advanced == 1, nil
+}
+
+var errInvalidOAuthClient = errors.New("invalid client credentials")
+
+// authenticateOAuthClient identifies the client calling the token,
+// revocation or introspection endpoint, by HTTP basic authentication or the
+// client_id and client_secret form fields. Public clients only send their ID.
+func (cfg *apiConfig) authenticateOAuthClient(req *http.Request) (database.OauthClient, error) {
+	clientID, secret, ok := req.BasicAuth()
+	if ok {
+		// RFC 6749 form-encodes both before they go into the header.
+		var err error
+		if clientID, err = url.QueryUnescape(clientID); err != nil {
+			return database.OauthClient{}, errInvalidOAuthClient
+		}
+		if secret, err = url.QueryUnescape(secret); err != nil {
+			return database.OauthClient{}, errInvalidOAuthClient
+		}
+	} else {
+		clientID = req.PostFormValue("client_id")
+		secret = req.PostFormValue("client_secret")
+	}
+
+	client, err := cfg.dbQueries.GetOAuthClient(req.Context(), clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.OauthClient{}, errInvalidOAuthClient
+	}
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+
+	if !client.SecretHash.Valid {
+		if secret != "" {
+			return database.OauthClient{}, errInvalidOAuthClient
+		}
+		return client, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(auth.HashToken(secret)), []byte(client.SecretHash.String)) != 1 {
+		return database.OauthClient{}, errInvalidOAuthClient
+	}
+	return client, nil
+}
+
+// oauthClientOrError authenticates the calling client, writing the error
+// response itself on failure.
+func (cfg *apiConfig) oauthClientOrError(w http.ResponseWriter, req *http.Request) (database.OauthClient, bool) {
+	if err := req.ParseForm(); err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "The request body couldn't be read")
+		return database.OauthClient{}, false
+	}
+	client, err := cfg.authenticateOAuthClient(req)
+	if errors.Is(err, errInvalidOAuthClient) {
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return database.OauthClient{}, false
+	}
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return database.OauthClient{}, false
+	}
+	return client, true
+}
+
+// oauthClaims are the claims of an access token issued to client for the
+// grant whose refresh token family is familyID.
+func oauthClaims(user database.User, client database.OauthClient, familyID uuid.UUID, scopes []string) auth.Claims {
+	return auth.Claims{
+		UserID:      user.ID,
+		SessionID:   familyID,
+		ClientID:    client.ID,
+		Scopes:      scopes,
+		IsChirpyRed: user.IsChirpyRed,
+	}
+}
+
+// OAuthToken is the token endpoint. It supports the authorization_code grant,
+// with PKCE, and the refresh_token grant. Refresh tokens rotate exactly like
+// first-party ones, but only for the client they were issued to.
+func (cfg *apiConfig) OAuthToken(w http.ResponseWriter, req *http.Request) {
+	type ResponseJson struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+		Scope        string `json:"scope"`
+	}
+
+	client, ok := cfg.oauthClientOrError(w, req)
+	if !ok {
+		return
+	}
+
+	var user database.User
+	var familyID uuid.UUID
+	var scopes []string
+	var refreshToken string
+	switch req.PostForm.Get("grant_type") {
+	case "authorization_code":
+		code, err := cfg.redeemAuthorizationCode(req, client)
+		var oauthErr *oauthError
+		if errors.As(err, &oauthErr) {
+			respondWithOAuthError(w, http.StatusBadRequest, oauthErr.Code, oauthErr.Description)
+			return
+		}
+		if err != nil {
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+		familyID, scopes = code.FamilyID, code.Scopes
+
+		tx, err := cfg.db.BeginTx(req.Context(), nil)
+		if err != nil {
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+		defer tx.Rollback()
+		qtx := cfg.queriesWithTx(tx)
+
+		used, err := qtx.UseOAuthAuthorizationCode(req.Context(), database.UseOAuthAuthorizationCodeParams{
+			UsedAt:   sql.NullTime{Time: time.Now(), Valid: true},
+			CodeHash: code.CodeHash,
+		})
+		if err != nil {
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+		if used == 0 {
+			tx.Rollback()
+			cfg.revokeAuthorizationCodeGrant(req, code)
+			respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "The authorization code was already used")
+			return
+		}
+
+		sessionClient := newSessionClient(req, client.Name)
+		sessionClient.OAuthClientID = client.ID
+		sessionClient.Scopes = code.Scopes
+		refreshToken, err = issueRefreshToken(req.Context(), qtx, code.UserID, code.FamilyID, sessionClient)
+		if err != nil {
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+
+		user, err = cfg.dbQueries.GetUserByID(req.Context(), code.UserID)
+		if err != nil {
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+
+	case "refresh_token":
+		// A narrower scope parameter isn't supported; refreshed tokens keep
+		// the scopes the user consented to.
+		stored, rotated, err := cfg.rotateRefreshToken(req, req.PostForm.Get("refresh_token"), client.ID)
+		if errors.Is(err, errInvalidRefreshToken) {
+			respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "The refresh token is invalid or expired")
+			return
+		}
+		if err != nil {
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+		familyID, scopes, refreshToken = stored.FamilyID, stored.Scopes, rotated
+
+		user, err = cfg.dbQueries.GetUserByID(req.Context(), stored.UserID)
+		if err != nil {
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+
+	default:
+		respondWithOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Use authorization_code or refresh_token")
+		return
+	}
+
+	accessToken, err := cfg.keyring.MakeJWT(oauthClaims(user, client, familyID, scopes), oauthAccessTokenTTL)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, ResponseJson{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(oauthAccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(scopes, " "),
+	})
+}
+
+// redeemAuthorizationCode checks the code in an authorization_code grant
+// against the client, redirect URI and PKCE verifier. It doesn't mark the
+// code as used.
+func (cfg *apiConfig) redeemAuthorizationCode(req *http.Request, client database.OauthClient) (database.OauthAuthorizationCode, error) {
+	invalidGrant := &oauthError{"invalid_grant", "The authorization code is invalid or expired"}
+
+	code, err := cfg.dbQueries.GetOAuthAuthorizationCode(req.Context(), auth.HashToken(req.PostForm.Get("code")))
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.OauthAuthorizationCode{}, invalidGrant
+	}
+	if err != nil {
+		return database.OauthAuthorizationCode{}, err
+	}
+	if code.ClientID != client.ID {
+		return database.OauthAuthorizationCode{}, invalidGrant
+	}
+	if code.UsedAt.Valid {
+		// RFC 6749 section 4.1.2: a code used twice was intercepted, so
+		// whatever was issued for it can't be trusted either.
+		cfg.revokeAuthorizationCodeGrant(req, code)
+		return database.OauthAuthorizationCode{}, invalidGrant
+	}
+	if !time.Now().Before(code.ExpiresAt) {
+		return database.OauthAuthorizationCode{}, invalidGrant
+	}
+	if req.PostForm.Get("redirect_uri") != code.RedirectUri {
+		return database.OauthAuthorizationCode{}, &oauthError{"invalid_grant", "The redirect URI doesn't match the authorization request"}
+	}
+	if !auth.VerifyPKCE(req.PostForm.Get("code_verifier"), code.CodeChallenge) {
+		return database.OauthAuthorizationCode{}, &oauthError{"invalid_grant", "The code verifier doesn't match the code challenge"}
+	}
+	return code, nil
+}
+
+// revokeAuthorizationCodeGrant revokes the refresh tokens issued for a code
+// that was presented again.
+func (cfg *apiConfig) revokeAuthorizationCodeGrant(req *http.Request, code database.OauthAuthorizationCode) {
+	err := cfg.dbQueries.RevokeRefreshTokenFamily(req.Context(), database.RevokeRefreshTokenFamilyParams{
+		RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		FamilyID:  code.FamilyID,
+	})
+	if err != nil {
+		log.Printf("Couldn't revoke refresh token family %s: %v\n", code.FamilyID, err)
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		Action:     "oauth_code.reuse_detected",
+		TargetType: "oauth_client",
+		TargetID:   code.ClientID,
+		Details:    map[string]string{"user_id": code.UserID.String(), "family_id": code.FamilyID.String()},
+	})
+}
+
+// grantForToken finds the refresh token family a token presented by client
+// belongs to, whether it is a refresh token or an access token. Tokens issued
+// to other clients are never found.
+func (cfg *apiConfig) grantForToken(ctx context.Context, client database.OauthClient, token string) (database.RefreshToken, *auth.Claims, error) {
+	stored, err := cfg.dbQueries.GetRefreshTokenByToken(ctx, auth.HashToken(token))
+	if err == nil {
+		if stored.ClientID.String != client.ID {
+			return database.RefreshToken{}, nil, errInvalidRefreshToken
+		}
+		return stored, nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.RefreshToken{}, nil, err
+	}
+
+	claims, err := cfg.keyring.ValidateJWT(token)
+	if err != nil || claims.ClientID != client.ID {
+		return database.RefreshToken{}, nil, errInvalidRefreshToken
+	}
+	return database.RefreshToken{}, &claims, nil
+}
+
+// RevokeOAuthToken is the revocation endpoint (RFC 7009). Revoking either
+// kind of token ends the whole grant, so the client's refresh tokens stop
+// working; access tokens already issued run until they expire. Unknown tokens
+// aren't an error.
+func (cfg *apiConfig) RevokeOAuthToken(w http.ResponseWriter, req *http.Request) {
+	client, ok := cfg.oauthClientOrError(w, req)
+	if !ok {
+		return
+	}
+
+	stored, claims, err := cfg.grantForToken(req.Context(), client, req.PostForm.Get("token"))
+	if errors.Is(err, errInvalidRefreshToken) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if err != nil {
+		respondWithOAuthError(w, http.StatusServiceUnavailable, "server_error", "")
+		return
+	}
+
+	familyID, userID := stored.FamilyID, stored.UserID
+	if claims != nil {
+		familyID, userID = claims.SessionID, claims.UserID
+	}
+	err = cfg.dbQueries.RevokeRefreshTokenFamily(req.Context(), database.RevokeRefreshTokenFamilyParams{
+		RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		FamilyID:  familyID,
+	})
+	if err != nil {
+		respondWithOAuthError(w, http.StatusServiceUnavailable, "server_error", "")
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		Action:     "oauth_token.revoked",
+		TargetType: "oauth_client",
+		TargetID:   client.ID,
+		Details:    map[string]string{"user_id": userID.String(), "family_id": familyID.String()},
+	})
+	w.WriteHeader(http.StatusOK)
+}
+
+// IntrospectOAuthToken is the introspection endpoint (RFC 7662). A client
+// can only introspect tokens issued to itself; anything else is reported as
+// inactive.
+func (cfg *apiConfig) IntrospectOAuthToken(w http.ResponseWriter, req *http.Request) {
+	type ResponseJson struct {
+		Active    bool   `json:"active"`
+		Scope     string `json:"scope,omitempty"`
+		ClientID  string `json:"client_id,omitempty"`
+		Subject   string `json:"sub,omitempty"`
+		TokenType string `json:"token_type,omitempty"`
+		ExpiresAt int64  `json:"exp,omitempty"`
+		IssuedAt  int64  `json:"iat,omitempty"`
+	}
+
+	client, ok := cfg.oauthClientOrError(w, req)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	stored, claims, err := cfg.grantForToken(req.Context(), client, req.PostForm.Get("token"))
+	if errors.Is(err, errInvalidRefreshToken) {
+		respondWithJSON(w, http.StatusOK, ResponseJson{Active: false})
+		return
+	}
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	if claims != nil {
+		if err := cfg.checkNotSuspended(req.Context(), claims.UserID); errors.Is(err, errAccountSuspended) {
+			respondWithJSON(w, http.StatusOK, ResponseJson{Active: false})
+			return
+		} else if err != nil {
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+		respondWithJSON(w, http.StatusOK, ResponseJson{
+			Active:    true,
+			Scope:     strings.Join(claims.Scopes, " "),
+			ClientID:  claims.ClientID,
+			Subject:   claims.UserID.String(),
+			TokenType: "access_token",
+			ExpiresAt: claims.ExpiresAt.Unix(),
+		})
+		return
+	}
+
+	if stored.RevokedAt.Valid || stored.RotatedAt.Valid || !time.Now().Before(stored.ExpiresAt) {
+		respondWithJSON(w, http.StatusOK, ResponseJson{Active: false})
+		return
+	}
+	respondWithJSON(w, http.StatusOK, ResponseJson{
+		Active:    true,
+		Scope:     strings.Join(stored.Scopes, " "),
+		ClientID:  client.ID,
+		Subject:   stored.UserID.String(),
+		TokenType: "refresh_token",
+		ExpiresAt: stored.ExpiresAt.Unix(),
+		IssuedAt:  stored.CreatedAt.Unix(),
+	})
+}
+
+func (cfg *apiConfig) purgeExpiredOAuthCodes(ctx context.Context) error {
+	return cfg.dbQueries.DeleteExpiredOAuthAuthorizationCodes(ctx, time.Now().Add(-oauthCodeTTL))
+}
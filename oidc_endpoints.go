@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/SergioFloresCorrea/Chirpy/internal/oidc"
+	"github.com/google/uuid"
+)
+
+const oidcLoginStateTTL = 10 * time.Minute
+
+// newOIDCProviders sets up a client for every provider named in the comma
+// separated OIDC_PROVIDERS. A provider called "acme" is configured through
+// OIDC_ACME_ISSUER, OIDC_ACME_CLIENT_ID, OIDC_ACME_CLIENT_SECRET and
+// optionally OIDC_ACME_SCOPES, which defaults to "email profile".
+func newOIDCProviders(baseURL string) (map[string]*oidc.Client, error) {
+	providers := make(map[string]*oidc.Client)
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		config := oidc.Config{
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  baseURL + "/api/oidc/" + name + "/callback",
+			Scopes:       []string{"email", "profile"},
+		}
+		if config.Issuer == "" || config.ClientID == "" {
+			return nil, fmt.Errorf("OIDC provider %s needs %sISSUER and %sCLIENT_ID", name, prefix, prefix)
+		}
+		if scopes, ok := os.LookupEnv(prefix + "SCOPES"); ok {
+			config.Scopes = strings.Fields(scopes)
+		}
+		providers[name] = oidc.NewClient(config, nil)
+	}
+	return providers, nil
+}
+
+// StartOIDCLogin sends the browser to the provider's sign in page. The state,
+// nonce and PKCE verifier are kept server side until the provider redirects
+// back to OIDCCallback; a cookie ties the state to this browser.
+func (cfg *apiConfig) StartOIDCLogin(w http.ResponseWriter, req *http.Request) {
+	providerName := req.PathValue("provider")
+	provider, ok := cfg.oidcProviders[providerName]
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "Unknown identity provider")
+		return
+	}
+
+	var values [3]string
+	for i := range values {
+		value, err := oidc.NewRandomString()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+			return
+		}
+		values[i] = value
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	authURL, err := provider.AuthCodeURL(req.Context(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("OIDC provider %s is unavailable: %v\n", providerName, err)
+		respondWithError(w, http.StatusBadGateway, "The identity provider is unavailable")
+		return
+	}
+
+	err = cfg.dbQueries.CreateOIDCLoginState(req.Context(), database.CreateOIDCLoginStateParams{
+		State:        state,
+		CreatedAt:    time.Now(),
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		DeviceName:   req.URL.Query().Get("device_name"),
+		ExpiresAt:    time.Now().Add(oidcLoginStateTTL),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	http.SetCookie(w, oidc.StateCookie(state, cfg.oidcCookiePath(providerName), cfg.secureCookies(), oidcLoginStateTTL))
+	http.Redirect(w, req, authURL, http.StatusFound)
+}
+
+// OIDCCallback completes a provider sign in. The external identity is
+// matched to a user by provider and subject, or else linked by verified
+// email to an existing user or a new one. The response is the same as for
+// LoginUser.
+func (cfg *apiConfig) OIDCCallback(w http.ResponseWriter, req *http.Request) {
+	providerName := req.PathValue("provider")
+	provider, ok := cfg.oidcProviders[providerName]
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "Unknown identity provider")
+		return
+	}
+
+	query := req.URL.Query()
+	if providerError := query.Get("error"); providerError != "" {
+		respondWithError(w, http.StatusBadRequest, "The identity provider refused the login: "+providerError)
+		return
+	}
+
+	http.SetCookie(w, oidc.ClearStateCookie(cfg.oidcCookiePath(providerName), cfg.secureCookies()))
+	if err := oidc.CheckState(req, query.Get("state")); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired login state")
+		return
+	}
+
+	loginState, err := cfg.dbQueries.ConsumeOIDCLoginState(req.Context(), query.Get("state"))
+	if err != nil || loginState.Provider != providerName || !time.Now().Before(loginState.ExpiresAt) {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired login state")
+		return
+	}
+
+	idToken, err := provider.Exchange(req.Context(), query.Get("code"), loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		log.Printf("OIDC login with %s failed: %v\n", providerName, err)
+		cfg.metrics.countLogin("oidc", false)
+		respondWithError(w, http.StatusUnauthorized, "Couldn't verify the identity provider's response")
+		return
+	}
+
+	user, err := cfg.userForIdentity(req, providerName, idToken)
+	if errors.Is(err, oidc.ErrUnverifiedEmail) {
+		respondWithError(w, http.StatusForbidden, "The identity provider hasn't verified your email address")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	cfg.metrics.countLogin("oidc", true)
+
+	cfg.continueLogin(w, req, user, loginState.DeviceName)
+}
+
+// oidcCookiePath scopes the state cookie to one provider's login routes.
+func (cfg *apiConfig) oidcCookiePath(providerName string) string {
+	return "/api/oidc/" + providerName + "/"
+}
+
+// secureCookies reports whether cookies should only travel over HTTPS.
+func (cfg *apiConfig) secureCookies() bool {
+	return strings.HasPrefix(cfg.baseURL, "https://")
+}
+
+// userForIdentity returns the user an external identity belongs to, linking
+// it first if it is new. Linking relies on the provider having verified the
+// email address, which also counts as verifying it for Chirpy. Linking to an
+// account whose address was never verified resets its credentials first.
+func (cfg *apiConfig) userForIdentity(req *http.Request, providerName string, idToken oidc.IDToken) (database.User, error) {
+	// Two first logins with the same identity race to link it. The loser
+	// trips a unique constraint, and finds the winner's link when it looks
+	// again.
+	user, err := cfg.findOrLinkIdentity(req, providerName, idToken)
+	if isUniqueViolation(err) {
+		user, err = cfg.findOrLinkIdentity(req, providerName, idToken)
+	}
+	return user, err
+}
+
+func (cfg *apiConfig) findOrLinkIdentity(req *http.Request, providerName string, idToken oidc.IDToken) (database.User, error) {
+	ctx := req.Context()
+	identity, err := cfg.dbQueries.GetUserIdentity(ctx, database.GetUserIdentityParams{
+		Provider: providerName,
+		Subject:  idToken.Subject,
+	})
+	if err == nil {
+		return cfg.dbQueries.GetUserByID(ctx, identity.UserID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	email, err := oidc.LinkEmail(idToken)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.User{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	user, err := qtx.GetUserByEmailIgnoringCase(ctx, email)
+	created := errors.Is(err, sql.ErrNoRows)
+	if created {
+		user, err = cfg.createUserForIdentity(ctx, qtx, email)
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+
+	// An account nobody verified may have been opened by someone else with
+	// this address, waiting for its owner to sign in. Shut them out before
+	// the owner takes it over.
+	squatted := !created && !user.EmailVerifiedAt.Valid
+	if squatted {
+		if err := cfg.resetCredentials(ctx, qtx, user.ID); err != nil {
+			return database.User{}, err
+		}
+	}
+	if !user.EmailVerifiedAt.Valid {
+		user, err = qtx.SetUserEmailVerified(ctx, database.SetUserEmailVerifiedParams{
+			Email:           user.Email,
+			EmailVerifiedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			ID:              user.ID,
+		})
+		if err != nil {
+			return database.User{}, err
+		}
+	}
+
+	_, err = qtx.CreateUserIdentity(ctx, database.CreateUserIdentityParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UserID:    user.ID,
+		Provider:  providerName,
+		Subject:   idToken.Subject,
+		Email:     idToken.Email,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return database.User{}, err
+	}
+
+	cfg.recordAudit(ctx, req, auditEntry{
+		ActorID:    actor(user.ID),
+		Action:     "user.identity_linked",
+		TargetType: "user",
+		TargetID:   user.ID.String(),
+		Details:    map[string]any{"provider": providerName, "new_user": created, "credentials_reset": squatted},
+	})
+	return user, nil
+}
+
+// createUserForIdentity creates a user who signs in through a provider. Their
+// password is random and unknown to anyone; they can set one through the
+// password reset flow.
+func (cfg *apiConfig) createUserForIdentity(ctx context.Context, queries *database.Queries, email string) (database.User, error) {
+	password, err := oidc.NewRandomString()
+	if err != nil {
+		return database.User{}, err
+	}
+	hashedPassword, err := cfg.passwordHasher.Hash(password)
+	if err != nil {
+		return database.User{}, err
+	}
+	return queries.CreateUser(ctx, database.CreateUserParams{
+		ID:             uuid.New(),
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+		Email:          email,
+		HashedPassword: hashedPassword,
+	})
+}
+
+// resetCredentials replaces a user's password with a random one and removes
+// every other way in: sessions, personal access tokens, passkeys and two-factor
+// authentication. qtx should be bound to a transaction.
+func (cfg *apiConfig) resetCredentials(ctx context.Context, qtx *database.Queries, userID uuid.UUID) error {
+	password, err := oidc.NewRandomString()
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := cfg.passwordHasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	err = qtx.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		HashedPassword: hashedPassword,
+		UpdatedAt:      now,
+		ID:             userID,
+	})
+	if err != nil {
+		return err
+	}
+	err = qtx.RevokeAllRefreshTokensForUser(ctx, database.RevokeAllRefreshTokensForUserParams{
+		RevokedAt: sql.NullTime{Time: now, Valid: true},
+		UserID:    userID,
+	})
+	if err != nil {
+		return err
+	}
+	err = qtx.RevokeAllPersonalAccessTokensForUser(ctx, database.RevokeAllPersonalAccessTokensForUserParams{
+		RevokedAt: sql.NullTime{Time: now, Valid: true},
+		UserID:    userID,
+	})
+	if err != nil {
+		return err
+	}
+	if err := qtx.DeleteWebAuthnCredentialsForUser(ctx, userID); err != nil {
+		return err
+	}
+	if _, err := qtx.DeleteTOTP(ctx, userID); err != nil {
+		return err
+	}
+	return qtx.DeleteRecoveryCodesForUser(ctx, userID)
+}
+
+func (cfg *apiConfig) purgeExpiredOIDCLoginStates(ctx context.Context) error {
+	return cfg.dbQueries.DeleteExpiredOIDCLoginStates(ctx, time.Now())
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	auth "github.com/SergioFloresCorrea/Chirpy/internal"
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/SergioFloresCorrea/Chirpy/internal/throttle"
+	"github.com/google/uuid"
+)
+
+const passwordResetTTL = time.Hour
+
+// Reset emails are limited per address, so nobody can flood an inbox, and per
+// client IP, so nobody can flood many. Every request counts, sent or not.
+var (
+	resetEmailPolicy = throttle.Policy{
+		FreeAttempts: 3,
+		BaseDelay:    time.Minute,
+		MaxDelay:     time.Hour,
+		Window:       time.Hour,
+	}
+	resetIPPolicy = throttle.Policy{
+		FreeAttempts: 20,
+		BaseDelay:    time.Minute,
+		MaxDelay:     time.Hour,
+		Window:       time.Hour,
+	}
+)
+
+// newPasswordResetThrottle keeps its counters in the login throttle's store,
+// under keys of its own.
+func newPasswordResetThrottle(store throttle.Store) *loginThrottle {
+	return &loginThrottle{
+		store:   store,
+		account: throttle.NewLimiter(store, "reset-email:", resetEmailPolicy),
+		ip:      throttle.NewLimiter(store, "reset-ip:", resetIPPolicy),
+	}
+}
+
+// ForgotPassword answers 202 whether or not the email belongs to an account.
+// The token is issued and mailed in the background so the response time
+// doesn't give the answer away either.
+func (cfg *apiConfig) ForgotPassword(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	// Limited whether or not the address has an account, so the limit gives
+	// nothing away either.
+	wait, err := cfg.passwordResetThrottle.take(req.Context(), expectedJson.Email, clientIP(req))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		respondWithError(w, http.StatusTooManyRequests, "Too many password reset requests, try again later")
+		return
+	}
+
+	go cfg.sendPasswordResetEmail(context.WithoutCancel(req.Context()), expectedJson.Email)
+
+	respondWithJSON(w, http.StatusAccepted, map[string]string{"status": "If the address belongs to an account, a password reset email is on its way."})
+}
+
+func (cfg *apiConfig) sendPasswordResetEmail(ctx context.Context, email string) {
+	user, err := cfg.dbQueries.GetUserByEmail(ctx, email)
+	if err != nil {
+		return
+	}
+
+	resetToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("Couldn't generate a password reset token: %v\n", err)
+		return
+	}
+
+	params := database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashToken(resetToken),
+		CreatedAt: time.Now(),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	}
+	if _, err := cfg.dbQueries.CreatePasswordResetToken(ctx, params); err != nil {
+		log.Printf("Couldn't store a password reset token: %v\n", err)
+		return
+	}
+
+	body := fmt.Sprintf(`Someone asked to reset the password of your Chirpy account. If it was you, send this token and your new password to %s/api/password/reset:
+
+%s
+
+The token expires in %s and can only be used once. If you didn't ask for a reset, you can ignore this email.`, cfg.baseURL, resetToken, passwordResetTTL)
+	cfg.sendMail(ctx, user.Email, "Reset your Chirpy password", body)
+}
+
+// ResetPassword sets a new password from an emailed reset token, spends every
+// other reset token of the user and revokes their refresh tokens, logging out
+// all their sessions.
+func (cfg *apiConfig) ResetPassword(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	tokenHash := auth.HashToken(expectedJson.Token)
+	resetToken, err := cfg.dbQueries.GetPasswordResetToken(req.Context(), tokenHash)
+	if err != nil || resetToken.UsedAt.Valid || !time.Now().Before(resetToken.ExpiresAt) {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), resetToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+
+	if !cfg.checkPasswordPolicy(w, expectedJson.Password, user.Email) {
+		return
+	}
+
+	hashedPassword, err := cfg.passwordHasher.Hash(expectedJson.Password)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error in hashing password")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	now := sql.NullTime{Time: time.Now(), Valid: true}
+	used, err := qtx.UsePasswordResetToken(req.Context(), database.UsePasswordResetTokenParams{UsedAt: now, TokenHash: tokenHash})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if used == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+	// Other tokens mailed before this one would otherwise still reset the
+	// new password.
+	err = qtx.InvalidatePasswordResetTokens(req.Context(), database.InvalidatePasswordResetTokensParams{UsedAt: now, UserID: resetToken.UserID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := setPasswordAndRevokeSessions(req.Context(), qtx, resetToken.UserID, hashedPassword); err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+func setPasswordAndRevokeSessions(ctx context.Context, queries *database.Queries, userID uuid.UUID, hashedPassword string) error {
+	err := queries.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		HashedPassword: hashedPassword,
+		UpdatedAt:      time.Now(),
+		ID:             userID,
+	})
+	if err != nil {
+		return err
+	}
+	return queries.RevokeAllRefreshTokensForUser(ctx, database.RevokeAllRefreshTokensForUserParams{
+		RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UserID:    userID,
+	})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	auth "github.com/SergioFloresCorrea/Chirpy/internal"
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// newPasswordHasher builds the argon2id hasher, letting
+// PASSWORD_HASH_MEMORY_KIB, PASSWORD_HASH_ITERATIONS and
+// PASSWORD_HASH_PARALLELISM override the default cost. Raising any of them
+// upgrades existing hashes as their users log in.
+func newPasswordHasher() (auth.PasswordHasher, error) {
+	params := auth.DefaultArgon2idParams
+	settings := []struct {
+		env  string
+		bits int
+		set  func(uint64)
+	}{
+		{"PASSWORD_HASH_MEMORY_KIB", 32, func(v uint64) { params.Memory = uint32(v) }},
+		{"PASSWORD_HASH_ITERATIONS", 32, func(v uint64) { params.Iterations = uint32(v) }},
+		{"PASSWORD_HASH_PARALLELISM", 8, func(v uint64) { params.Parallelism = uint8(v) }},
+	}
+	for _, setting := range settings {
+		raw := os.Getenv(setting.env)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseUint(raw, 10, setting.bits)
+		if err != nil || value == 0 {
+			return nil, fmt.Errorf("invalid %s %q", setting.env, raw)
+		}
+		setting.set(value)
+	}
+	return auth.NewArgon2idHasher(params), nil
+}
+
+// rehashPassword replaces a user's stored hash with one made by the current
+// hasher. It runs after a successful login, the only time the password is
+// known; a failure just means trying again on the next login.
+func (cfg *apiConfig) rehashPassword(ctx context.Context, userID uuid.UUID, password string) {
+	hashedPassword, err := cfg.passwordHasher.Hash(password)
+	if err != nil {
+		log.Printf("Couldn't rehash the password of %s: %v\n", userID, err)
+		return
+	}
+	err = cfg.dbQueries.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		HashedPassword: hashedPassword,
+		UpdatedAt:      time.Now(),
+		ID:             userID,
+	})
+	if err != nil {
+		log.Printf("Couldn't store the rehashed password of %s: %v\n", userID, err)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/SergioFloresCorrea/Chirpy/internal/passwordpolicy"
+)
+
+// newPasswordPolicy starts from the default policy and applies
+// PASSWORD_MIN_LENGTH, PASSWORD_MIN_ENTROPY_BITS and BREACHED_PASSWORDS_FILE,
+// a local copy of breached password hashes in Pwned Passwords format.
+func newPasswordPolicy() (passwordpolicy.Policy, error) {
+	policy := passwordpolicy.DefaultPolicy
+	if minLength := os.Getenv("PASSWORD_MIN_LENGTH"); minLength != "" {
+		value, err := strconv.Atoi(minLength)
+		if err != nil || value < 0 {
+			return policy, fmt.Errorf("invalid PASSWORD_MIN_LENGTH %q", minLength)
+		}
+		policy.MinLength = value
+	}
+	if minEntropy := os.Getenv("PASSWORD_MIN_ENTROPY_BITS"); minEntropy != "" {
+		value, err := strconv.ParseFloat(minEntropy, 64)
+		if err != nil || value < 0 {
+			return policy, fmt.Errorf("invalid PASSWORD_MIN_ENTROPY_BITS %q", minEntropy)
+		}
+		policy.MinEntropyBits = value
+	}
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		corpus, err := passwordpolicy.LoadCorpusFile(path)
+		if err != nil {
+			return policy, fmt.Errorf("couldn't load BREACHED_PASSWORDS_FILE: %w", err)
+		}
+		log.Printf("Loaded %d breached password hashes\n", corpus.Len())
+		policy.Breached = corpus
+	}
+	return policy, nil
+}
+
+// checkPasswordPolicy answers 400 with every rule password breaks and
+// returns false, or returns true when the password is acceptable.
+func (cfg *apiConfig) checkPasswordPolicy(w http.ResponseWriter, password, email string) bool {
+	type ResponseJson struct {
+		Error      string                     `json:"error"`
+		Violations []passwordpolicy.Violation `json:"violations"`
+	}
+
+	violations := cfg.passwordPolicy.Check(password, email)
+	if len(violations) == 0 {
+		return true
+	}
+	respondWithJSON(w, http.StatusBadRequest, ResponseJson{
+		Error:      "Password does not meet the requirements",
+		Violations: violations,
+	})
+	return false
+}
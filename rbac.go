@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	auth "github.com/SergioFloresCorrea/Chirpy/internal"
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+)
+
+type principalContextKey struct{}
+
+// principalFromContext returns the caller stored by middlewareRequirePermission.
+func principalFromContext(ctx context.Context) principal {
+	caller, _ := ctx.Value(principalContextKey{}).(principal)
+	return caller
+}
+
+// middlewareRequirePermission only lets through callers whose roles grant
+// permission. Roles come from the access token, but are checked against the
+// database on every request so that a revoked role stops working at once
+// rather than when the token expires.
+func (cfg *apiConfig) middlewareRequirePermission(permission string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		caller, err := cfg.authenticate(req)
+		if errors.Is(err, errAccountSuspended) {
+			respondWithError(w, http.StatusForbidden, "Account suspended")
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		allowed, err := cfg.hasPermission(req.Context(), caller, permission)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't check permissions")
+			return
+		}
+		if !allowed {
+			respondWithError(w, http.StatusForbidden, "Missing the "+permission+" permission")
+			return
+		}
+
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), principalContextKey{}, caller)))
+	})
+}
+
+// hasPermission reports whether the roles caller's token carries, and still
+// holds, grant permission.
+func (cfg *apiConfig) hasPermission(ctx context.Context, caller principal, permission string) (bool, error) {
+	if len(caller.Roles) == 0 {
+		return false, nil
+	}
+	permissions, err := cfg.dbQueries.GetPermissionsForUserRoles(ctx, database.GetPermissionsForUserRolesParams{
+		UserID: caller.UserID,
+		Roles:  caller.Roles,
+	})
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(permissions, permission), nil
+}
+
+// bootstrapAdmin grants the admin role to the user named by
+// BOOTSTRAP_ADMIN_EMAIL, so that a fresh deployment has someone who can hand
+// out roles. It does nothing when the variable is unset.
+func (cfg *apiConfig) bootstrapAdmin(ctx context.Context) {
+	email := os.Getenv("BOOTSTRAP_ADMIN_EMAIL")
+	if email == "" {
+		return
+	}
+	user, err := cfg.dbQueries.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("BOOTSTRAP_ADMIN_EMAIL %s doesn't belong to any user\n", email)
+		return
+	}
+	if err != nil {
+		log.Printf("Couldn't look up the bootstrap admin: %v\n", err)
+		return
+	}
+	granted, err := cfg.dbQueries.GrantRole(ctx, database.GrantRoleParams{
+		UserID:    user.ID,
+		Role:      auth.RoleAdmin,
+		GrantedAt: time.Now(),
+	})
+	if err != nil {
+		log.Printf("Couldn't grant the admin role to %s: %v\n", email, err)
+		return
+	}
+	if granted > 0 {
+		log.Printf("Granted the admin role to %s\n", email)
+	}
+}
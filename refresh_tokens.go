@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	auth "github.com/SergioFloresCorrea/Chirpy/internal"
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const refreshTokenTTL = 60 * 24 * time.Hour
+
+var errInvalidRefreshToken = errors.New("invalid refresh token")
+
+// sessionClient describes the device a refresh token was handed to, so users
+// can tell their sessions apart.
+type sessionClient struct {
+	UserAgent  string
+	IP         string
+	DeviceName string
+	// OAuthClientID and Scopes are only set for tokens issued to a
+	// third-party OAuth client, which is limited to what the user consented
+	// to.
+	OAuthClientID string
+	Scopes        []string
+}
+
+func newSessionClient(req *http.Request, deviceName string) sessionClient {
+	return sessionClient{
+		UserAgent:  req.UserAgent(),
+		IP:         clientIP(req),
+		DeviceName: deviceName,
+	}
+}
+
+// issueRefreshToken stores a new refresh token in familyID and returns it.
+// Logging in starts a new family; every rotation adds a token to it. The
+// family ID doubles as the public session ID, since unlike the token itself
+// it is not a secret and it survives rotation.
+func issueRefreshToken(ctx context.Context, queries *database.Queries, userID, familyID uuid.UUID, client sessionClient) (string, error) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	scopes := client.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	params := database.CreateRefreshTokenParams{
+		TokenHash:  auth.HashToken(refreshToken),
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		UserID:     userID,
+		ExpiresAt:  time.Now().Add(refreshTokenTTL),
+		FamilyID:   familyID,
+		UserAgent:  client.UserAgent,
+		Ip:         client.IP,
+		DeviceName: client.DeviceName,
+		LastUsedAt: time.Now(),
+		ClientID:   sql.NullString{String: client.OAuthClientID, Valid: client.OAuthClientID != ""},
+		Scopes:     scopes,
+	}
+	if _, err := queries.CreateRefreshToken(ctx, params); err != nil {
+		return "", err
+	}
+	return refreshToken, nil
+}
+
+// sessionClaims are the claims of an access token issued for the login whose
+// refresh token family is sessionID. The user's roles are read afresh, so a
+// newly granted role shows up at the next refresh.
+func (cfg *apiConfig) sessionClaims(ctx context.Context, user database.User, sessionID uuid.UUID) (auth.Claims, error) {
+	roles, err := cfg.dbQueries.GetRolesForUser(ctx, user.ID)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+	return auth.Claims{
+		UserID:      user.ID,
+		SessionID:   sessionID,
+		Scopes:      auth.SessionScopes,
+		Roles:       roles,
+		IsChirpyRed: user.IsChirpyRed,
+	}, nil
+}
+
+// revokeRefreshTokenFamily is the response to a rotated refresh token being
+// replayed: every token descended from the same login stops working.
+func (cfg *apiConfig) revokeRefreshTokenFamily(req *http.Request, refreshToken database.RefreshToken) {
+	err := cfg.dbQueries.RevokeRefreshTokenFamily(req.Context(), database.RevokeRefreshTokenFamilyParams{
+		RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		FamilyID:  refreshToken.FamilyID,
+	})
+	if err != nil {
+		log.Printf("Couldn't revoke refresh token family %s: %v\n", refreshToken.FamilyID, err)
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		Action:     "refresh_token.reuse_detected",
+		TargetType: "user",
+		TargetID:   refreshToken.UserID.String(),
+		Details:    map[string]string{"family_id": refreshToken.FamilyID.String()},
+	})
+}
+
+// rotateRefreshToken trades presented for a new refresh token of the same
+// family and returns the stored row of the old one along with the new token.
+// Tokens only rotate for the client they were issued to; clientID is empty
+// for first-party logins. Presenting a token that was already rotated means
+// two parties hold it, so the whole family is revoked.
+func (cfg *apiConfig) rotateRefreshToken(req *http.Request, presented, clientID string) (database.RefreshToken, string, error) {
+	tokenHash := auth.HashToken(presented)
+	stored, err := cfg.dbQueries.GetRefreshTokenByToken(req.Context(), tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.RefreshToken{}, "", errInvalidRefreshToken
+	}
+	if err != nil {
+		return database.RefreshToken{}, "", err
+	}
+	if stored.ClientID.String != clientID {
+		return database.RefreshToken{}, "", errInvalidRefreshToken
+	}
+
+	if stored.RotatedAt.Valid {
+		cfg.revokeRefreshTokenFamily(req, stored)
+		return database.RefreshToken{}, "", errInvalidRefreshToken
+	}
+	if stored.RevokedAt.Valid || stored.ExpiresAt.Before(time.Now()) {
+		return database.RefreshToken{}, "", errInvalidRefreshToken
+	}
+
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		return database.RefreshToken{}, "", err
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	rotated, err := qtx.RotateRefreshToken(req.Context(), database.RotateRefreshTokenParams{
+		RotatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		TokenHash: tokenHash,
+	})
+	if err != nil {
+		return database.RefreshToken{}, "", err
+	}
+	if rotated == 0 {
+		// Another request rotated or revoked the token since we read it.
+		tx.Rollback()
+		cfg.revokeRefreshTokenFamily(req, stored)
+		return database.RefreshToken{}, "", errInvalidRefreshToken
+	}
+
+	client := newSessionClient(req, stored.DeviceName)
+	client.OAuthClientID = clientID
+	client.Scopes = stored.Scopes
+	refreshToken, err := issueRefreshToken(req.Context(), qtx, stored.UserID, stored.FamilyID, client)
+	if err != nil {
+		return database.RefreshToken{}, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return database.RefreshToken{}, "", err
+	}
+	return stored, refreshToken, nil
+}
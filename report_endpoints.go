@@ -0,0 +1,657 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	auth "github.com/SergioFloresCorrea/Chirpy/internal"
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	reportTargetChirp = "chirp"
+	reportTargetUser  = "user"
+
+	reportStatusOpen     = "open"
+	reportStatusClaimed  = "claimed"
+	reportStatusResolved = "resolved"
+
+	resolutionDismiss     = "dismiss"
+	resolutionRemoveChirp = "remove_chirp"
+	resolutionWarn        = "warn"
+	resolutionSuspend     = "suspend"
+
+	maxReportCommentLength = 1000
+	maxReportNoteLength    = 2000
+	defaultReportPageSize  = 50
+	maxReportPageSize      = 100
+)
+
+// A user may file reportLimit reports per reportWindow, which with one report
+// per user and target keeps a single account from flooding the queue.
+const (
+	reportLimit  = 10
+	reportWindow = time.Hour
+)
+
+var reportCategories = []string{"spam", "harassment", "hate", "violence", "sexual", "self_harm", "misinformation", "other"}
+
+type Report struct {
+	ID               uuid.UUID  `json:"id"`
+	TargetType       string     `json:"target_type"`
+	TargetID         uuid.UUID  `json:"target_id"`
+	TargetUserID     uuid.UUID  `json:"target_user_id"`
+	ChirpBody        string     `json:"chirp_body,omitempty"`
+	ReportCount      int32      `json:"report_count"`
+	Status           string     `json:"status"`
+	ClaimedBy        *uuid.UUID `json:"claimed_by"`
+	ClaimedAt        *time.Time `json:"claimed_at"`
+	ResolvedBy       *uuid.UUID `json:"resolved_by"`
+	ResolvedAt       *time.Time `json:"resolved_at"`
+	Resolution       string     `json:"resolution,omitempty"`
+	ResolutionReason string     `json:"resolution_reason,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+func toReport(report database.Report) Report {
+	return Report{
+		ID:               report.ID,
+		TargetType:       report.TargetType,
+		TargetID:         report.TargetID,
+		TargetUserID:     report.TargetUserID,
+		ChirpBody:        report.ChirpBody.String,
+		ReportCount:      report.ReportCount,
+		Status:           report.Status,
+		ClaimedBy:        nullUUIDPtr(report.ClaimedBy),
+		ClaimedAt:        nullTimePtr(report.ClaimedAt),
+		ResolvedBy:       nullUUIDPtr(report.ResolvedBy),
+		ResolvedAt:       nullTimePtr(report.ResolvedAt),
+		Resolution:       report.Resolution.String,
+		ResolutionReason: report.ResolutionReason,
+		CreatedAt:        report.CreatedAt,
+		UpdatedAt:        report.UpdatedAt,
+	}
+}
+
+type ReportNote struct {
+	ID        uuid.UUID  `json:"id"`
+	AuthorID  *uuid.UUID `json:"author_id"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func toReportNote(note database.ReportNote) ReportNote {
+	return ReportNote{
+		ID:        note.ID,
+		AuthorID:  nullUUIDPtr(note.AuthorID),
+		Body:      note.Body,
+		CreatedAt: note.CreatedAt,
+	}
+}
+
+type Warning struct {
+	ID        uuid.UUID  `json:"id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	IssuedBy  *uuid.UUID `json:"issued_by"`
+	ReportID  *uuid.UUID `json:"report_id"`
+	Reason    string     `json:"reason"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// CreateReport files a report about a chirp or a user. Reports about the same
+// target join one open case in the moderation queue, and each user counts
+// once per case, so reporting again changes nothing.
+func (cfg *apiConfig) CreateReport(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		TargetType string `json:"target_type"`
+		TargetID   string `json:"target_id"`
+		Category   string `json:"category"`
+		Comment    string `json:"comment"`
+	}
+	type ResponseJson struct {
+		Status string `json:"status"`
+	}
+
+	caller, ok := cfg.authorize(w, req, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	blocked, err := cfg.blockedUntilVerified(req.Context(), caller.UserID, actionReport)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "Verify your email address before reporting")
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	targetID, err := uuid.Parse(expectedJson.TargetID)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid target ID format")
+		return
+	}
+	if !slices.Contains(reportCategories, expectedJson.Category) {
+		respondWithError(w, http.StatusBadRequest, "category must be one of "+strings.Join(reportCategories, ", "))
+		return
+	}
+	comment := strings.TrimSpace(expectedJson.Comment)
+	if utf8.RuneCountInString(comment) > maxReportCommentLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("The comment can be at most %d characters long", maxReportCommentLength))
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	// Held until the transaction ends, so concurrent reports from one user
+	// can't all pass the limit before any of them is counted.
+	if err := qtx.LockReportSubmissionsForReporter(req.Context(), caller.UserID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	now := time.Now()
+	recent, err := qtx.CountReportSubmissionsSince(req.Context(), database.CountReportSubmissionsSinceParams{
+		ReporterID: caller.UserID,
+		CreatedAt:  now.Add(-reportWindow),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if recent >= reportLimit {
+		w.Header().Set("Retry-After", strconv.Itoa(int(reportWindow.Seconds())))
+		respondWithError(w, http.StatusTooManyRequests, "You have sent too many reports, try again later")
+		return
+	}
+
+	params := database.OpenReportParams{
+		ID:         uuid.New(),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		TargetType: expectedJson.TargetType,
+		TargetID:   targetID,
+	}
+	switch expectedJson.TargetType {
+	case reportTargetChirp:
+		chirp, err := qtx.GetChirpByID(req.Context(), targetID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Chirp not found")
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+			return
+		}
+		// Keep the text, so the evidence survives the chirp being deleted.
+		params.TargetUserID = chirp.UserID
+		params.ChirpBody = sql.NullString{String: chirp.Body, Valid: true}
+	case reportTargetUser:
+		user, err := qtx.GetUserByID(req.Context(), targetID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+			return
+		}
+		params.TargetUserID = user.ID
+	default:
+		respondWithError(w, http.StatusBadRequest, "target_type must be either chirp or user")
+		return
+	}
+	if params.TargetUserID == caller.UserID {
+		respondWithError(w, http.StatusBadRequest, "You can't report yourself")
+		return
+	}
+
+	report, err := qtx.OpenReport(req.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	added, err := qtx.CreateReportSubmission(req.Context(), database.CreateReportSubmissionParams{
+		ReportID:   report.ID,
+		ReporterID: caller.UserID,
+		CreatedAt:  now,
+		Category:   expectedJson.Category,
+		Comment:    comment,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if added == 0 {
+		respondWithJSON(w, http.StatusOK, ResponseJson{Status: "already_reported"})
+		return
+	}
+	err = qtx.IncrementReportCount(req.Context(), database.IncrementReportCountParams{
+		UpdatedAt: now,
+		ID:        report.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, ResponseJson{Status: "received"})
+}
+
+// ListReports is the moderation queue. Cases with the most reports come
+// first.
+func (cfg *apiConfig) ListReports(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	status := query.Get("status")
+	if status == "" {
+		status = reportStatusOpen
+	}
+	if status != reportStatusOpen && status != reportStatusClaimed && status != reportStatusResolved {
+		respondWithError(w, http.StatusBadRequest, "status must be open, claimed or resolved")
+		return
+	}
+	limit := defaultReportPageSize
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxReportPageSize {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxReportPageSize))
+			return
+		}
+		limit = parsed
+	}
+	offset := 0
+	if value := query.Get("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			respondWithError(w, http.StatusBadRequest, "offset must not be negative")
+			return
+		}
+		offset = parsed
+	}
+
+	reports, err := cfg.dbQueries.ListReportsByStatus(req.Context(), database.ListReportsByStatusParams{
+		Status: status,
+		Limit:  int32(limit),
+		Offset: int32(offset),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	responseJson := make([]Report, 0, len(reports))
+	for _, report := range reports {
+		responseJson = append(responseJson, toReport(report))
+	}
+	respondWithJSON(w, http.StatusOK, responseJson)
+}
+
+// GetReport returns a case along with every report filed in it and the
+// moderators' notes.
+func (cfg *apiConfig) GetReport(w http.ResponseWriter, req *http.Request) {
+	type Submission struct {
+		ReporterID uuid.UUID `json:"reporter_id"`
+		Category   string    `json:"category"`
+		Comment    string    `json:"comment"`
+		CreatedAt  time.Time `json:"created_at"`
+	}
+	type ResponseJson struct {
+		Report
+		Submissions []Submission `json:"submissions"`
+		Notes       []ReportNote `json:"notes"`
+	}
+
+	report, ok := cfg.reportOrError(w, req)
+	if !ok {
+		return
+	}
+
+	submissions, err := cfg.dbQueries.ListReportSubmissions(req.Context(), report.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	notes, err := cfg.dbQueries.ListReportNotes(req.Context(), report.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	responseJson := ResponseJson{
+		Report:      toReport(report),
+		Submissions: make([]Submission, 0, len(submissions)),
+		Notes:       make([]ReportNote, 0, len(notes)),
+	}
+	for _, submission := range submissions {
+		responseJson.Submissions = append(responseJson.Submissions, Submission{
+			ReporterID: submission.ReporterID,
+			Category:   submission.Category,
+			Comment:    submission.Comment,
+			CreatedAt:  submission.CreatedAt,
+		})
+	}
+	for _, note := range notes {
+		responseJson.Notes = append(responseJson.Notes, toReportNote(note))
+	}
+	respondWithJSON(w, http.StatusOK, responseJson)
+}
+
+// ClaimReport assigns a case to the calling moderator, so two moderators
+// don't work on it at once. Claiming a case you already hold is a no-op.
+func (cfg *apiConfig) ClaimReport(w http.ResponseWriter, req *http.Request) {
+	caller := principalFromContext(req.Context())
+	report, ok := cfg.reportOrError(w, req)
+	if !ok {
+		return
+	}
+
+	claimed, err := cfg.dbQueries.ClaimReport(req.Context(), database.ClaimReportParams{
+		ClaimedBy: actor(caller.UserID),
+		ClaimedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		ID:        report.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if claimed == 0 {
+		respondWithError(w, http.StatusConflict, "The report is resolved or claimed by another moderator")
+		return
+	}
+
+	report, err = cfg.dbQueries.GetReport(req.Context(), report.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, toReport(report))
+}
+
+func (cfg *apiConfig) AddReportNote(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		Body string `json:"body"`
+	}
+
+	caller := principalFromContext(req.Context())
+	report, ok := cfg.reportOrError(w, req)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	body := strings.TrimSpace(expectedJson.Body)
+	if body == "" || utf8.RuneCountInString(body) > maxReportNoteLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("A note must have between 1 and %d characters", maxReportNoteLength))
+		return
+	}
+
+	note, err := cfg.dbQueries.CreateReportNote(req.Context(), database.CreateReportNoteParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		ReportID:  report.ID,
+		AuthorID:  actor(caller.UserID),
+		Body:      body,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, toReportNote(note))
+}
+
+// ResolveReport closes a case the caller has claimed, taking one action: dismiss
+// it, remove the reported chirp, warn its author or suspend them. Suspending
+// also needs the users:suspend permission.
+func (cfg *apiConfig) ResolveReport(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		Action        string `json:"action"`
+		Reason        string `json:"reason"`
+		DurationHours int    `json:"duration_hours"`
+		Permanent     bool   `json:"permanent"`
+	}
+
+	caller := principalFromContext(req.Context())
+	report, ok := cfg.reportOrError(w, req)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	if report.Status != reportStatusClaimed || report.ClaimedBy != actor(caller.UserID) {
+		respondWithError(w, http.StatusConflict, "Claim the report before resolving it")
+		return
+	}
+
+	now := time.Now()
+	reason := strings.TrimSpace(expectedJson.Reason)
+	var expiresAt sql.NullTime
+	switch expectedJson.Action {
+	case resolutionDismiss:
+	case resolutionRemoveChirp:
+		if report.TargetType != reportTargetChirp {
+			respondWithError(w, http.StatusBadRequest, "Only reports about a chirp can remove it")
+			return
+		}
+	case resolutionWarn:
+		if reason == "" {
+			respondWithError(w, http.StatusBadRequest, "A reason is required")
+			return
+		}
+	case resolutionSuspend:
+		allowed, err := cfg.hasPermission(req.Context(), caller, auth.PermissionUsersSuspend)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+			return
+		}
+		if !allowed {
+			respondWithError(w, http.StatusForbidden, "Missing the "+auth.PermissionUsersSuspend+" permission")
+			return
+		}
+		if reason == "" {
+			respondWithError(w, http.StatusBadRequest, "A reason is required")
+			return
+		}
+		expiresAt, err = suspensionExpiry(expectedJson.DurationHours, expectedJson.Permanent, now)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if _, suspended, err := cfg.activeSuspension(req.Context(), report.TargetUserID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+			return
+		} else if suspended {
+			respondWithError(w, http.StatusConflict, "The user is already suspended")
+			return
+		}
+	default:
+		respondWithError(w, http.StatusBadRequest, "action must be dismiss, remove_chirp, warn or suspend")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	resolved, err := qtx.ResolveReport(req.Context(), database.ResolveReportParams{
+		ResolvedBy:       actor(caller.UserID),
+		ResolvedAt:       sql.NullTime{Time: now, Valid: true},
+		Resolution:       sql.NullString{String: expectedJson.Action, Valid: true},
+		ResolutionReason: reason,
+		ID:               report.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if resolved == 0 {
+		respondWithError(w, http.StatusConflict, "Claim the report before resolving it")
+		return
+	}
+
+	var suspension database.UserSuspension
+	switch expectedJson.Action {
+	case resolutionRemoveChirp:
+		err = qtx.DeleteChirpByID(req.Context(), report.TargetID)
+	case resolutionWarn:
+		_, err = qtx.CreateUserWarning(req.Context(), database.CreateUserWarningParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UserID:    report.TargetUserID,
+			IssuedBy:  actor(caller.UserID),
+			ReportID:  uuid.NullUUID{UUID: report.ID, Valid: true},
+			Reason:    reason,
+		})
+	case resolutionSuspend:
+		suspension, err = createSuspension(req.Context(), qtx, caller, report.TargetUserID, reason, expiresAt, now)
+	}
+	if errors.Is(err, errOutranked) {
+		respondWithError(w, http.StatusForbidden, "You can't suspend a user who holds permissions you don't")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	updated, err := qtx.GetReport(req.Context(), report.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(caller.UserID),
+		Action:     "report.resolved",
+		TargetType: "report",
+		TargetID:   report.ID.String(),
+		Details:    map[string]string{"action": expectedJson.Action, "reason": reason},
+		Before:     toReport(report),
+		After:      toReport(updated),
+	})
+	switch expectedJson.Action {
+	case resolutionRemoveChirp:
+		cfg.recordAudit(req.Context(), req, auditEntry{
+			ActorID:    actor(caller.UserID),
+			Action:     "chirp.removed",
+			TargetType: "chirp",
+			TargetID:   report.TargetID.String(),
+			Details:    map[string]string{"report_id": report.ID.String(), "user_id": report.TargetUserID.String()},
+			Before:     map[string]string{"body": report.ChirpBody.String},
+		})
+	case resolutionWarn:
+		cfg.recordAudit(req.Context(), req, auditEntry{
+			ActorID:    actor(caller.UserID),
+			Action:     "user.warned",
+			TargetType: "user",
+			TargetID:   report.TargetUserID.String(),
+			Details:    map[string]string{"report_id": report.ID.String(), "reason": reason},
+		})
+		if user, err := cfg.dbQueries.GetUserByID(req.Context(), report.TargetUserID); err == nil {
+			cfg.sendMail(req.Context(), user.Email, "A warning about your Chirpy account",
+				"Chirpy's moderators have reviewed a report about your account and issued a warning:\n\n"+reason+"\n\nFurther violations may lead to your account being suspended.\n")
+		}
+	case resolutionSuspend:
+		cfg.auditSuspension(req, suspension)
+	}
+
+	respondWithJSON(w, http.StatusOK, toReport(updated))
+}
+
+// ListUserWarnings returns the warnings moderators have issued to a user,
+// newest first.
+func (cfg *apiConfig) ListUserWarnings(w http.ResponseWriter, req *http.Request) {
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format")
+		return
+	}
+
+	warnings, err := cfg.dbQueries.ListWarningsForUser(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	responseJson := make([]Warning, 0, len(warnings))
+	for _, warning := range warnings {
+		responseJson = append(responseJson, Warning{
+			ID:        warning.ID,
+			UserID:    warning.UserID,
+			IssuedBy:  nullUUIDPtr(warning.IssuedBy),
+			ReportID:  nullUUIDPtr(warning.ReportID),
+			Reason:    warning.Reason,
+			CreatedAt: warning.CreatedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, responseJson)
+}
+
+// reportOrError loads the report named in the path. On failure it writes the
+// error response itself and returns false.
+func (cfg *apiConfig) reportOrError(w http.ResponseWriter, req *http.Request) (database.Report, bool) {
+	reportID, err := uuid.Parse(req.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid report ID format")
+		return database.Report{}, false
+	}
+	report, err := cfg.dbQueries.GetReport(req.Context(), reportID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Report not found")
+		return database.Report{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return database.Report{}, false
+	}
+	return report, true
+}
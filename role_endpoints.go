@@ -0,0 +1,226 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	auth "github.com/SergioFloresCorrea/Chirpy/internal"
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// ListRoles returns every role along with the permissions it grants.
+func (cfg *apiConfig) ListRoles(w http.ResponseWriter, req *http.Request) {
+	roles, err := cfg.dbQueries.ListRoles(req.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	rolePermissions, err := cfg.dbQueries.ListRolePermissions(req.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	permissions := make(map[string][]string)
+	for _, rolePermission := range rolePermissions {
+		permissions[rolePermission.Role] = append(permissions[rolePermission.Role], rolePermission.Permission)
+	}
+	responseJson := make([]Role, 0, len(roles))
+	for _, role := range roles {
+		responseJson = append(responseJson, Role{
+			Name:        role.Name,
+			Description: role.Description,
+			Permissions: append([]string{}, permissions[role.Name]...),
+		})
+	}
+	respondWithJSON(w, http.StatusOK, responseJson)
+}
+
+func (cfg *apiConfig) GetUserRoles(w http.ResponseWriter, req *http.Request) {
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format")
+		return
+	}
+	if _, err := cfg.dbQueries.GetUserByID(req.Context(), userID); errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	roles, err := cfg.dbQueries.GetRolesForUser(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, roles)
+}
+
+// GrantUserRole gives a user a role. The user picks it up at their next
+// login or token refresh.
+func (cfg *apiConfig) GrantUserRole(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		Role string `json:"role"`
+	}
+
+	caller := principalFromContext(req.Context())
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format")
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	if _, err := cfg.dbQueries.GetRole(req.Context(), expectedJson.Role); errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "Unknown role")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if _, err := cfg.dbQueries.GetUserByID(req.Context(), userID); errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	before, err := cfg.dbQueries.GetRolesForUser(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	granted, err := cfg.dbQueries.GrantRole(req.Context(), database.GrantRoleParams{
+		UserID:    userID,
+		Role:      expectedJson.Role,
+		GrantedAt: time.Now(),
+		GrantedBy: actor(caller.UserID),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if granted > 0 {
+		cfg.recordAudit(req.Context(), req, auditEntry{
+			ActorID:    actor(caller.UserID),
+			Action:     "role.granted",
+			TargetType: "user",
+			TargetID:   userID.String(),
+			Details:    map[string]string{"role": expectedJson.Role},
+			Before:     rolesSnapshot(before),
+			After:      rolesSnapshot(withRole(before, expectedJson.Role)),
+		})
+	}
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+// RevokeUserRole takes a role away from a user. The admin role can't be
+// revoked from its last holder, or nobody could grant it again.
+func (cfg *apiConfig) RevokeUserRole(w http.ResponseWriter, req *http.Request) {
+	caller := principalFromContext(req.Context())
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format")
+		return
+	}
+	role := req.PathValue("role")
+
+	// Serializable, so two admins revoking each other can't both succeed.
+	tx, err := cfg.db.BeginTx(req.Context(), &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	before, err := qtx.GetRolesForUser(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	revoked, err := qtx.RevokeRole(req.Context(), database.RevokeRoleParams{
+		UserID: userID,
+		Role:   role,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "The user doesn't have that role")
+		return
+	}
+	if role == auth.RoleAdmin {
+		admins, err := qtx.CountUsersWithRole(req.Context(), auth.RoleAdmin)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+			return
+		}
+		if admins == 0 {
+			respondWithError(w, http.StatusConflict, "Can't revoke the admin role from the last admin")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(caller.UserID),
+		Action:     "role.revoked",
+		TargetType: "user",
+		TargetID:   userID.String(),
+		Details:    map[string]string{"role": role},
+		Before:     rolesSnapshot(before),
+		After:      rolesSnapshot(withoutRole(before, role)),
+	})
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+// rolesSnapshot is how a user's roles appear in the before and after columns
+// of the audit log.
+func rolesSnapshot(roles []string) map[string][]string {
+	if roles == nil {
+		roles = []string{}
+	}
+	return map[string][]string{"roles": roles}
+}
+
+// withRole returns roles with role added, sorted like GetRolesForUser sorts
+// them.
+func withRole(roles []string, role string) []string {
+	if slices.Contains(roles, role) {
+		return roles
+	}
+	roles = append(slices.Clone(roles), role)
+	slices.Sort(roles)
+	return roles
+}
+
+func withoutRole(roles []string, role string) []string {
+	return slices.DeleteFunc(slices.Clone(roles), func(r string) bool { return r == role })
+}
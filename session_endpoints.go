@@ -0,0 +1,123 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	auth "github.com/SergioFloresCorrea/Chirpy/internal"
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	SignedInAt time.Time `json:"signed_in_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+	// OAuthClientID is set when the session is an OAuth client acting for
+	// the user; revoking it withdraws the client's access.
+	OAuthClientID string `json:"oauth_client_id,omitempty"`
+}
+
+// ListSessions returns one entry per login that can still be refreshed.
+func (cfg *apiConfig) ListSessions(w http.ResponseWriter, req *http.Request) {
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	sessions, err := cfg.dbQueries.GetActiveSessionsForUser(req.Context(), database.GetActiveSessionsForUserParams{
+		UserID:    userID,
+		ExpiresAt: time.Now(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	responseJson := make([]Session, 0, len(sessions))
+	for _, session := range sessions {
+		responseJson = append(responseJson, Session{
+			ID:            session.FamilyID,
+			DeviceName:    session.DeviceName,
+			UserAgent:     session.UserAgent,
+			IP:            session.Ip,
+			SignedInAt:    session.SignedInAt,
+			LastUsedAt:    session.LastUsedAt,
+			ExpiresAt:     session.ExpiresAt,
+			Current:       session.FamilyID == caller.SessionID,
+			OAuthClientID: session.ClientID.String,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, responseJson)
+}
+
+func (cfg *apiConfig) RevokeSession(w http.ResponseWriter, req *http.Request) {
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	sessionID, err := uuid.Parse(req.PathValue("sessionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid session ID format")
+		return
+	}
+
+	revoked, err := cfg.dbQueries.RevokeSessionForUser(req.Context(), database.RevokeSessionForUserParams{
+		RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		FamilyID:  sessionID,
+		UserID:    userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(userID),
+		Action:     "session.revoked",
+		TargetType: "session",
+		TargetID:   sessionID.String(),
+	})
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+// RevokeAllSessions logs the caller out everywhere. Access tokens already
+// handed out stay valid until they expire.
+func (cfg *apiConfig) RevokeAllSessions(w http.ResponseWriter, req *http.Request) {
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	err := cfg.dbQueries.RevokeAllRefreshTokensForUser(req.Context(), database.RevokeAllRefreshTokensForUserParams{
+		RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UserID:    userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(userID),
+		Action:     "session.revoked_all",
+		TargetType: "user",
+		TargetID:   userID.String(),
+	})
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
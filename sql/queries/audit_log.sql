@@ -0,0 +1,40 @@
+-- name: CreateAuditLogEntry :exec
+INSERT INTO audit_log(id, created_at, actor_id, action, target_type, target_id, ip, details, before, after, request_id)
+VALUES(
+	$1,
+	$2,
+	$3,
+	$4,
+	$5,
+	$6,
+	$7,
+	$8,
+	$9,
+	$10,
+	$11
+);
+
+-- name: ListAuditLogEntries :many
+SELECT * FROM audit_log
+WHERE (sqlc.narg('actor_id')::uuid IS NULL OR actor_id = sqlc.narg('actor_id'))
+AND (sqlc.narg('action')::text IS NULL OR action = sqlc.narg('action'))
+AND (sqlc.narg('target_type')::text IS NULL OR target_type = sqlc.narg('target_type'))
+AND (sqlc.narg('target_id')::text IS NULL OR target_id = sqlc.narg('target_id'))
+AND (sqlc.narg('request_id')::text IS NULL OR request_id = sqlc.narg('request_id'))
+AND (sqlc.narg('since')::timestamp IS NULL OR created_at >= sqlc.narg('since'))
+AND (sqlc.narg('until')::timestamp IS NULL OR created_at < sqlc.narg('until'))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: ListAuditLogEntriesAfter :many
+SELECT * FROM audit_log
+WHERE (sqlc.narg('actor_id')::uuid IS NULL OR actor_id = sqlc.narg('actor_id'))
+AND (sqlc.narg('action')::text IS NULL OR action = sqlc.narg('action'))
+AND (sqlc.narg('target_type')::text IS NULL OR target_type = sqlc.narg('target_type'))
+AND (sqlc.narg('target_id')::text IS NULL OR target_id = sqlc.narg('target_id'))
+AND (sqlc.narg('request_id')::text IS NULL OR request_id = sqlc.narg('request_id'))
+AND (sqlc.narg('since')::timestamp IS NULL OR created_at >= sqlc.narg('since'))
+AND (sqlc.narg('until')::timestamp IS NULL OR created_at < sqlc.narg('until'))
+AND (sqlc.narg('after_created_at')::timestamp IS NULL OR (created_at, id) > (sqlc.narg('after_created_at'), sqlc.narg('after_id')::uuid))
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit');
@@ -1,21 +1,40 @@
 -- name: CreateChirp :one
-INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, status, body_hash)
 VALUES (
 	$1,
 	$2,
 	$3,
 	$4,
-	$5
+	$5,
+	$6,
+	$7
 )
 RETURNING *;
 
 -- name: GetChirps :many
-SELECT * FROM chirps
-ORDER BY created_at;
+SELECT chirps.* FROM chirps
+INNER JOIN users
+ON users.id = chirps.user_id
+WHERE users.scheduled_deletion_at IS NULL AND (chirps.status = 'published' OR chirps.user_id = sqlc.narg(viewer_id))
+	AND NOT EXISTS (
+		SELECT 1 FROM user_suspensions
+		WHERE user_suspensions.user_id = chirps.user_id
+			AND user_suspensions.lifted_at IS NULL
+			AND (user_suspensions.expires_at IS NULL OR user_suspensions.expires_at > NOW())
+	)
+ORDER BY chirps.created_at;
 
 -- name: GetChirpByID :one
-SELECT * FROM chirps
-WHERE id = $1
+SELECT chirps.* FROM chirps
+INNER JOIN users
+ON users.id = chirps.user_id
+WHERE chirps.id = $1 AND users.scheduled_deletion_at IS NULL AND chirps.status = 'published'
+	AND NOT EXISTS (
+		SELECT 1 FROM user_suspensions
+		WHERE user_suspensions.user_id = chirps.user_id
+			AND user_suspensions.lifted_at IS NULL
+			AND (user_suspensions.expires_at IS NULL OR user_suspensions.expires_at > NOW())
+	)
 LIMIT 1;
 
 -- name: DeleteChirpByID :exec
@@ -23,5 +42,42 @@ DELETE FROM chirps
 WHERE id = $1;
 
 -- name: GetChirpsByUserID :many
+SELECT chirps.* FROM chirps
+INNER JOIN users
+ON users.id = chirps.user_id
+WHERE chirps.user_id = $1 AND users.scheduled_deletion_at IS NULL AND chirps.status = 'published'
+	AND NOT EXISTS (
+		SELECT 1 FROM user_suspensions
+		WHERE user_suspensions.user_id = chirps.user_id
+			AND user_suspensions.lifted_at IS NULL
+			AND (user_suspensions.expires_at IS NULL OR user_suspensions.expires_at > NOW())
+	);
+
+-- name: GetOwnChirps :many
+SELECT * FROM chirps
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: GetOwnChirpByID :one
 SELECT * FROM chirps
-WHERE user_id = $1;
+WHERE id = $1 AND user_id = $2
+LIMIT 1;
+
+-- name: CountRecentChirpsWithBodyHash :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND body_hash = $2 AND created_at > $3;
+
+-- name: ListChirpsByStatus :many
+SELECT * FROM chirps
+WHERE status = $1
+ORDER BY created_at
+LIMIT $2 OFFSET $3;
+
+-- name: SetChirpStatus :execrows
+UPDATE chirps
+SET status = sqlc.arg(status), updated_at = sqlc.arg(updated_at)
+WHERE id = sqlc.arg(id) AND status = sqlc.arg(previous_status);
+
+-- name: DeleteChirpWithStatus :execrows
+DELETE FROM chirps
+WHERE id = $1 AND status = $2;
@@ -0,0 +1,40 @@
+-- name: CreateDataExport :one
+INSERT INTO data_exports(id, created_at, updated_at, user_id, expires_at)
+VALUES(
+	$1,
+	$2,
+	$3,
+	$4,
+	$5
+)
+RETURNING *;
+
+-- name: GetDataExport :one
+SELECT * FROM data_exports
+WHERE id = $1
+LIMIT 1;
+
+-- name: MarkDataExportReady :exec
+UPDATE data_exports
+SET status = 'ready', file_path = $1, completed_at = $2, updated_at = $2
+WHERE id = $3;
+
+-- name: MarkDataExportFailed :exec
+UPDATE data_exports
+SET status = 'failed', error = $1, completed_at = $2, updated_at = $2
+WHERE id = $3;
+
+-- name: DeleteExpiredDataExports :many
+DELETE FROM data_exports
+WHERE expires_at <= $1
+RETURNING file_path;
+
+-- name: DeleteDataExportsOfUsersPastGracePeriod :many
+DELETE FROM data_exports
+USING users
+WHERE data_exports.user_id = users.id AND users.scheduled_deletion_at <= $1
+RETURNING data_exports.file_path;
+
+-- name: ListDataExportFiles :many
+SELECT file_path FROM data_exports
+WHERE file_path <> '';
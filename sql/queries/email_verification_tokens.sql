@@ -0,0 +1,25 @@
+-- name: CreateEmailVerificationToken :one
+INSERT INTO email_verification_tokens(id, created_at, user_id, email, expires_at)
+VALUES(
+	$1,
+	$2,
+	$3,
+	$4,
+	$5
+)
+RETURNING *;
+
+-- name: GetEmailVerificationToken :one
+SELECT * FROM email_verification_tokens
+WHERE id = $1
+LIMIT 1;
+
+-- name: UseEmailVerificationToken :execrows
+UPDATE email_verification_tokens
+SET used_at = $1
+WHERE id = $2 AND used_at IS NULL;
+
+-- name: InvalidateEmailVerificationTokens :exec
+UPDATE email_verification_tokens
+SET used_at = $1
+WHERE user_id = $2 AND used_at IS NULL;
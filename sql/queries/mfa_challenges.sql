@@ -0,0 +1,25 @@
+-- name: CreateMFAChallenge :one
+INSERT INTO mfa_challenges(id, created_at, user_id, device_name, expires_at)
+VALUES(
+	$1,
+	$2,
+	$3,
+	$4,
+	$5
+)
+RETURNING *;
+
+-- name: GetMFAChallenge :one
+SELECT * FROM mfa_challenges
+WHERE id = $1
+LIMIT 1;
+
+-- name: RecordMFAChallengeAttempt :execrows
+UPDATE mfa_challenges
+SET attempts = attempts + 1
+WHERE id = $1 AND used_at IS NULL AND attempts < $2;
+
+-- name: UseMFAChallenge :execrows
+UPDATE mfa_challenges
+SET used_at = $1
+WHERE id = $2 AND used_at IS NULL;
@@ -0,0 +1,27 @@
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes(code_hash, created_at, client_id, user_id, redirect_uri, scopes, code_challenge, family_id, expires_at)
+VALUES(
+	$1,
+	$2,
+	$3,
+	$4,
+	$5,
+	$6,
+	$7,
+	$8,
+	$9
+);
+
+-- name: DeleteExpiredOAuthAuthorizationCodes :exec
+DELETE FROM oauth_authorization_codes
+WHERE expires_at < $1;
+
+-- name: GetOAuthAuthorizationCode :one
+SELECT * FROM oauth_authorization_codes
+WHERE code_hash = $1
+LIMIT 1;
+
+-- name: UseOAuthAuthorizationCode :execrows
+UPDATE oauth_authorization_codes
+SET used_at = $1
+WHERE code_hash = $2 AND used_at IS NULL;
@@ -0,0 +1,20 @@
+-- name: CreateOIDCLoginState :exec
+INSERT INTO oidc_login_states(state, created_at, provider, nonce, code_verifier, device_name, expires_at)
+VALUES(
+	$1,
+	$2,
+	$3,
+	$4,
+	$5,
+	$6,
+	$7
+);
+
+-- name: ConsumeOIDCLoginState :one
+DELETE FROM oidc_login_states
+WHERE state = $1
+RETURNING *;
+
+-- name: DeleteExpiredOIDCLoginStates :exec
+DELETE FROM oidc_login_states
+WHERE expires_at < $1;
@@ -0,0 +1,24 @@
+-- name: CreatePasswordResetToken :one
+INSERT INTO password_reset_tokens(token_hash, created_at, user_id, expires_at)
+VALUES(
+	$1,
+	$2,
+	$3,
+	$4
+)
+RETURNING *;
+
+-- name: GetPasswordResetToken :one
+SELECT * FROM password_reset_tokens
+WHERE token_hash = $1
+LIMIT 1;
+
+-- name: UsePasswordResetToken :execrows
+UPDATE password_reset_tokens
+SET used_at = $1
+WHERE token_hash = $2 AND used_at IS NULL;
+
+-- name: InvalidatePasswordResetTokens :exec
+UPDATE password_reset_tokens
+SET used_at = $1
+WHERE user_id = $2 AND used_at IS NULL;
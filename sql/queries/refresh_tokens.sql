@@ -1,20 +1,69 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens(token, created_at, updated_at, user_id, expires_at)
+INSERT INTO refresh_tokens(token_hash, created_at, updated_at, user_id, expires_at, family_id, user_agent, ip, device_name, last_used_at, client_id, scopes)
 VALUES(
 	$1,
 	$2,
 	$3,
 	$4,
-	$5
+	$5,
+	$6,
+	$7,
+	$8,
+	$9,
+	$10,
+	$11,
+	$12
 )
 RETURNING *;
 
 -- name: GetRefreshTokenByToken :one
 SELECT * FROM refresh_tokens
-WHERE token=$1
+WHERE token_hash=$1
 LIMIT 1;
 
 -- name: SetRevokeAt :exec
 UPDATE refresh_tokens
 SET revoked_at = $1, updated_at = $2
-WHERE token = $3;
+WHERE token_hash = $3;
+
+-- name: RevokeAllRefreshTokensForUser :exec
+UPDATE refresh_tokens
+SET revoked_at = $1, updated_at = $1
+WHERE user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeOtherSessionsForUser :exec
+UPDATE refresh_tokens
+SET revoked_at = $1, updated_at = $1
+WHERE user_id = $2 AND family_id <> $3 AND revoked_at IS NULL;
+
+-- name: GetRefreshTokensForUser :many
+SELECT * FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: RotateRefreshToken :execrows
+UPDATE refresh_tokens
+SET rotated_at = $1, updated_at = $1
+WHERE token_hash = $2 AND rotated_at IS NULL AND revoked_at IS NULL;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = $1, updated_at = $1
+WHERE family_id = $2 AND revoked_at IS NULL;
+
+-- name: GetActiveSessionsForUser :many
+SELECT refresh_tokens.*, (
+	SELECT MIN(family.created_at) FROM refresh_tokens AS family
+	WHERE family.family_id = refresh_tokens.family_id
+)::TIMESTAMP AS signed_in_at
+FROM refresh_tokens
+WHERE refresh_tokens.user_id = $1
+AND refresh_tokens.revoked_at IS NULL
+AND refresh_tokens.rotated_at IS NULL
+AND refresh_tokens.expires_at > $2
+ORDER BY refresh_tokens.last_used_at DESC;
+
+-- name: RevokeSessionForUser :execrows
+UPDATE refresh_tokens
+SET revoked_at = $1, updated_at = $1
+WHERE family_id = $2 AND user_id = $3 AND revoked_at IS NULL;
@@ -0,0 +1,15 @@
+-- name: CreateReportNote :one
+INSERT INTO report_notes(id, created_at, report_id, author_id, body)
+VALUES(
+	$1,
+	$2,
+	$3,
+	$4,
+	$5
+)
+RETURNING *;
+
+-- name: ListReportNotes :many
+SELECT * FROM report_notes
+WHERE report_id = $1
+ORDER BY created_at;
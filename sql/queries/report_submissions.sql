@@ -0,0 +1,22 @@
+-- name: CountReportSubmissionsSince :one
+SELECT COUNT(*) FROM report_submissions
+WHERE reporter_id = $1 AND created_at > $2;
+
+-- name: CreateReportSubmission :execrows
+INSERT INTO report_submissions(report_id, reporter_id, created_at, category, comment)
+VALUES(
+	$1,
+	$2,
+	$3,
+	$4,
+	$5
+)
+ON CONFLICT (report_id, reporter_id) DO NOTHING;
+
+-- name: ListReportSubmissions :many
+SELECT * FROM report_submissions
+WHERE report_id = $1
+ORDER BY created_at;
+
+-- name: LockReportSubmissionsForReporter :exec
+SELECT pg_advisory_xact_lock(hashtextextended('report_submissions:' || sqlc.arg(reporter_id)::UUID, 0));
@@ -0,0 +1,40 @@
+-- name: ClaimReport :execrows
+UPDATE reports
+SET status = 'claimed', claimed_by = $1, claimed_at = $2, updated_at = $2
+WHERE id = $3 AND status <> 'resolved' AND (claimed_by IS NULL OR claimed_by = $1);
+
+-- name: GetReport :one
+SELECT * FROM reports
+WHERE id = $1
+LIMIT 1;
+
+-- name: IncrementReportCount :exec
+UPDATE reports
+SET report_count = report_count + 1, updated_at = $1
+WHERE id = $2;
+
+-- name: ListReportsByStatus :many
+SELECT * FROM reports
+WHERE status = $1
+ORDER BY report_count DESC, created_at
+LIMIT $2 OFFSET $3;
+
+-- name: OpenReport :one
+INSERT INTO reports(id, created_at, updated_at, target_type, target_id, target_user_id, chirp_body)
+VALUES(
+	$1,
+	$2,
+	$3,
+	$4,
+	$5,
+	$6,
+	$7
+)
+ON CONFLICT (target_type, target_id) WHERE status <> 'resolved'
+DO UPDATE SET updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: ResolveReport :execrows
+UPDATE reports
+SET status = 'resolved', resolved_by = $1, resolved_at = $2, updated_at = $2, resolution = $3, resolution_reason = $4
+WHERE id = $5 AND status = 'claimed' AND claimed_by = $1;
@@ -0,0 +1,52 @@
+-- name: CountUsersWithRole :one
+SELECT COUNT(*) FROM user_roles
+WHERE role = $1;
+
+-- name: CountUsersWithPermission :one
+SELECT COUNT(DISTINCT user_roles.user_id) FROM user_roles
+JOIN role_permissions ON role_permissions.role = user_roles.role
+WHERE role_permissions.permission = $1;
+
+-- name: GetPermissionsForUser :many
+SELECT DISTINCT role_permissions.permission FROM user_roles
+JOIN role_permissions ON role_permissions.role = user_roles.role
+WHERE user_roles.user_id = $1
+ORDER BY role_permissions.permission;
+
+-- name: GetPermissionsForUserRoles :many
+SELECT DISTINCT role_permissions.permission FROM user_roles
+JOIN role_permissions ON role_permissions.role = user_roles.role
+WHERE user_roles.user_id = sqlc.arg(user_id) AND user_roles.role = ANY(sqlc.arg(roles)::TEXT[])
+ORDER BY role_permissions.permission;
+
+-- name: GetRole :one
+SELECT * FROM roles
+WHERE name = $1
+LIMIT 1;
+
+-- name: GetRolesForUser :many
+SELECT role FROM user_roles
+WHERE user_id = $1
+ORDER BY role;
+
+-- name: GrantRole :execrows
+INSERT INTO user_roles(user_id, role, granted_at, granted_by)
+VALUES(
+	$1,
+	$2,
+	$3,
+	$4
+)
+ON CONFLICT (user_id, role) DO NOTHING;
+
+-- name: ListRolePermissions :many
+SELECT * FROM role_permissions
+ORDER BY role, permission;
+
+-- name: ListRoles :many
+SELECT * FROM roles
+ORDER BY name;
+
+-- name: RevokeRole :execrows
+DELETE FROM user_roles
+WHERE user_id = $1 AND role = $2;
@@ -0,0 +1,16 @@
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities(id, created_at, user_id, provider, subject, email)
+VALUES(
+	$1,
+	$2,
+	$3,
+	$4,
+	$5,
+	$6
+)
+RETURNING *;
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE provider = $1 AND subject = $2
+LIMIT 1;
@@ -0,0 +1,27 @@
+-- name: CreateUserSuspension :one
+INSERT INTO user_suspensions(id, created_at, user_id, suspended_by, reason, expires_at)
+VALUES(
+	$1,
+	$2,
+	$3,
+	$4,
+	$5,
+	$6
+)
+RETURNING *;
+
+-- name: GetActiveSuspensionForUser :one
+SELECT * FROM user_suspensions
+WHERE user_id = $1 AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+ORDER BY expires_at DESC NULLS FIRST
+LIMIT 1;
+
+-- name: LiftUserSuspension :execrows
+UPDATE user_suspensions
+SET lifted_at = $1, lifted_by = $2
+WHERE id = $3 AND user_id = $4 AND lifted_at IS NULL;
+
+-- name: ListSuspensionsForUser :many
+SELECT * FROM user_suspensions
+WHERE user_id = $1
+ORDER BY created_at DESC;
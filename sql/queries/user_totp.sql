@@ -0,0 +1,30 @@
+-- name: UpsertTOTPEnrollment :one
+INSERT INTO user_totp(user_id, created_at, secret)
+VALUES(
+	$1,
+	$2,
+	$3
+)
+ON CONFLICT (user_id) DO UPDATE
+SET created_at = EXCLUDED.created_at, secret = EXCLUDED.secret, last_used_step = 0
+WHERE user_totp.confirmed_at IS NULL
+RETURNING *;
+
+-- name: GetTOTPByUserID :one
+SELECT * FROM user_totp
+WHERE user_id = $1
+LIMIT 1;
+
+-- name: ConfirmTOTP :execrows
+UPDATE user_totp
+SET confirmed_at = $1, last_used_step = $2
+WHERE user_id = $3 AND confirmed_at IS NULL;
+
+-- name: SetTOTPLastUsedStep :execrows
+UPDATE user_totp
+SET last_used_step = $1
+WHERE user_id = $2 AND last_used_step < $1;
+
+-- name: DeleteTOTP :execrows
+DELETE FROM user_totp
+WHERE user_id = $1;
@@ -0,0 +1,16 @@
+-- name: CreateUserWarning :one
+INSERT INTO user_warnings(id, created_at, user_id, issued_by, report_id, reason)
+VALUES(
+	$1,
+	$2,
+	$3,
+	$4,
+	$5,
+	$6
+)
+RETURNING *;
+
+-- name: ListWarningsForUser :many
+SELECT * FROM user_warnings
+WHERE user_id = $1
+ORDER BY created_at DESC;
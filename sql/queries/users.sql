@@ -9,29 +9,57 @@ VALUES (
 )
 RETURNING *;
 
--- name: DeleteUsers :exec
-DELETE FROM users;
-
 -- name: GetUserByEmail :one
 SELECT * FROM users
 WHERE email = $1
 LIMIT 1;
 
+-- name: GetUserByEmailIgnoringCase :one
+SELECT * FROM users
+WHERE lower(email) = lower($1)
+ORDER BY created_at
+LIMIT 1;
+
 -- name: GetUserFromRefreshToken :one
 SELECT * FROM users
 INNER JOIN refresh_tokens
 ON users.id = refresh_tokens.user_id
-WHERE refresh_tokens.token = $1
+WHERE refresh_tokens.token_hash = $1
 LIMIT 1;
 
--- name: UpdateUserEmailAndPassword :one
-UPDATE users
-SET email = $1, hashed_password = $2
-WHERE id = $3
-RETURNING *;
-
 -- name: UpgradeUserToRedByID :exec
 UPDATE users
 SET is_chirpy_red = true
 WHERE id = $1
 RETURNING *;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1
+LIMIT 1;
+
+-- name: SetUserEmailVerified :one
+UPDATE users
+SET email = $1, email_verified_at = $2, updated_at = $2
+WHERE id = $3
+RETURNING *;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $1, updated_at = $2
+WHERE id = $3;
+
+-- name: ScheduleUserDeletion :exec
+UPDATE users
+SET scheduled_deletion_at = $1, updated_at = $2
+WHERE id = $3;
+
+-- name: CancelUserDeletion :exec
+UPDATE users
+SET scheduled_deletion_at = NULL, updated_at = $1
+WHERE id = $2;
+
+-- name: DeleteUsersPastGracePeriod :many
+DELETE FROM users
+WHERE scheduled_deletion_at <= $1
+RETURNING id;
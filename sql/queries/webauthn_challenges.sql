@@ -0,0 +1,20 @@
+-- name: CreateWebAuthnChallenge :exec
+INSERT INTO webauthn_challenges(id, created_at, user_id, ceremony, challenge, device_name, expires_at)
+VALUES(
+	$1,
+	$2,
+	$3,
+	$4,
+	$5,
+	$6,
+	$7
+);
+
+-- name: ConsumeWebAuthnChallenge :one
+DELETE FROM webauthn_challenges
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteExpiredWebAuthnChallenges :exec
+DELETE FROM webauthn_challenges
+WHERE expires_at < $1;
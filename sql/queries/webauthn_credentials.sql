@@ -0,0 +1,41 @@
+-- name: CreateWebAuthnCredential :one
+INSERT INTO webauthn_credentials(id, created_at, user_id, name, credential_id, public_key, sign_count, transports)
+VALUES(
+	$1,
+	$2,
+	$3,
+	$4,
+	$5,
+	$6,
+	$7,
+	$8
+)
+RETURNING *;
+
+-- name: DeleteWebAuthnCredential :execrows
+DELETE FROM webauthn_credentials
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteWebAuthnCredentialsForUser :exec
+DELETE FROM webauthn_credentials
+WHERE user_id = $1;
+
+-- name: GetWebAuthnCredentialByCredentialID :one
+SELECT * FROM webauthn_credentials
+WHERE credential_id = $1
+LIMIT 1;
+
+-- name: ListWebAuthnCredentialsForUser :many
+SELECT * FROM webauthn_credentials
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: RenameWebAuthnCredential :execrows
+UPDATE webauthn_credentials
+SET name = $1
+WHERE id = $2 AND user_id = $3;
+
+-- name: UpdateWebAuthnCredentialUse :execrows
+UPDATE webauthn_credentials
+SET sign_count = sqlc.arg(sign_count), last_used_at = sqlc.arg(last_used_at)
+WHERE id = sqlc.arg(id) AND sign_count = sqlc.arg(previous_sign_count);
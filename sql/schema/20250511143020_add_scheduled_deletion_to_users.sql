@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+ADD COLUMN scheduled_deletion_at TIMESTAMP;
+
+CREATE INDEX users_scheduled_deletion_at_idx ON users (scheduled_deletion_at)
+WHERE scheduled_deletion_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+DROP COLUMN scheduled_deletion_at;
+-- +goose StatementEnd
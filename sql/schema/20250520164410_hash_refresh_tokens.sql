@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Existing tokens are hashed in place with the same SHA-256 hex encoding the
+-- server uses, so sessions survive the migration without clients noticing.
+ALTER TABLE refresh_tokens
+ADD COLUMN token_hash TEXT;
+
+UPDATE refresh_tokens
+SET token_hash = encode(sha256(convert_to(token, 'UTF8')), 'hex');
+
+ALTER TABLE refresh_tokens
+ALTER COLUMN token_hash SET NOT NULL,
+DROP CONSTRAINT refresh_tokens_pkey,
+DROP COLUMN token,
+ADD PRIMARY KEY (token_hash);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- The raw tokens can't be recovered from their hashes, so rolling back logs
+-- every session out: each row keeps its hash as a token no client holds.
+ALTER TABLE refresh_tokens
+DROP CONSTRAINT refresh_tokens_pkey;
+
+ALTER TABLE refresh_tokens
+RENAME COLUMN token_hash TO token;
+
+ALTER TABLE refresh_tokens
+ADD PRIMARY KEY (token);
+
+UPDATE refresh_tokens
+SET revoked_at = NOW()
+WHERE revoked_at IS NULL;
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE oauth_clients(
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	owner_id UUID NOT NULL,
+	name TEXT NOT NULL,
+	secret_hash TEXT,
+	redirect_uris TEXT[] NOT NULL,
+	scopes TEXT[] NOT NULL,
+	FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE oauth_authorization_codes(
+	code_hash TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	client_id TEXT NOT NULL,
+	user_id UUID NOT NULL,
+	redirect_uri TEXT NOT NULL,
+	scopes TEXT[] NOT NULL,
+	code_challenge TEXT NOT NULL,
+	family_id UUID NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP,
+	FOREIGN KEY (client_id) REFERENCES oauth_clients (id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+ALTER TABLE refresh_tokens
+	ADD COLUMN client_id TEXT REFERENCES oauth_clients (id) ON DELETE CASCADE,
+	ADD COLUMN scopes TEXT[] NOT NULL DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE refresh_tokens
+	DROP COLUMN scopes,
+	DROP COLUMN client_id;
+DROP TABLE oauth_authorization_codes;
+DROP TABLE oauth_clients;
+-- +goose StatementEnd
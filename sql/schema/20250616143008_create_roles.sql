@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE roles(
+	name TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	description TEXT NOT NULL
+);
+
+CREATE TABLE role_permissions(
+	role TEXT NOT NULL,
+	permission TEXT NOT NULL,
+	PRIMARY KEY (role, permission),
+	FOREIGN KEY (role) REFERENCES roles (name) ON DELETE CASCADE
+);
+
+CREATE TABLE user_roles(
+	user_id UUID NOT NULL,
+	role TEXT NOT NULL,
+	granted_at TIMESTAMP NOT NULL,
+	granted_by UUID,
+	PRIMARY KEY (user_id, role),
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+	FOREIGN KEY (role) REFERENCES roles (name) ON DELETE CASCADE,
+	FOREIGN KEY (granted_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+INSERT INTO roles(name, created_at, description) VALUES
+	('admin', NOW(), 'Full access to the admin API, including roles'),
+	('moderator', NOW(), 'Looks after users and content');
+
+INSERT INTO role_permissions(role, permission) VALUES
+	('admin', 'metrics:read'),
+	('admin', 'data:reset'),
+	('admin', 'login:unlock'),
+	('admin', 'roles:manage'),
+	('moderator', 'metrics:read'),
+	('moderator', 'login:unlock');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE user_roles;
+DROP TABLE role_permissions;
+DROP TABLE roles;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE user_suspensions(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL,
+	suspended_by UUID,
+	reason TEXT NOT NULL,
+	expires_at TIMESTAMP,
+	lifted_at TIMESTAMP,
+	lifted_by UUID,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+	FOREIGN KEY (suspended_by) REFERENCES users (id) ON DELETE SET NULL,
+	FOREIGN KEY (lifted_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX user_suspensions_user_id_idx ON user_suspensions (user_id);
+
+INSERT INTO role_permissions(role, permission) VALUES
+	('admin', 'users:suspend'),
+	('moderator', 'users:suspend');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM role_permissions WHERE permission = 'users:suspend';
+DROP TABLE user_suspensions;
+-- +goose StatementEnd
@@ -0,0 +1,76 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE reports(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	target_type TEXT NOT NULL,
+	target_id UUID NOT NULL,
+	target_user_id UUID NOT NULL,
+	chirp_body TEXT,
+	report_count INTEGER NOT NULL DEFAULT 0,
+	status TEXT NOT NULL DEFAULT 'open',
+	claimed_by UUID,
+	claimed_at TIMESTAMP,
+	resolved_by UUID,
+	resolved_at TIMESTAMP,
+	resolution TEXT,
+	resolution_reason TEXT NOT NULL DEFAULT '',
+	FOREIGN KEY (target_user_id) REFERENCES users (id) ON DELETE CASCADE,
+	FOREIGN KEY (claimed_by) REFERENCES users (id) ON DELETE SET NULL,
+	FOREIGN KEY (resolved_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+-- Every report about the same chirp or user joins one open case, so a pile
+-- of reports is a single queue item rather than many.
+CREATE UNIQUE INDEX reports_open_target_idx ON reports (target_type, target_id) WHERE status <> 'resolved';
+CREATE INDEX reports_status_idx ON reports (status, created_at);
+
+CREATE TABLE report_submissions(
+	report_id UUID NOT NULL,
+	reporter_id UUID NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	category TEXT NOT NULL,
+	comment TEXT NOT NULL,
+	PRIMARY KEY (report_id, reporter_id),
+	FOREIGN KEY (report_id) REFERENCES reports (id) ON DELETE CASCADE,
+	FOREIGN KEY (reporter_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX report_submissions_reporter_id_idx ON report_submissions (reporter_id, created_at);
+
+CREATE TABLE report_notes(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	report_id UUID NOT NULL,
+	author_id UUID,
+	body TEXT NOT NULL,
+	FOREIGN KEY (report_id) REFERENCES reports (id) ON DELETE CASCADE,
+	FOREIGN KEY (author_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE TABLE user_warnings(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL,
+	issued_by UUID,
+	report_id UUID,
+	reason TEXT NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+	FOREIGN KEY (issued_by) REFERENCES users (id) ON DELETE SET NULL,
+	FOREIGN KEY (report_id) REFERENCES reports (id) ON DELETE SET NULL
+);
+
+INSERT INTO role_permissions(role, permission) VALUES
+	('admin', 'reports:moderate'),
+	('moderator', 'reports:moderate');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM role_permissions WHERE permission = 'reports:moderate';
+DROP TABLE user_warnings;
+DROP TABLE report_notes;
+DROP TABLE report_submissions;
+DROP TABLE reports;
+-- +goose StatementEnd
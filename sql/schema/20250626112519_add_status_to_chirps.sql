@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE chirps
+ADD COLUMN status TEXT NOT NULL DEFAULT 'published';
+
+CREATE INDEX chirps_status_idx ON chirps (status, created_at) WHERE status <> 'published';
+CREATE INDEX chirps_user_id_created_at_idx ON chirps (user_id, created_at);
+
+INSERT INTO role_permissions(role, permission) VALUES
+	('admin', 'chirps:moderate'),
+	('moderator', 'chirps:moderate');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM role_permissions WHERE permission = 'chirps:moderate';
+DROP INDEX chirps_user_id_created_at_idx;
+DROP INDEX chirps_status_idx;
+ALTER TABLE chirps
+DROP COLUMN status;
+-- +goose StatementEnd
@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE audit_log
+ADD COLUMN before JSONB NOT NULL DEFAULT 'null',
+ADD COLUMN after JSONB NOT NULL DEFAULT 'null',
+ADD COLUMN request_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX audit_log_actor_id_idx ON audit_log (actor_id, created_at);
+CREATE INDEX audit_log_target_idx ON audit_log (target_type, target_id, created_at);
+CREATE INDEX audit_log_action_idx ON audit_log (action, created_at);
+
+CREATE FUNCTION audit_log_is_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_no_update_or_delete
+BEFORE UPDATE OR DELETE ON audit_log
+FOR EACH ROW EXECUTE FUNCTION audit_log_is_append_only();
+
+CREATE TRIGGER audit_log_no_truncate
+BEFORE TRUNCATE ON audit_log
+FOR EACH STATEMENT EXECUTE FUNCTION audit_log_is_append_only();
+
+INSERT INTO role_permissions(role, permission) VALUES
+	('admin', 'audit:read');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM role_permissions WHERE permission = 'audit:read';
+DROP TRIGGER audit_log_no_truncate ON audit_log;
+DROP TRIGGER audit_log_no_update_or_delete ON audit_log;
+DROP FUNCTION audit_log_is_append_only();
+DROP INDEX audit_log_action_idx;
+DROP INDEX audit_log_target_idx;
+DROP INDEX audit_log_actor_id_idx;
+ALTER TABLE audit_log
+DROP COLUMN request_id,
+DROP COLUMN after,
+DROP COLUMN before;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- body holds the chirp as stored, after moderation masked it. body_hash is
+-- the SHA-256 of the chirp as written, which repeat rules compare.
+ALTER TABLE chirps
+ADD COLUMN body_hash TEXT;
+
+UPDATE chirps
+SET body_hash = encode(sha256(convert_to(body, 'UTF8')), 'hex');
+
+ALTER TABLE chirps
+ALTER COLUMN body_hash SET NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE chirps
+DROP COLUMN body_hash;
+-- +goose StatementEnd
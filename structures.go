@@ -3,6 +3,7 @@ package main
 import (
 	"time"
 
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
 	"github.com/google/uuid"
 )
 
@@ -21,3 +22,23 @@ type Chirp struct {
 	Body      string    `json:"body"`
 	UserID    uuid.UUID `json:"user_id"`
 }
+
+// HeldChirp is what the author gets back when moderation holds their chirp
+// for review.
+type HeldChirp struct {
+	Chirp
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// toChirp leaves out the status, so a shadow-hidden chirp looks published to
+// its author.
+func toChirp(chirp database.Chirp) Chirp {
+	return Chirp{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserID:    chirp.UserID,
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	auth "github.com/SergioFloresCorrea/Chirpy/internal"
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// maxSuspensionHours caps timed suspensions; anything longer is a ban.
+const maxSuspensionHours = 365 * 24
+
+var (
+	errAccountSuspended = errors.New("account suspended")
+	errOutranked        = errors.New("the user holds permissions you don't")
+)
+
+type Suspension struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	Reason      string     `json:"reason"`
+	SuspendedBy *uuid.UUID `json:"suspended_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	// ExpiresAt is null for a permanent ban.
+	ExpiresAt *time.Time `json:"expires_at"`
+	LiftedAt  *time.Time `json:"lifted_at"`
+	Active    bool       `json:"active"`
+}
+
+func toSuspension(suspension database.UserSuspension, now time.Time) Suspension {
+	return Suspension{
+		ID:          suspension.ID,
+		UserID:      suspension.UserID,
+		Reason:      suspension.Reason,
+		SuspendedBy: nullUUIDPtr(suspension.SuspendedBy),
+		CreatedAt:   suspension.CreatedAt,
+		ExpiresAt:   nullTimePtr(suspension.ExpiresAt),
+		LiftedAt:    nullTimePtr(suspension.LiftedAt),
+		Active:      !suspension.LiftedAt.Valid && (!suspension.ExpiresAt.Valid || suspension.ExpiresAt.Time.After(now)),
+	}
+}
+
+// activeSuspension returns the suspension currently in force for a user, if
+// any. When several overlap, it is the one that lasts longest.
+func (cfg *apiConfig) activeSuspension(ctx context.Context, userID uuid.UUID) (database.UserSuspension, bool, error) {
+	suspension, err := cfg.dbQueries.GetActiveSuspensionForUser(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.UserSuspension{}, false, nil
+	}
+	if err != nil {
+		return database.UserSuspension{}, false, err
+	}
+	return suspension, true, nil
+}
+
+// checkNotSuspended returns errAccountSuspended if userID may not use the API
+// right now.
+func (cfg *apiConfig) checkNotSuspended(ctx context.Context, userID uuid.UUID) error {
+	_, suspended, err := cfg.activeSuspension(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if suspended {
+		return errAccountSuspended
+	}
+	return nil
+}
+
+// suspensionMessage tells a suspended user why they can't log in and for how
+// long.
+func suspensionMessage(suspension database.UserSuspension) string {
+	if !suspension.ExpiresAt.Valid {
+		return "Your account has been banned: " + suspension.Reason
+	}
+	return fmt.Sprintf("Your account is suspended until %s: %s", suspension.ExpiresAt.Time.UTC().Format(time.RFC3339), suspension.Reason)
+}
+
+// refuseSuspendedLogin answers a login attempt by a suspended user and
+// returns true. It only runs once the user has proven who they are, so it
+// tells nobody else that the account is suspended.
+func (cfg *apiConfig) refuseSuspendedLogin(w http.ResponseWriter, req *http.Request, userID uuid.UUID) bool {
+	suspension, suspended, err := cfg.activeSuspension(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return true
+	}
+	if suspended {
+		respondWithError(w, http.StatusForbidden, suspensionMessage(suspension))
+		return true
+	}
+	return false
+}
+
+// SuspendUser suspends a user for a number of hours, or bans them for good.
+// Their sessions end at once and their chirps are hidden until the
+// suspension ends.
+func (cfg *apiConfig) SuspendUser(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		Reason        string `json:"reason"`
+		DurationHours int    `json:"duration_hours"`
+		Permanent     bool   `json:"permanent"`
+	}
+
+	caller := principalFromContext(req.Context())
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format")
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	now := time.Now()
+	reason := strings.TrimSpace(expectedJson.Reason)
+	if reason == "" {
+		respondWithError(w, http.StatusBadRequest, "A reason is required")
+		return
+	}
+	expiresAt, err := suspensionExpiry(expectedJson.DurationHours, expectedJson.Permanent, now)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if userID == caller.UserID {
+		respondWithError(w, http.StatusBadRequest, "You can't suspend yourself")
+		return
+	}
+
+	if _, err := cfg.dbQueries.GetUserByID(req.Context(), userID); errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if _, suspended, err := cfg.activeSuspension(req.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	} else if suspended {
+		respondWithError(w, http.StatusConflict, "The user is already suspended")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	suspension, err := createSuspension(req.Context(), qtx, caller, userID, reason, expiresAt, now)
+	if errors.Is(err, errOutranked) {
+		respondWithError(w, http.StatusForbidden, "You can't suspend a user who holds permissions you don't")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	cfg.auditSuspension(req, suspension)
+	respondWithJSON(w, http.StatusCreated, toSuspension(suspension, now))
+}
+
+// suspensionExpiry turns a requested length into the suspension's expiry,
+// which is null for a permanent ban. Exactly one of the two has to be given.
+func suspensionExpiry(durationHours int, permanent bool, now time.Time) (sql.NullTime, error) {
+	if permanent == (durationHours != 0) {
+		return sql.NullTime{}, errors.New("either duration_hours or permanent is required")
+	}
+	if permanent {
+		return sql.NullTime{}, nil
+	}
+	if durationHours < 0 || durationHours > maxSuspensionHours {
+		return sql.NullTime{}, fmt.Errorf("duration_hours must be between 1 and %d", maxSuspensionHours)
+	}
+	return sql.NullTime{Time: now.Add(time.Duration(durationHours) * time.Hour), Valid: true}, nil
+}
+
+// createSuspension records a suspension and revokes every refresh token of
+// the user, so no session outlives it. Access tokens are refused by
+// authenticate from then on. qtx should be bound to a transaction.
+//
+// It returns errOutranked when the user holds a permission caller doesn't,
+// unless caller can manage roles: otherwise a moderator could lock an admin
+// out of the very API that lifts suspensions.
+func createSuspension(ctx context.Context, qtx *database.Queries, caller principal, userID uuid.UUID, reason string, expiresAt sql.NullTime, now time.Time) (database.UserSuspension, error) {
+	callerPermissions, err := qtx.GetPermissionsForUserRoles(ctx, database.GetPermissionsForUserRolesParams{
+		UserID: caller.UserID,
+		Roles:  caller.Roles,
+	})
+	if err != nil {
+		return database.UserSuspension{}, err
+	}
+	targetPermissions, err := qtx.GetPermissionsForUser(ctx, userID)
+	if err != nil {
+		return database.UserSuspension{}, err
+	}
+	if !auth.Outranks(callerPermissions, targetPermissions) {
+		return database.UserSuspension{}, errOutranked
+	}
+
+	suspension, err := qtx.CreateUserSuspension(ctx, database.CreateUserSuspensionParams{
+		ID:          uuid.New(),
+		CreatedAt:   now,
+		UserID:      userID,
+		SuspendedBy: actor(caller.UserID),
+		Reason:      reason,
+		ExpiresAt:   expiresAt,
+	})
+	if err != nil {
+		return database.UserSuspension{}, err
+	}
+
+	err = qtx.RevokeAllRefreshTokensForUser(ctx, database.RevokeAllRefreshTokensForUserParams{
+		RevokedAt: sql.NullTime{Time: now, Valid: true},
+		UserID:    userID,
+	})
+	if err != nil {
+		return database.UserSuspension{}, err
+	}
+	return suspension, nil
+}
+
+func (cfg *apiConfig) auditSuspension(req *http.Request, suspension database.UserSuspension) {
+	action := "user.suspended"
+	if !suspension.ExpiresAt.Valid {
+		action = "user.banned"
+	}
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    suspension.SuspendedBy,
+		Action:     action,
+		TargetType: "user",
+		TargetID:   suspension.UserID.String(),
+		Details:    map[string]any{"suspension_id": suspension.ID, "reason": suspension.Reason, "expires_at": nullTimePtr(suspension.ExpiresAt)},
+		After:      toSuspension(suspension, suspension.CreatedAt),
+	})
+}
+
+// ListUserSuspensions returns a user's suspensions, newest first, including
+// those that have ended.
+func (cfg *apiConfig) ListUserSuspensions(w http.ResponseWriter, req *http.Request) {
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format")
+		return
+	}
+
+	suspensions, err := cfg.dbQueries.ListSuspensionsForUser(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	now := time.Now()
+	responseJson := make([]Suspension, 0, len(suspensions))
+	for _, suspension := range suspensions {
+		responseJson = append(responseJson, toSuspension(suspension, now))
+	}
+	respondWithJSON(w, http.StatusOK, responseJson)
+}
+
+// LiftUserSuspension ends a suspension or ban early. The user has to log in
+// again, since their sessions were revoked when they were suspended.
+func (cfg *apiConfig) LiftUserSuspension(w http.ResponseWriter, req *http.Request) {
+	caller := principalFromContext(req.Context())
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format")
+		return
+	}
+	suspensionID, err := uuid.Parse(req.PathValue("suspensionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid suspension ID format")
+		return
+	}
+
+	liftedAt := time.Now()
+	lifted, err := cfg.dbQueries.LiftUserSuspension(req.Context(), database.LiftUserSuspensionParams{
+		LiftedAt: sql.NullTime{Time: liftedAt, Valid: true},
+		LiftedBy: actor(caller.UserID),
+		ID:       suspensionID,
+		UserID:   userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if lifted == 0 {
+		respondWithError(w, http.StatusNotFound, "Suspension not found")
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(caller.UserID),
+		Action:     "user.suspension_lifted",
+		TargetType: "user",
+		TargetID:   userID.String(),
+		Details:    map[string]string{"suspension_id": suspensionID.String()},
+		Before:     map[string]any{"lifted_at": nil},
+		After:      map[string]any{"lifted_at": liftedAt},
+	})
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
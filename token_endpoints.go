@@ -0,0 +1,181 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	auth "github.com/SergioFloresCorrea/Chirpy/internal"
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPersonalAccessTokenDays = 30
+	maxPersonalAccessTokenDays     = 365
+)
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func toPersonalAccessToken(token database.PersonalAccessToken) PersonalAccessToken {
+	return PersonalAccessToken{
+		ID:         token.ID,
+		Name:       token.Name,
+		Scopes:     token.Scopes,
+		CreatedAt:  token.CreatedAt,
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: nullTimePtr(token.LastUsedAt),
+	}
+}
+
+// CreatePersonalAccessToken issues a token for scripts and integrations. The
+// token itself is only ever shown in this response.
+func (cfg *apiConfig) CreatePersonalAccessToken(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	type ResponseJson struct {
+		PersonalAccessToken
+		Token string `json:"token"`
+	}
+
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	name := strings.TrimSpace(expectedJson.Name)
+	if name == "" {
+		respondWithError(w, http.StatusBadRequest, "Token name is required")
+		return
+	}
+	if len(expectedJson.Scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one scope is required")
+		return
+	}
+	for _, scope := range expectedJson.Scopes {
+		if !auth.HasScope(auth.DelegatedScopes, scope) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Scope %q can't be granted to a personal access token", scope))
+			return
+		}
+	}
+	days := expectedJson.ExpiresInDays
+	if days == 0 {
+		days = defaultPersonalAccessTokenDays
+	}
+	if days < 0 || days > maxPersonalAccessTokenDays {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("expires_in_days must be between 1 and %d", maxPersonalAccessTokenDays))
+		return
+	}
+
+	token, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	now := time.Now()
+	created, err := cfg.dbQueries.CreatePersonalAccessToken(req.Context(), database.CreatePersonalAccessTokenParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UserID:    userID,
+		Name:      name,
+		TokenHash: auth.HashToken(token),
+		Scopes:    expectedJson.Scopes,
+		ExpiresAt: now.AddDate(0, 0, days),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(userID),
+		Action:     "personal_access_token.created",
+		TargetType: "personal_access_token",
+		TargetID:   created.ID.String(),
+		Details:    map[string]any{"name": created.Name, "scopes": created.Scopes},
+	})
+	respondWithJSON(w, http.StatusCreated, ResponseJson{
+		PersonalAccessToken: toPersonalAccessToken(created),
+		Token:               token,
+	})
+}
+
+// ListPersonalAccessTokens returns the caller's tokens that haven't been
+// revoked, including expired ones so they can be recognised and cleaned up.
+func (cfg *apiConfig) ListPersonalAccessTokens(w http.ResponseWriter, req *http.Request) {
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	tokens, err := cfg.dbQueries.ListPersonalAccessTokens(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	responseJson := make([]PersonalAccessToken, 0, len(tokens))
+	for _, token := range tokens {
+		responseJson = append(responseJson, toPersonalAccessToken(token))
+	}
+	respondWithJSON(w, http.StatusOK, responseJson)
+}
+
+func (cfg *apiConfig) RevokePersonalAccessToken(w http.ResponseWriter, req *http.Request) {
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	tokenID, err := uuid.Parse(req.PathValue("tokenID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid token ID format")
+		return
+	}
+
+	revoked, err := cfg.dbQueries.RevokePersonalAccessToken(req.Context(), database.RevokePersonalAccessTokenParams{
+		RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		ID:        tokenID,
+		UserID:    userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "Token not found")
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(userID),
+		Action:     "personal_access_token.revoked",
+		TargetType: "personal_access_token",
+		TargetID:   tokenID.String(),
+	})
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	auth "github.com/SergioFloresCorrea/Chirpy/internal"
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	emailVerificationPurpose = "email-verification"
+	emailVerificationTTL     = 24 * time.Hour
+)
+
+// Actions that UNVERIFIED_RESTRICTIONS can withhold from users who haven't
+// confirmed their email address yet.
+const (
+	actionLogin       = "login"
+	actionCreateChirp = "create_chirp"
+	actionDeleteChirp = "delete_chirp"
+	actionReport      = "report"
+)
+
+// Reporting is restricted by default, so throwaway accounts can't be used to
+// pile reports onto someone.
+const defaultUnverifiedRestrictions = actionCreateChirp + "," + actionReport
+
+type unverifiedRestrictions map[string]bool
+
+// parseUnverifiedRestrictions reads a comma separated list of actions, e.g.
+// "login,create_chirp". The literal "none" lifts every restriction.
+func parseUnverifiedRestrictions(list string) (unverifiedRestrictions, error) {
+	restrictions := unverifiedRestrictions{}
+	if list == "none" {
+		return restrictions, nil
+	}
+	for _, action := range strings.Split(list, ",") {
+		action = strings.TrimSpace(action)
+		switch action {
+		case "":
+			continue
+		case actionLogin, actionCreateChirp, actionDeleteChirp, actionReport:
+			restrictions[action] = true
+		default:
+			return nil, fmt.Errorf("unknown unverified restriction %q", action)
+		}
+	}
+	return restrictions, nil
+}
+
+func (r unverifiedRestrictions) blocks(action string, user database.User) bool {
+	return r[action] && !user.EmailVerifiedAt.Valid
+}
+
+// blockedUntilVerified looks the user up only when action is restricted, so
+// unrestricted endpoints don't pay for the extra query.
+func (cfg *apiConfig) blockedUntilVerified(ctx context.Context, userID uuid.UUID, action string) (bool, error) {
+	if !cfg.unverifiedRestrictions[action] {
+		return false, nil
+	}
+	user, err := cfg.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return cfg.unverifiedRestrictions.blocks(action, user), nil
+}
+
+// sendVerificationEmail issues a fresh single-use token for email and mails it
+// there. email differs from user.Email when the user is changing address.
+// The user's earlier tokens stop working in the same transaction, so an old
+// link can't confirm an address the user has since changed their mind about.
+func (cfg *apiConfig) sendVerificationEmail(ctx context.Context, user database.User, email string) error {
+	now := time.Now()
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	err = qtx.InvalidateEmailVerificationTokens(ctx, database.InvalidateEmailVerificationTokensParams{
+		UsedAt: sql.NullTime{Time: now, Valid: true},
+		UserID: user.ID,
+	})
+	if err != nil {
+		return err
+	}
+	verification, err := qtx.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UserID:    user.ID,
+		Email:     email,
+		ExpiresAt: now.Add(emailVerificationTTL),
+	})
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	token := auth.MakeSignedToken(verification.ID, emailVerificationPurpose, cfg.secret)
+	body := fmt.Sprintf(`Confirm your Chirpy email address by sending this token to %s/api/users/verify:
+
+%s
+
+The token expires in %s and can only be used once.`, cfg.baseURL, token, emailVerificationTTL)
+	cfg.sendMail(ctx, email, "Confirm your Chirpy email address", body)
+	return nil
+}
+
+func (cfg *apiConfig) VerifyEmail(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		Token string `json:"token"`
+	}
+
+	type ResponseJson struct {
+		ID            uuid.UUID `json:"id"`
+		CreatedAt     time.Time `json:"created_at"`
+		UpdatedAt     time.Time `json:"updated_at"`
+		Email         string    `json:"email"`
+		IsChirpyRed   bool      `json:"is_chirpy_red"`
+		EmailVerified bool      `json:"email_verified"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	tokenID, err := auth.ParseSignedToken(expectedJson.Token, emailVerificationPurpose, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+
+	verification, err := cfg.dbQueries.GetEmailVerificationToken(req.Context(), tokenID)
+	if err != nil || verification.UsedAt.Valid || verification.ExpiresAt.Before(time.Now()) {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+
+	previous, err := cfg.dbQueries.GetUserByID(req.Context(), verification.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	now := sql.NullTime{Time: time.Now(), Valid: true}
+	used, err := qtx.UseEmailVerificationToken(req.Context(), database.UseEmailVerificationTokenParams{UsedAt: now, ID: verification.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if used == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+
+	user, err := qtx.SetUserEmailVerified(req.Context(), database.SetUserEmailVerifiedParams{
+		Email:           verification.Email,
+		EmailVerifiedAt: now,
+		ID:              verification.UserID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusConflict, "Email address is no longer available")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	if verification.Email != previous.Email {
+		cfg.recordAudit(req.Context(), req, auditEntry{
+			ActorID:    actor(user.ID),
+			Action:     "user.email_changed",
+			TargetType: "user",
+			TargetID:   user.ID.String(),
+			Details:    map[string]string{"old_email": previous.Email, "new_email": user.Email},
+		})
+	}
+
+	responseJson := ResponseJson{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		IsChirpyRed:   user.IsChirpyRed,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+	}
+	respondWithJSON(w, http.StatusOK, responseJson)
+}
+
+// ResendVerificationEmail always answers 202 so it can't be used to find out
+// which addresses have accounts. The token is issued and mailed in the
+// background so the response time doesn't give the answer away either.
+func (cfg *apiConfig) ResendVerificationEmail(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	go cfg.resendVerificationEmail(context.WithoutCancel(req.Context()), expectedJson.Email)
+
+	respondWithJSON(w, http.StatusAccepted, map[string]string{"status": "If the address belongs to an unverified account, a new verification email is on its way."})
+}
+
+func (cfg *apiConfig) resendVerificationEmail(ctx context.Context, email string) {
+	user, err := cfg.dbQueries.GetUserByEmail(ctx, email)
+	if err != nil || user.EmailVerifiedAt.Valid {
+		return
+	}
+	if err := cfg.sendVerificationEmail(ctx, user, user.Email); err != nil {
+		log.Printf("Couldn't issue verification token: %v\n", err)
+	}
+}
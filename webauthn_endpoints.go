@@ -0,0 +1,479 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	auth "github.com/SergioFloresCorrea/Chirpy/internal"
+	"github.com/SergioFloresCorrea/Chirpy/internal/database"
+	"github.com/SergioFloresCorrea/Chirpy/internal/webauthn"
+	"github.com/google/uuid"
+)
+
+const (
+	webauthnCeremonyPurpose = "webauthn-ceremony"
+	ceremonyRegistration    = "registration"
+	ceremonyLogin           = "login"
+)
+
+// newRelyingParty configures passkeys for the site at baseURL. The relying
+// party ID and origins default to baseURL's host and origin; set
+// WEBAUTHN_RP_ID and WEBAUTHN_ORIGINS when the frontend is served elsewhere.
+func newRelyingParty(baseURL string) (*webauthn.RelyingParty, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid BASE_URL: %w", err)
+	}
+
+	rp := &webauthn.RelyingParty{
+		ID:      base.Hostname(),
+		Name:    "Chirpy",
+		Origins: []string{base.Scheme + "://" + base.Host},
+	}
+	if id := os.Getenv("WEBAUTHN_RP_ID"); id != "" {
+		rp.ID = id
+	}
+	if name := os.Getenv("WEBAUTHN_RP_NAME"); name != "" {
+		rp.Name = name
+	}
+	if origins := os.Getenv("WEBAUTHN_ORIGINS"); origins != "" {
+		rp.Origins = nil
+		for _, origin := range strings.Split(origins, ",") {
+			rp.Origins = append(rp.Origins, strings.TrimSpace(origin))
+		}
+	}
+	return rp, nil
+}
+
+type Passkey struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Transports []string   `json:"transports"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func toPasskey(credential database.WebauthnCredential) Passkey {
+	return Passkey{
+		ID:         credential.ID,
+		Name:       credential.Name,
+		Transports: credential.Transports,
+		CreatedAt:  credential.CreatedAt,
+		LastUsedAt: nullTimePtr(credential.LastUsedAt),
+	}
+}
+
+// startCeremony stores a fresh challenge and returns it along with the token
+// the client sends back to finish the ceremony.
+func (cfg *apiConfig) startCeremony(ctx context.Context, ceremony string, userID uuid.NullUUID, deviceName string) (challenge, ceremonyToken string, err error) {
+	challenge, err = webauthn.NewChallenge()
+	if err != nil {
+		return "", "", err
+	}
+	id := uuid.New()
+	err = cfg.dbQueries.CreateWebAuthnChallenge(ctx, database.CreateWebAuthnChallengeParams{
+		ID:         id,
+		CreatedAt:  time.Now(),
+		UserID:     userID,
+		Ceremony:   ceremony,
+		Challenge:  challenge,
+		DeviceName: deviceName,
+		ExpiresAt:  time.Now().Add(webauthn.Timeout),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return challenge, auth.MakeSignedToken(id, webauthnCeremonyPurpose, cfg.secret), nil
+}
+
+var errInvalidCeremony = errors.New("invalid or expired ceremony")
+
+// finishCeremony consumes the challenge behind ceremonyToken, so each one can
+// be answered only once.
+func (cfg *apiConfig) finishCeremony(ctx context.Context, ceremony, ceremonyToken string) (database.WebauthnChallenge, error) {
+	id, err := auth.ParseSignedToken(ceremonyToken, webauthnCeremonyPurpose, cfg.secret)
+	if err != nil {
+		return database.WebauthnChallenge{}, errInvalidCeremony
+	}
+	challenge, err := cfg.dbQueries.ConsumeWebAuthnChallenge(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.WebauthnChallenge{}, errInvalidCeremony
+	}
+	if err != nil {
+		return database.WebauthnChallenge{}, err
+	}
+	if challenge.Ceremony != ceremony || !time.Now().Before(challenge.ExpiresAt) {
+		return database.WebauthnChallenge{}, errInvalidCeremony
+	}
+	return challenge, nil
+}
+
+// BeginPasskeyRegistration returns the options for navigator.credentials.create.
+func (cfg *apiConfig) BeginPasskeyRegistration(w http.ResponseWriter, req *http.Request) {
+	type ResponseJson struct {
+		CeremonyToken string                   `json:"ceremony_token"`
+		PublicKey     webauthn.CreationOptions `json:"public_key"`
+	}
+
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	existing, err := cfg.dbQueries.ListWebAuthnCredentialsForUser(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	exclude := make([]webauthn.CredentialDescriptor, 0, len(existing))
+	for _, credential := range existing {
+		exclude = append(exclude, webauthn.Descriptor(credential.CredentialID, credential.Transports))
+	}
+
+	challenge, ceremonyToken, err := cfg.startCeremony(req.Context(), ceremonyRegistration, uuid.NullUUID{UUID: userID, Valid: true}, "")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	webauthnUser := webauthn.User{ID: user.ID[:], Name: user.Email, DisplayName: user.Email}
+	respondWithJSON(w, http.StatusOK, ResponseJson{
+		CeremonyToken: ceremonyToken,
+		PublicKey:     cfg.relyingParty.CreationOptions(challenge, webauthnUser, exclude),
+	})
+}
+
+// FinishPasskeyRegistration verifies the new credential and stores it.
+func (cfg *apiConfig) FinishPasskeyRegistration(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		CeremonyToken string                        `json:"ceremony_token"`
+		Name          string                        `json:"name"`
+		Credential    webauthn.RegistrationResponse `json:"credential"`
+	}
+
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	challenge, err := cfg.finishCeremony(req.Context(), ceremonyRegistration, expectedJson.CeremonyToken)
+	if errors.Is(err, errInvalidCeremony) || (err == nil && challenge.UserID.UUID != userID) {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired ceremony token")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	credential, err := cfg.relyingParty.VerifyRegistration(challenge.Challenge, expectedJson.Credential)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("The passkey couldn't be verified: %v", err))
+		return
+	}
+	if _, err := cfg.dbQueries.GetWebAuthnCredentialByCredentialID(req.Context(), credential.ID); err == nil {
+		respondWithError(w, http.StatusConflict, "This passkey is already registered")
+		return
+	}
+
+	name := strings.TrimSpace(expectedJson.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+	transports := credential.Transports
+	if transports == nil {
+		transports = []string{}
+	}
+	created, err := cfg.dbQueries.CreateWebAuthnCredential(req.Context(), database.CreateWebAuthnCredentialParams{
+		ID:           uuid.New(),
+		CreatedAt:    time.Now(),
+		UserID:       userID,
+		Name:         name,
+		CredentialID: credential.ID,
+		PublicKey:    credential.PublicKey,
+		SignCount:    int64(credential.SignCount),
+		Transports:   transports,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(userID),
+		Action:     "passkey.registered",
+		TargetType: "passkey",
+		TargetID:   created.ID.String(),
+		Details:    map[string]string{"name": created.Name},
+	})
+	if user, err := cfg.dbQueries.GetUserByID(req.Context(), userID); err == nil {
+		cfg.sendMail(req.Context(), user.Email, "A passkey was added to your Chirpy account",
+			fmt.Sprintf("The passkey %q can now be used to log in to your Chirpy account. If this wasn't you, remove it and change your password right away.", created.Name))
+	}
+
+	respondWithJSON(w, http.StatusCreated, toPasskey(created))
+}
+
+func (cfg *apiConfig) ListPasskeys(w http.ResponseWriter, req *http.Request) {
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	credentials, err := cfg.dbQueries.ListWebAuthnCredentialsForUser(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	responseJson := make([]Passkey, 0, len(credentials))
+	for _, credential := range credentials {
+		responseJson = append(responseJson, toPasskey(credential))
+	}
+	respondWithJSON(w, http.StatusOK, responseJson)
+}
+
+func (cfg *apiConfig) RenamePasskey(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		Name string `json:"name"`
+	}
+
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	passkeyID, err := uuid.Parse(req.PathValue("passkeyID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid passkey ID format")
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	name := strings.TrimSpace(expectedJson.Name)
+	if name == "" {
+		respondWithError(w, http.StatusBadRequest, "Passkey name is required")
+		return
+	}
+
+	renamed, err := cfg.dbQueries.RenameWebAuthnCredential(req.Context(), database.RenameWebAuthnCredentialParams{
+		Name:   name,
+		ID:     passkeyID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if renamed == 0 {
+		respondWithError(w, http.StatusNotFound, "Passkey not found")
+		return
+	}
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+func (cfg *apiConfig) DeletePasskey(w http.ResponseWriter, req *http.Request) {
+	caller, ok := cfg.authorize(w, req, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+	userID := caller.UserID
+
+	passkeyID, err := uuid.Parse(req.PathValue("passkeyID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid passkey ID format")
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteWebAuthnCredential(req.Context(), database.DeleteWebAuthnCredentialParams{
+		ID:     passkeyID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Passkey not found")
+		return
+	}
+
+	cfg.recordAudit(req.Context(), req, auditEntry{
+		ActorID:    actor(userID),
+		Action:     "passkey.deleted",
+		TargetType: "passkey",
+		TargetID:   passkeyID.String(),
+	})
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+// BeginPasskeyLogin returns the options for navigator.credentials.get. The
+// user isn't known yet; the passkey they pick identifies them.
+func (cfg *apiConfig) BeginPasskeyLogin(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		DeviceName string `json:"device_name"`
+	}
+	type ResponseJson struct {
+		CeremonyToken string                  `json:"ceremony_token"`
+		PublicKey     webauthn.RequestOptions `json:"public_key"`
+	}
+
+	expectedJson := ExpectedJson{}
+	if !hasNoBody(req) {
+		decoder := json.NewDecoder(req.Body)
+		if err := decoder.Decode(&expectedJson); err != nil {
+			respondWithError(w, 400, "Something went wrong")
+			return
+		}
+		defer req.Body.Close()
+	}
+
+	challenge, ceremonyToken, err := cfg.startCeremony(req.Context(), ceremonyLogin, uuid.NullUUID{}, expectedJson.DeviceName)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, ResponseJson{
+		CeremonyToken: ceremonyToken,
+		PublicKey:     cfg.relyingParty.RequestOptions(challenge),
+	})
+}
+
+// FinishPasskeyLogin verifies the assertion and starts a session, answering
+// like LoginUser. A passkey verifies the user on the device, so it counts as
+// both factors and no TOTP code is asked for.
+func (cfg *apiConfig) FinishPasskeyLogin(w http.ResponseWriter, req *http.Request) {
+	type ExpectedJson struct {
+		CeremonyToken string                     `json:"ceremony_token"`
+		Credential    webauthn.AssertionResponse `json:"credential"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	expectedJson := ExpectedJson{}
+	if err := decoder.Decode(&expectedJson); err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+	defer req.Body.Close()
+
+	challenge, err := cfg.finishCeremony(req.Context(), ceremonyLogin, expectedJson.CeremonyToken)
+	if errors.Is(err, errInvalidCeremony) {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired ceremony token")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+
+	credentialID, err := expectedJson.Credential.CredentialID()
+	if err != nil {
+		cfg.metrics.countLogin("passkey", false)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	credential, err := cfg.dbQueries.GetWebAuthnCredentialByCredentialID(req.Context(), credentialID)
+	if err != nil {
+		cfg.metrics.countLogin("passkey", false)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if expectedJson.Credential.Response.UserHandle != "" {
+		userHandle, err := expectedJson.Credential.UserHandle()
+		if err != nil || !bytes.Equal(userHandle, credential.UserID[:]) {
+			cfg.metrics.countLogin("passkey", false)
+			respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+	}
+
+	signCount, err := cfg.relyingParty.VerifyAssertion(challenge.Challenge, expectedJson.Credential, credential.PublicKey, uint32(credential.SignCount))
+	if errors.Is(err, webauthn.ErrSignCountRegressed) {
+		cfg.recordAudit(req.Context(), req, auditEntry{
+			Action:     "passkey.clone_suspected",
+			TargetType: "passkey",
+			TargetID:   credential.ID.String(),
+			Details:    map[string]string{"user_id": credential.UserID.String()},
+		})
+		cfg.metrics.countLogin("passkey", false)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if err != nil {
+		cfg.metrics.countLogin("passkey", false)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// The update only applies if no other login used the credential since it
+	// was read, so a counter can't be accepted twice.
+	updated, err := cfg.dbQueries.UpdateWebAuthnCredentialUse(req.Context(), database.UpdateWebAuthnCredentialUseParams{
+		SignCount:         int64(signCount),
+		LastUsedAt:        sql.NullTime{Time: time.Now(), Valid: true},
+		ID:                credential.ID,
+		PreviousSignCount: credential.SignCount,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%v", err))
+		return
+	}
+	if updated == 0 {
+		cfg.metrics.countLogin("passkey", false)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	cfg.metrics.countLogin("passkey", true)
+
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), credential.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if cfg.unverifiedRestrictions.blocks(actionLogin, user) {
+		respondWithError(w, http.StatusForbidden, "Verify your email address before logging in")
+		return
+	}
+	if cfg.refuseSuspendedLogin(w, req, user.ID) {
+		return
+	}
+
+	cfg.completeLogin(w, req, user, challenge.DeviceName)
+}
+
+func (cfg *apiConfig) purgeExpiredWebAuthnChallenges(ctx context.Context) error {
+	return cfg.dbQueries.DeleteExpiredWebAuthnChallenges(ctx, time.Now())
+}